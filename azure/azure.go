@@ -7,12 +7,18 @@
 package azure
 
 import (
+	"context"
+
 	"code.cloudfoundry.org/lager/v3"
 	"encoding/base64"
 	"fmt"
 	"github.com/Azure/azure-sdk-for-go/storage"
 	"github.com/pivotal-cf/service-backup/process"
+	"github.com/pivotal-cf/service-backup/throttle"
+	"github.com/pivotal-cf/service-backup/tracing"
+	"github.com/pivotal-cf/service-backup/workers"
 	"io"
+	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
@@ -25,6 +31,8 @@ type AzureClient struct {
 	container    string
 	endpoint     string
 	remotePathFn func() string
+	Throttle     *throttle.Throttle
+	Workers      int
 }
 
 const ChunkSize = 8 * 1024 * 1024 // 8MB
@@ -40,16 +48,18 @@ func New(name, accountKey, accountName, container, endpoint string, remotePathFn
 	}
 }
 
-func (a *AzureClient) Upload(localPath string, sessionLogger lager.Logger, processManager process.ProcessManager) error {
+func (a *AzureClient) Upload(ctx context.Context, localPath string, sessionLogger lager.Logger, processManager process.ProcessManager) error {
 	remotePath := a.remotePathFn()
 
 	sessionLogger.Info("Uploading azure blobs", lager.Data{"container": a.container, "localPath": localPath, "remotePath": remotePath})
 	sessionLogger.Info("The container and remote path will be created if they don't already exist", lager.Data{"container": a.container, "remotePath": remotePath})
 	sessionLogger.Info(fmt.Sprintf("about to upload %s to Azure remote path %s", localPath, remotePath))
-	return a.uploadDir(localPath, remotePath, processManager, sessionLogger)
+	a.Throttle.Log(sessionLogger)
+	workers.Log(sessionLogger, a.Workers)
+	return a.uploadDir(ctx, localPath, remotePath, processManager, sessionLogger)
 }
 
-func (a *AzureClient) uploadFile(sessionLogger lager.Logger, containerReference *storage.Container, localFilePath, remoteFilePath string) error {
+func (a *AzureClient) uploadFile(ctx context.Context, sessionLogger lager.Logger, containerReference *storage.Container, localFilePath, remoteFilePath string) error {
 	sessionLogger.Info(fmt.Sprintf("uploadFile: %s to %s", localFilePath, remoteFilePath))
 	file, err := os.Open(localFilePath)
 	if err != nil {
@@ -61,11 +71,17 @@ func (a *AzureClient) uploadFile(sessionLogger lager.Logger, containerReference
 	if err != nil {
 		return fmt.Errorf("error in uploadFile cloud not create block blob: %w", err)
 	}
+	reader := a.Throttle.Reader(file, sessionLogger)
 	buffer := make([]byte, ChunkSize)
 	blocks := []storage.Block{}
 	for i := 0; ; i++ {
-		bytesRead, err := file.Read(buffer)
-		if err != nil {
+		// The storage client does not take a context, so cancellation is
+		// checked between blocks instead.
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("error in uploadFile: %w", err)
+		}
+		bytesRead, err := io.ReadFull(reader, buffer)
+		if err != nil && err != io.ErrUnexpectedEOF {
 			if err == io.EOF {
 				break
 			} else {
@@ -91,14 +107,41 @@ func (a *AzureClient) uploadFile(sessionLogger lager.Logger, containerReference
 	return nil
 }
 
-func (a *AzureClient) uploadDir(localFilePath, remoteFileRoot string, processManager process.ProcessManager, sessionLogger lager.Logger) error {
+func (a *AzureClient) uploadDir(ctx context.Context, localFilePath, remoteFileRoot string, processManager process.ProcessManager, sessionLogger lager.Logger) error {
+	containerReference, err := a.containerReference(nil)
+	if err != nil {
+		return fmt.Errorf("error in uploadDir %w", err)
+	}
+
+	err = workers.ForEachFile(ctx, localFilePath, a.Workers, func(ctx context.Context, filePath string, d os.FileInfo) error {
+		filePathDifference := strings.Replace(filePath, localFilePath, "", -1)
+		remoteFilePath := filepath.Join(remoteFileRoot, filePathDifference)
+
+		fileCtx, span := tracing.StartFileSpan(ctx, filePath, d.Size())
+		err := a.uploadFile(fileCtx, sessionLogger, containerReference, filePath, remoteFilePath)
+		tracing.End(span, err)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("error in uploadDir when walking dir: %w", err)
+	}
+
+	return nil
+}
+
+// containerReference returns the container, creating it if need be. A nil
+// httpClient uses the storage client's default.
+func (a *AzureClient) containerReference(httpClient *http.Client) (*storage.Container, error) {
 	endpoint := storage.DefaultBaseURL
 	if len(a.endpoint) != 0 {
 		endpoint = a.endpoint
 	}
 	azureClient, err := storage.NewClient(a.accountName, a.accountKey, endpoint, storage.DefaultAPIVersion, true)
 	if err != nil {
-		return fmt.Errorf("error in uploadDir when creating client: %w", err)
+		return nil, fmt.Errorf("when creating client: %w", err)
+	}
+	if httpClient != nil {
+		azureClient.HTTPClient = httpClient
 	}
 
 	azureBlobService := azureClient.GetBlobService()
@@ -106,24 +149,9 @@ func (a *AzureClient) uploadDir(localFilePath, remoteFileRoot string, processMan
 	containerReference := azureBlobService.GetContainerReference(a.container)
 	_, err = containerReference.CreateIfNotExists(&storage.CreateContainerOptions{})
 	if err != nil {
-		return fmt.Errorf("error in uploadDir Failed to establish a new connection: %w", err)
+		return nil, fmt.Errorf("Failed to establish a new connection: %w", err)
 	}
-
-	err = filepath.Walk(localFilePath, func(filePath string, d os.FileInfo, err error) error {
-		if d.IsDir() {
-			return nil
-		}
-
-		filePathDifference := strings.Replace(filePath, localFilePath, "", -1)
-		remoteFilePath := filepath.Join(remoteFileRoot, filePathDifference)
-
-		return a.uploadFile(sessionLogger, containerReference, filePath, remoteFilePath)
-	})
-	if err != nil {
-		return fmt.Errorf("error in uploadDir when walking dir: %w", err)
-	}
-
-	return nil
+	return containerReference, nil
 }
 
 func (a *AzureClient) Name() string {
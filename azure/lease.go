@@ -0,0 +1,112 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package azure
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"code.cloudfoundry.org/lager/v3"
+	"github.com/Azure/azure-sdk-for-go/storage"
+	"github.com/pivotal-cf/service-backup/lease"
+)
+
+// electionLeaseSeconds is how long the blob lease guarding an election is
+// held for, the shortest Azure allows. It is released as soon as the election
+// is over.
+const electionLeaseSeconds = 15
+
+type leaseBackend struct {
+	client *AzureClient
+	key    string
+
+	// httpClient, if set, replaces the storage client's, to point it at a
+	// fake server in tests.
+	httpClient *http.Client
+}
+
+// LeaseBackend stores a lease as the blob key in the container this client
+// uploads to. Each node takes a blob lease on it while reading and writing
+// it, so only one node can take the lease.
+func (a *AzureClient) LeaseBackend(key string, _ lager.Logger) lease.Backend {
+	return &leaseBackend{client: a, key: key}
+}
+
+func (b *leaseBackend) Acquire(ctx context.Context, l lease.Lease) (lease.Lease, error) {
+	containerReference, err := b.client.containerReference(b.httpClient)
+	if err != nil {
+		return lease.Lease{}, err
+	}
+	blob := containerReference.GetBlobReference(b.key)
+
+	// A blob lease can only be taken on a blob that exists.
+	err = blob.CreateBlockBlobFromReader(bytes.NewReader(nil), &storage.PutBlobOptions{IfNoneMatch: "*"})
+	if err != nil && !hasStatus(err, http.StatusConflict, http.StatusPreconditionFailed) {
+		return lease.Lease{}, fmt.Errorf("error creating lease blob: %w", err)
+	}
+
+	leaseID, err := blob.AcquireLease(electionLeaseSeconds, "", nil)
+	if hasStatus(err, http.StatusConflict) {
+		// Another node is taking the lease right now.
+		return b.get(blob, "")
+	}
+	if err != nil {
+		return lease.Lease{}, fmt.Errorf("error acquiring lease on lease blob: %w", err)
+	}
+	defer blob.ReleaseLease(leaseID, nil)
+
+	current, err := b.get(blob, leaseID)
+	if err != nil {
+		return lease.Lease{}, err
+	}
+	if !current.FreeFor(l.Holder, l.AcquiredAt) {
+		return current, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return lease.Lease{}, err
+	}
+
+	contents, err := lease.Encode(l)
+	if err != nil {
+		return lease.Lease{}, err
+	}
+	if err := blob.CreateBlockBlobFromReader(bytes.NewReader(contents), &storage.PutBlobOptions{LeaseID: leaseID}); err != nil {
+		return lease.Lease{}, fmt.Errorf("error writing lease blob: %w", err)
+	}
+	return l, nil
+}
+
+func (b *leaseBackend) get(blob *storage.Blob, leaseID string) (lease.Lease, error) {
+	reader, err := blob.Get(&storage.GetBlobOptions{LeaseID: leaseID})
+	if err != nil {
+		return lease.Lease{}, fmt.Errorf("error reading lease blob: %w", err)
+	}
+	defer reader.Close()
+
+	contents, err := io.ReadAll(reader)
+	if err != nil {
+		return lease.Lease{}, fmt.Errorf("error reading lease blob: %w", err)
+	}
+	return lease.Decode(contents)
+}
+
+func hasStatus(err error, statuses ...int) bool {
+	var serviceErr storage.AzureStorageServiceError
+	if !errors.As(err, &serviceErr) {
+		return false
+	}
+	for _, status := range statuses {
+		if serviceErr.StatusCode == status {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,158 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package azure
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+	"github.com/pivotal-cf/service-backup/lease"
+)
+
+var _ = Describe("LeaseBackend", func() {
+	const blobPath = "/container/leases/leader.json"
+
+	var (
+		server  *ghttp.Server
+		backend *leaseBackend
+		now     time.Time
+		mine    lease.Lease
+		theirs  lease.Lease
+	)
+
+	BeforeEach(func() {
+		server = ghttp.NewTLSServer()
+		client := New("azure", "YWNjb3VudC1rZXk=", "account", "container", "core.windows.net", func() string { return "path" })
+		backend = client.LeaseBackend("leases/leader.json", nil).(*leaseBackend)
+		// The account is addressed by virtual host, so every host is sent to
+		// the fake server.
+		backend.httpClient = redirectedClient(server)
+
+		now = time.Now().UTC().Round(0)
+		mine = lease.Lease{Holder: "node-a", ScheduledAt: now, AcquiredAt: now, ExpiresAt: now.Add(time.Hour)}
+		theirs = lease.Lease{Holder: "node-b", ScheduledAt: now, AcquiredAt: now, ExpiresAt: now.Add(time.Hour)}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	respondWithError := func(status int, code string) http.HandlerFunc {
+		return ghttp.RespondWith(status, "<Error><Code>"+code+"</Code><Message>"+code+"</Message></Error>")
+	}
+	createContainer := ghttp.CombineHandlers(
+		ghttp.VerifyRequest(http.MethodPut, "/container", "restype=container"),
+		ghttp.RespondWith(http.StatusCreated, nil),
+	)
+	createBlob := func(response http.HandlerFunc) http.HandlerFunc {
+		return ghttp.CombineHandlers(
+			ghttp.VerifyRequest(http.MethodPut, blobPath),
+			ghttp.VerifyHeaderKV("If-None-Match", "*"),
+			response,
+		)
+	}
+	leaseAction := func(action string, response http.HandlerFunc) http.HandlerFunc {
+		return ghttp.CombineHandlers(
+			ghttp.VerifyRequest(http.MethodPut, blobPath, "comp=lease"),
+			ghttp.VerifyHeaderKV("x-ms-lease-action", action),
+			response,
+		)
+	}
+	acquired := ghttp.RespondWith(http.StatusCreated, nil, http.Header{"x-ms-lease-id": {"lease-id"}})
+	getBlob := func(contents string) http.HandlerFunc {
+		return ghttp.CombineHandlers(
+			ghttp.VerifyRequest(http.MethodGet, blobPath),
+			ghttp.RespondWith(http.StatusOK, contents),
+		)
+	}
+	writeBlob := ghttp.CombineHandlers(
+		ghttp.VerifyRequest(http.MethodPut, blobPath),
+		ghttp.VerifyHeaderKV("x-ms-lease-id", "lease-id"),
+		func(w http.ResponseWriter, r *http.Request) {
+			body, err := io.ReadAll(r.Body)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(body)).To(ContainSubstring(`"holder":"node-a"`))
+		},
+		ghttp.RespondWith(http.StatusCreated, nil),
+	)
+	encode := func(l lease.Lease) string {
+		contents, err := lease.Encode(l)
+		Expect(err).NotTo(HaveOccurred())
+		return string(contents)
+	}
+
+	It("creates the lease blob and writes the lease while holding a blob lease", func() {
+		server.AppendHandlers(
+			createContainer,
+			createBlob(ghttp.RespondWith(http.StatusCreated, nil)),
+			leaseAction("acquire", acquired),
+			getBlob(""),
+			writeBlob,
+			leaseAction("release", ghttp.RespondWith(http.StatusOK, nil)),
+		)
+
+		Expect(backend.Acquire(context.Background(), mine)).To(Equal(mine))
+		Expect(server.ReceivedRequests()).To(HaveLen(6))
+	})
+
+	It("replaces an expired lease in an existing blob", func() {
+		expired := theirs
+		expired.ExpiresAt = now.Add(-time.Minute)
+		server.AppendHandlers(
+			createContainer,
+			createBlob(respondWithError(http.StatusConflict, "BlobAlreadyExists")),
+			leaseAction("acquire", acquired),
+			getBlob(encode(expired)),
+			writeBlob,
+			leaseAction("release", ghttp.RespondWith(http.StatusOK, nil)),
+		)
+
+		Expect(backend.Acquire(context.Background(), mine)).To(Equal(mine))
+	})
+
+	It("returns the lease held by another node without writing", func() {
+		server.AppendHandlers(
+			createContainer,
+			createBlob(respondWithError(http.StatusConflict, "BlobAlreadyExists")),
+			leaseAction("acquire", acquired),
+			getBlob(encode(theirs)),
+			leaseAction("release", ghttp.RespondWith(http.StatusOK, nil)),
+		)
+
+		Expect(backend.Acquire(context.Background(), mine)).To(Equal(theirs))
+		Expect(server.ReceivedRequests()).To(HaveLen(5))
+	})
+
+	It("returns the winner's lease when another node holds the blob lease", func() {
+		server.AppendHandlers(
+			createContainer,
+			createBlob(respondWithError(http.StatusConflict, "BlobAlreadyExists")),
+			leaseAction("acquire", respondWithError(http.StatusConflict, "LeaseAlreadyPresent")),
+			getBlob(encode(theirs)),
+		)
+
+		Expect(backend.Acquire(context.Background(), mine)).To(Equal(theirs))
+		Expect(server.ReceivedRequests()).To(HaveLen(4))
+	})
+})
+
+// redirectedClient sends every request to server, whatever host it is for.
+func redirectedClient(server *ghttp.Server) *http.Client {
+	return &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return new(net.Dialer).DialContext(ctx, network, server.Addr())
+		},
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}}
+}
@@ -0,0 +1,148 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+// backup-schedule prints when the backups configured in a service-backup
+// config file will next run and, if the daemon's control API is configured,
+// the status of the running daemon.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/lager/v3"
+	"github.com/pivotal-cf/service-backup/config"
+	"github.com/pivotal-cf/service-backup/control"
+	"github.com/pivotal-cf/service-backup/scheduler"
+	cron "github.com/robfig/cron/v3"
+)
+
+const timeFormat = "Mon 2006-01-02 15:04:05 MST"
+
+func main() {
+	count := flag.Int("count", 5, "number of upcoming runs to print")
+	flag.Parse()
+
+	logger := lager.NewLogger("ServiceBackup")
+	logger.RegisterSink(lager.NewWriterSink(os.Stderr, lager.ERROR))
+
+	backupConfig, err := config.Parse(flag.Arg(0), logger)
+	if err != nil {
+		fail("failed to parse config: %s", err)
+	}
+
+	blackoutWindows, err := scheduler.BlackoutWindowsFromConfig(backupConfig.BlackoutWindows, backupConfig.CronTimezone)
+	if err != nil {
+		fail("%s", err)
+	}
+
+	now := time.Now()
+	if backupConfig.NoDestinations() {
+		fmt.Println("No destinations configured, backups are skipped")
+	} else {
+		schedule, err := scheduler.ParseSchedule(backupConfig.CronSchedule, backupConfig.CronTimezone)
+		if err != nil {
+			fail("invalid cron_schedule: %s", err)
+		}
+		printRuns(os.Stdout, "Backups", backupConfig.CronSchedule, schedule, blackoutWindows, now, *count)
+
+		for _, destination := range backupConfig.Destinations {
+			if destination.CronSchedule == "" {
+				continue
+			}
+			schedule, err := scheduler.ParseSchedule(destination.CronSchedule, backupConfig.CronTimezone)
+			if err != nil {
+				fail("invalid cron_schedule for destination %s: %s", destination.Name, err)
+			}
+			printRuns(os.Stdout, "Uploads to "+destination.Name, destination.CronSchedule, schedule, blackoutWindows, now, *count)
+		}
+	}
+
+	if apiConfig := backupConfig.ControlAPI; apiConfig != nil {
+		client := control.NewClient(apiConfig.SocketPath, apiConfig.Address, apiConfig.Token)
+		status, err := client.Status(context.Background())
+		if err != nil {
+			fmt.Printf("\nDaemon not reachable: %s\n", err)
+			return
+		}
+		printStatus(os.Stdout, status)
+	}
+}
+
+func printRuns(w io.Writer, title, spec string, schedule cron.Schedule, blackoutWindows []scheduler.BlackoutWindow, now time.Time, count int) {
+	location := scheduler.Location(schedule)
+	if location == nil {
+		location = time.Local
+	}
+
+	fmt.Fprintf(w, "%s, on schedule %q in %s:\n", title, spec, location)
+	runs := scheduler.NextRuns(schedule, now, count)
+	if len(runs) == 0 {
+		fmt.Fprintln(w, "  never")
+	}
+	for _, run := range runs {
+		fmt.Fprintf(w, "  %s  (%s)", run.In(location).Format(timeFormat), run.UTC().Format(time.RFC3339))
+		if window, end, in := scheduler.Blackout(blackoutWindows, run); in {
+			fmt.Fprintf(w, "  in blackout window %s until %s", window, end.In(location).Format(timeFormat))
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+func printStatus(w io.Writer, status control.StatusResponse) {
+	fmt.Fprintln(w, "\nDaemon:")
+	fmt.Fprintf(w, "  paused: %t\n", status.Paused)
+	if status.NextRun != nil {
+		fmt.Fprintf(w, "  next run: %s\n", status.NextRun.Local().Format(timeFormat))
+	}
+	if len(status.CurrentRuns) == 0 {
+		fmt.Fprintln(w, "  in progress: none")
+	}
+	for _, run := range status.CurrentRuns {
+		fmt.Fprintf(w, "  in progress: %s, started %s\n", run.BackupGUID, run.StartedAt.Local().Format(timeFormat))
+	}
+
+	if status.LastRun == nil {
+		fmt.Fprintln(w, "  last run: none since the daemon started")
+		return
+	}
+	run := status.LastRun
+	outcome := "succeeded"
+	switch {
+	case run.Cancelled:
+		outcome = "cancelled"
+	case !run.Succeeded:
+		outcome = "failed"
+	}
+	finished := ""
+	if run.FinishedAt != nil {
+		finished = ", finished " + run.FinishedAt.Local().Format(timeFormat)
+	}
+	fmt.Fprintf(w, "  last run: %s %s%s\n", run.BackupGUID, outcome, finished)
+	if run.FailedPhase != "" {
+		fmt.Fprintf(w, "    failed phase: %s\n", run.FailedPhase)
+	}
+	if run.Error != "" {
+		fmt.Fprintf(w, "    error: %s\n", run.Error)
+	}
+	for _, destination := range run.Destinations {
+		if destination.Error != "" {
+			fmt.Fprintf(w, "    destination %s failed: %s\n", destination.Name, destination.Error)
+		}
+	}
+	if run.CleanupError != "" {
+		fmt.Fprintf(w, "    cleanup error: %s\n", run.CleanupError)
+	}
+}
+
+func fail(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(2)
+}
@@ -7,14 +7,23 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"code.cloudfoundry.org/lager/v3"
 	"github.com/pivotal-cf/service-backup/config"
 	"github.com/pivotal-cf/service-backup/executor"
+	"github.com/pivotal-cf/service-backup/logging"
+	"github.com/pivotal-cf/service-backup/notify"
 	"github.com/pivotal-cf/service-backup/process"
+	"github.com/pivotal-cf/service-backup/scheduler"
+	"github.com/pivotal-cf/service-backup/tracing"
 	"github.com/pivotal-cf/service-backup/upload"
 )
 
@@ -22,23 +31,68 @@ func main() {
 	sigterms := make(chan os.Signal, 1)
 	signal.Notify(sigterms, syscall.SIGTERM, syscall.SIGINT)
 
-	logger := lager.NewLogger("ServiceBackup")
-	logger.RegisterSink(lager.NewWriterSink(os.Stdout, lager.INFO))
+	logFlags := logging.RegisterFlags(flag.CommandLine, logging.FormatJSON)
+	overrideBlackout := flag.Bool("override-blackout", false, "back up even during a configured blackout window")
+	flag.Parse()
 
-	configPath := os.Args[1]
+	logger, err := logFlags.NewLogger("ServiceBackup", config.Logging{})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid logging flags: %s\n", err)
+		os.Exit(2)
+	}
+
+	configPath := flag.Arg(0)
 	backupConfig, err := config.Parse(configPath, logger)
 	if err != nil {
 		logger.Error("failed to parse config", err)
 		os.Exit(2)
 	}
 
-	backuper, err := upload.Initialize(&backupConfig, logger)
+	configuredLogger, err := logFlags.NewLogger("ServiceBackup", backupConfig.Logging)
+	if err != nil {
+		logger.Error("failed to configure logging", err)
+		os.Exit(2)
+	}
+	logger = configuredLogger
+
+	blackoutWindows, err := scheduler.BlackoutWindowsFromConfig(backupConfig.BlackoutWindows, backupConfig.CronTimezone)
+	if err != nil {
+		logger.Error("failed to configure blackout windows", err)
+		os.Exit(2)
+	}
+	if window, end, in := scheduler.Blackout(blackoutWindows, time.Now()); in {
+		data := lager.Data{"window": window, "window_end": end.UTC()}
+		if !*overrideBlackout {
+			logger.Error("Not backing up during blackout window", errors.New("run with -override-blackout to back up anyway"), data)
+			os.Exit(2)
+		}
+		logger.Info("Backing up during blackout window, as overridden", data)
+	}
+
+	scheduleLocation, err := scheduler.ScheduleLocation(backupConfig.CronSchedule, backupConfig.CronTimezone)
+	if err != nil {
+		logger.Error("failed to parse cron schedule", err)
+		os.Exit(2)
+	}
+	backuper, err := upload.Initialize(&backupConfig, logger, upload.WithLocation(scheduleLocation))
 	if err != nil {
 		logger.Error("failed to initialize uploader", err)
 		os.Exit(2)
 	}
 
-	terminator := process.NewManager()
+	managerOptions, err := process.OptionsFromConfig(backupConfig.ProcessPriority, logger)
+	if err != nil {
+		logger.Error("failed to configure process priority", err)
+		os.Exit(2)
+	}
+	managerOptions = append(managerOptions, process.OutputOptionsFromConfig(backupConfig.ChildOutput)...)
+	terminator := process.NewManager(managerOptions...)
+
+	tracerProvider, shutdownTracing, err := tracing.NewTracerProvider(backupConfig.Tracing, backupConfig.DeploymentName, logger)
+	if err != nil {
+		logger.Error("failed to configure tracing", err)
+		os.Exit(2)
+	}
 	go func() {
 		<-sigterms
 		terminator.Terminate()
@@ -46,6 +100,30 @@ func main() {
 		os.Exit(1)
 	}()
 
+	outputTail, err := executor.OutputTailFromConfig(backupConfig)
+	if err != nil {
+		logger.Error("failed to configure child output", err)
+		os.Exit(2)
+	}
+
+	executorOptions := []executor.Option{
+		executor.WithTracerProvider(tracerProvider),
+		executor.WithOutputTail(outputTail),
+	}
+	if backupConfig.ChildOutput != nil && backupConfig.ChildOutput.Stream {
+		executorOptions = append(executorOptions, executor.WithStreamedOutput())
+	}
+	notifiers, err := notify.NotifiersFromConfig(backupConfig, logger)
+	if err != nil {
+		logger.Error("failed to configure notifications", err)
+		os.Exit(2)
+	}
+	var dispatcher *notify.Dispatcher
+	if len(notifiers) > 0 {
+		dispatcher = notify.NewDispatcher(logger, notifiers...)
+		executorOptions = append(executorOptions, executor.WithObserver(dispatcher))
+	}
+
 	var backupExecutor executor.Executor
 	if backupConfig.NoDestinations() {
 		logger.Info("No destination provided - skipping backup")
@@ -65,9 +143,18 @@ func main() {
 			backupConfig.ExitIfInProgress,
 			logger,
 			terminator,
+			executorOptions...,
 		)
 	}
-	if err := backupExecutor.Execute(); err != nil {
+	err = backupExecutor.Execute()
+	if dispatcher != nil {
+		// Each event is bounded by its notifiers, so wait for all of them.
+		dispatcher.Close(context.Background())
+	}
+	if shutdownErr := shutdownTracing(context.Background()); shutdownErr != nil {
+		logger.Error("failed to flush traces", shutdownErr)
+	}
+	if err != nil {
 		logger.Error("Error running backup", err)
 		os.Exit(2)
 	}
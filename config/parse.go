@@ -34,29 +34,197 @@ func Parse(backupConfigPath string, logger lager.Logger) (BackupConfig, error) {
 }
 
 type Destination struct {
-	Type   string                 `yaml:"type"`
-	Name   string                 `yaml:"name"`
-	Config map[string]interface{} `yaml:"config"`
+	Type           string                 `yaml:"type"`
+	Name           string                 `yaml:"name"`
+	Config         map[string]interface{} `yaml:"config"`
+	BandwidthLimit *BandwidthLimit        `yaml:"bandwidth_limit,omitempty"`
+	CronSchedule   string                 `yaml:"cron_schedule,omitempty"`
+	UploadWorkers  int                    `yaml:"upload_workers,omitempty"`
+}
+
+type BandwidthLimit struct {
+	BytesPerSecond int64             `yaml:"bytes_per_second"`
+	Schedule       []BandwidthWindow `yaml:"schedule"`
+}
+
+type BandwidthWindow struct {
+	Start          string `yaml:"start"`
+	End            string `yaml:"end"`
+	BytesPerSecond int64  `yaml:"bytes_per_second"`
 }
 
 type Alerts struct {
-	ProductName string        `yaml:"product_name"`
-	Config      alerts.Config `yaml:"config"`
+	ProductName string            `yaml:"product_name"`
+	Config      alerts.Config     `yaml:"config"`
+	Subject     string            `yaml:"subject"`
+	Body        string            `yaml:"body"`
+	Labels      map[string]string `yaml:"labels,omitempty"`
+}
+
+type ProcessPriority struct {
+	Nice        int     `yaml:"nice"`
+	IONiceClass string  `yaml:"ionice_class"`
+	IONiceLevel int     `yaml:"ionice_level"`
+	Cgroup      *Cgroup `yaml:"cgroup,omitempty"`
+}
+
+type Cgroup struct {
+	Name           string `yaml:"name"`
+	CPUMaxPercent  int    `yaml:"cpu_max_percent"`
+	MemoryMaxBytes int64  `yaml:"memory_max_bytes"`
+}
+
+type Metrics struct {
+	Address string `yaml:"address"`
+}
+
+type ControlAPI struct {
+	SocketPath string `yaml:"socket_path"`
+	Address    string `yaml:"address"`
+	Token      string `yaml:"token"`
+}
+
+type Logging struct {
+	Format string     `yaml:"format"`
+	Level  string     `yaml:"level"`
+	File   *LogFile   `yaml:"file,omitempty"`
+	Syslog *LogSyslog `yaml:"syslog,omitempty"`
+}
+
+type LogFile struct {
+	Path         string `yaml:"path"`
+	MaxSizeBytes int64  `yaml:"max_size_bytes"`
+	MaxBackups   int    `yaml:"max_backups"`
+}
+
+type LogSyslog struct {
+	Network string `yaml:"network"`
+	Address string `yaml:"address"`
+	Tag     string `yaml:"tag"`
+}
+
+type Tracing struct {
+	OTLPEndpoint string            `yaml:"otlp_endpoint"`
+	Headers      map[string]string `yaml:"headers,omitempty"`
+	FilePath     string            `yaml:"file_path"`
+}
+
+type Webhook struct {
+	URL            string            `yaml:"url"`
+	Events         []string          `yaml:"events,omitempty"`
+	Secret         string            `yaml:"secret"`
+	Headers        map[string]string `yaml:"headers,omitempty"`
+	TimeoutSeconds int               `yaml:"timeout_seconds"`
+	MaxRetries     int               `yaml:"max_retries"`
+}
+
+type Email struct {
+	Host              string   `yaml:"host"`
+	Port              int      `yaml:"port"`
+	TLS               string   `yaml:"tls"`
+	SkipSSLValidation bool     `yaml:"skip_ssl_validation"`
+	Username          string   `yaml:"username"`
+	Password          string   `yaml:"password"`
+	From              string   `yaml:"from"`
+	To                []string `yaml:"to"`
+	Subject           string   `yaml:"subject"`
+	Body              string   `yaml:"body"`
+}
+
+type PagerDuty struct {
+	RoutingKey string            `yaml:"routing_key"`
+	APIURL     string            `yaml:"api_url"`
+	Severities map[string]string `yaml:"severities,omitempty"`
+}
+
+type DeadMansSwitch struct {
+	MaxAgeSeconds        int    `yaml:"max_age_seconds"`
+	CheckIntervalSeconds int    `yaml:"check_interval_seconds"`
+	HeartbeatURL         string `yaml:"heartbeat_url"`
+	StatePath            string `yaml:"state_path"`
+}
+
+type AlertRateLimit struct {
+	RenotifyIntervalSeconds int    `yaml:"renotify_interval_seconds"`
+	DigestTime              string `yaml:"digest_time"`
+}
+
+type ChildOutput struct {
+	TailLines            int      `yaml:"tail_lines"`
+	TailBytes            int      `yaml:"tail_bytes"`
+	RedactPatterns       []string `yaml:"redact_patterns,omitempty"`
+	Stream               bool     `yaml:"stream"`
+	StreamLinesPerSecond int      `yaml:"stream_lines_per_second"`
+	MaxBufferedBytes     int      `yaml:"max_buffered_bytes"`
+}
+
+type Splay struct {
+	MaxRandomSeconds int `yaml:"max_random_seconds"`
+	MaxOffsetSeconds int `yaml:"max_offset_seconds"`
+}
+
+type CatchUp struct {
+	StatePath          string `yaml:"state_path"`
+	Policy             string `yaml:"policy"`
+	DelaySeconds       int    `yaml:"delay_seconds"`
+	MinIntervalSeconds int    `yaml:"min_interval_seconds"`
+}
+
+type BlackoutWindow struct {
+	Name            string `yaml:"name"`
+	Schedule        string `yaml:"schedule"`
+	DurationSeconds int    `yaml:"duration_seconds"`
+	From            string `yaml:"from"`
+	To              string `yaml:"to"`
+	Action          string `yaml:"action"`
+}
+
+type Shutdown struct {
+	DrainSeconds     int `yaml:"drain_seconds"`
+	KillAfterSeconds int `yaml:"kill_after_seconds"`
+}
+
+type LeaderElection struct {
+	Destination string `yaml:"destination"`
+	LeasePath   string `yaml:"lease_path"`
+	Key         string `yaml:"key"`
+	Holder      string `yaml:"holder"`
+	TTLSeconds  int    `yaml:"ttl_seconds"`
 }
 
 type BackupConfig struct {
-	Destinations                []Destination `yaml:"destinations"`
-	SourceFolder                string        `yaml:"source_folder"`
-	SourceExecutable            string        `yaml:"source_executable"`
-	CronSchedule                string        `yaml:"cron_schedule"`
-	CleanupExecutable           string        `yaml:"cleanup_executable"`
-	MissingPropertiesMessage    string        `yaml:"missing_properties_message"`
-	ExitIfInProgress            bool          `yaml:"exit_if_in_progress"`
-	ServiceIdentifierExecutable string        `yaml:"service_identifier_executable"`
-	DeploymentName              string        `yaml:"deployment_name"`
-	AddDeploymentName           bool          `yaml:"add_deployment_name_to_backup_path"`
-	AwsCliPath                  string        `yaml:"aws_cli_path"`
-	Alerts                      *Alerts       `yaml:"alerts,omitempty"`
+	Destinations                []Destination     `yaml:"destinations"`
+	SourceFolder                string            `yaml:"source_folder"`
+	SourceExecutable            string            `yaml:"source_executable"`
+	CronSchedule                string            `yaml:"cron_schedule"`
+	CronTimezone                string            `yaml:"cron_timezone"`
+	CleanupExecutable           string            `yaml:"cleanup_executable"`
+	MissingPropertiesMessage    string            `yaml:"missing_properties_message"`
+	ExitIfInProgress            bool              `yaml:"exit_if_in_progress"`
+	ServiceIdentifierExecutable string            `yaml:"service_identifier_executable"`
+	DeploymentName              string            `yaml:"deployment_name"`
+	AddDeploymentName           bool              `yaml:"add_deployment_name_to_backup_path"`
+	AwsCliPath                  string            `yaml:"aws_cli_path"`
+	Alerts                      *Alerts           `yaml:"alerts,omitempty"`
+	ProcessPriority             *ProcessPriority  `yaml:"process_priority,omitempty"`
+	BandwidthLimit              *BandwidthLimit   `yaml:"bandwidth_limit,omitempty"`
+	UploadParallelism           int               `yaml:"upload_parallelism"`
+	Metrics                     *Metrics          `yaml:"metrics,omitempty"`
+	ControlAPI                  *ControlAPI       `yaml:"control_api,omitempty"`
+	Logging                     Logging           `yaml:"logging"`
+	Tracing                     *Tracing          `yaml:"tracing,omitempty"`
+	Webhooks                    []Webhook         `yaml:"webhooks,omitempty"`
+	Email                       *Email            `yaml:"email,omitempty"`
+	PagerDuty                   *PagerDuty        `yaml:"pagerduty,omitempty"`
+	DeadMansSwitch              *DeadMansSwitch   `yaml:"dead_mans_switch,omitempty"`
+	AlertRateLimit              *AlertRateLimit   `yaml:"alert_rate_limit,omitempty"`
+	ChildOutput                 *ChildOutput      `yaml:"child_output,omitempty"`
+	AlertSeverities             map[string]string `yaml:"alert_severities,omitempty"`
+	Splay                       *Splay            `yaml:"splay,omitempty"`
+	CatchUp                     *CatchUp          `yaml:"catch_up,omitempty"`
+	BlackoutWindows             []BlackoutWindow  `yaml:"blackout_windows,omitempty"`
+	Shutdown                    *Shutdown         `yaml:"shutdown,omitempty"`
+	LeaderElection              *LeaderElection   `yaml:"leader_election,omitempty"`
 }
 
 func (b BackupConfig) NoDestinations() bool {
@@ -67,11 +67,14 @@ var _ = Describe("Parse", func() {
 							"access_key_id":     "AKAIADCIWI@ICFIJ",
 							"secret_access_key": "ASCDMIACDNI@UD937e9237aSCDAS",
 						},
+						CronSchedule:  "0 0 2 * * *",
+						UploadWorkers: 8,
 					},
 				}))
 				Expect(backupConfig.SourceFolder).To(Equal("."))
 				Expect(backupConfig.SourceExecutable).To(Equal("ls"))
 				Expect(backupConfig.CronSchedule).To(Equal("*/5 * * * * *"))
+				Expect(backupConfig.CronTimezone).To(Equal("Europe/Berlin"))
 				Expect(backupConfig.CleanupExecutable).To(Equal("ls"))
 				Expect(backupConfig.MissingPropertiesMessage).To(Equal("custom message"))
 				Expect(backupConfig.ExitIfInProgress).To(BeTrue())
@@ -96,8 +99,113 @@ var _ = Describe("Parse", func() {
 						GlobalTimeoutSeconds: 42,
 						SkipSSLValidation:    boolPointer(true),
 					},
+					Subject: "[{{.Labels.team}}] {{.Title}}",
+					Body:    "{{.Summary}}",
+					Labels:  map[string]string{"team": "data"},
 				}))
 				Expect(backupConfig.DeploymentName).To(Equal("deployment-name"))
+				Expect(backupConfig.ProcessPriority).To(Equal(&config.ProcessPriority{
+					Nice:        10,
+					IONiceClass: "best-effort",
+					IONiceLevel: 7,
+					Cgroup: &config.Cgroup{
+						Name:           "service-backup",
+						CPUMaxPercent:  50,
+						MemoryMaxBytes: 1073741824,
+					},
+				}))
+				Expect(backupConfig.BandwidthLimit).To(Equal(&config.BandwidthLimit{
+					BytesPerSecond: 20971520,
+					Schedule: []config.BandwidthWindow{
+						{Start: "00:00", End: "06:00", BytesPerSecond: 0},
+					},
+				}))
+				Expect(backupConfig.UploadParallelism).To(Equal(2))
+				Expect(backupConfig.Metrics).To(Equal(&config.Metrics{Address: "127.0.0.1:9399"}))
+				Expect(backupConfig.ControlAPI).To(Equal(&config.ControlAPI{
+					SocketPath: "/var/vcap/sys/run/service-backup/control.sock",
+					Token:      "control-token",
+				}))
+				Expect(backupConfig.Logging).To(Equal(config.Logging{
+					Format: "plain",
+					Level:  "debug",
+					File: &config.LogFile{
+						Path:         "/var/vcap/sys/log/service-backup/service-backup.log",
+						MaxSizeBytes: 10485760,
+						MaxBackups:   5,
+					},
+					Syslog: &config.LogSyslog{
+						Network: "udp",
+						Address: "127.0.0.1:514",
+						Tag:     "service-backup",
+					},
+				}))
+				Expect(backupConfig.Tracing).To(Equal(&config.Tracing{
+					OTLPEndpoint: "https://otel-collector.example.com:4318/v1/traces",
+					Headers:      map[string]string{"Authorization": "Bearer tracing-token"},
+					FilePath:     "/var/vcap/sys/log/service-backup/traces.json",
+				}))
+				Expect(backupConfig.Webhooks).To(Equal([]config.Webhook{{
+					URL:            "https://hooks.example.com/service-backup",
+					Events:         []string{"run_failed", "destination_failed"},
+					Secret:         "webhook-secret",
+					Headers:        map[string]string{"X-Team": "data"},
+					TimeoutSeconds: 5,
+					MaxRetries:     3,
+				}}))
+				Expect(backupConfig.Email).To(Equal(&config.Email{
+					Host:     "smtp.example.com",
+					Port:     587,
+					TLS:      "starttls",
+					Username: "backups",
+					Password: "smtp-password",
+					From:     "backups@example.com",
+					To:       []string{"ops@example.com", "dba@example.com"},
+					Subject:  "[{{.DeploymentName}}] backup failed",
+					Body:     "{{.Error}}",
+				}))
+				Expect(backupConfig.PagerDuty).To(Equal(&config.PagerDuty{
+					RoutingKey: "pagerduty-routing-key",
+					APIURL:     "https://events.eu.pagerduty.com/v2/enqueue",
+					Severities: map[string]string{"timeout": "error"},
+				}))
+				Expect(backupConfig.DeadMansSwitch).To(Equal(&config.DeadMansSwitch{
+					MaxAgeSeconds:        93600,
+					CheckIntervalSeconds: 300,
+					HeartbeatURL:         "https://hc-ping.com/check-uuid",
+					StatePath:            "/var/vcap/store/service-backup/last_success.json",
+				}))
+				Expect(backupConfig.AlertRateLimit).To(Equal(&config.AlertRateLimit{
+					RenotifyIntervalSeconds: 21600,
+					DigestTime:              "09:00",
+				}))
+				Expect(backupConfig.ChildOutput).To(Equal(&config.ChildOutput{
+					TailLines:            50,
+					TailBytes:            8192,
+					RedactPatterns:       []string{`license=(\w+)`},
+					Stream:               true,
+					StreamLinesPerSecond: 20,
+					MaxBufferedBytes:     65536,
+				}))
+				Expect(backupConfig.AlertSeverities).To(Equal(map[string]string{"partial": "critical", "cleanup": "info"}))
+				Expect(backupConfig.Splay).To(Equal(&config.Splay{MaxRandomSeconds: 300, MaxOffsetSeconds: 600}))
+				Expect(backupConfig.CatchUp).To(Equal(&config.CatchUp{
+					StatePath:          "/var/vcap/store/service-backup/schedule.json",
+					Policy:             "delayed",
+					DelaySeconds:       600,
+					MinIntervalSeconds: 7200,
+				}))
+				Expect(backupConfig.BlackoutWindows).To(Equal([]config.BlackoutWindow{
+					{Name: "peak", Schedule: "0 0 18 * * 1-5", DurationSeconds: 14400, Action: "defer"},
+					{Name: "freeze", From: "2026-12-24T00:00:00Z", To: "2026-12-27T00:00:00Z"},
+				}))
+				Expect(backupConfig.Shutdown).To(Equal(&config.Shutdown{DrainSeconds: 900, KillAfterSeconds: 30}))
+				Expect(backupConfig.LeaderElection).To(Equal(&config.LeaderElection{
+					Destination: "s3_destination",
+					Key:         "leases/leader.json",
+					Holder:      "node-0",
+					TTLSeconds:  7200,
+				}))
 			})
 		})
 
@@ -128,6 +236,7 @@ var _ = Describe("Parse", func() {
 				Expect(backupConfig.ServiceIdentifierExecutable).To(Equal(""))
 				Expect(backupConfig.AwsCliPath).To(Equal("path/to/aws_cli"))
 				Expect(backupConfig.Alerts).To(BeNil())
+				Expect(backupConfig.ProcessPriority).To(BeNil())
 			})
 		})
 
@@ -0,0 +1,48 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package config
+
+// secretDestinationKeys are the destination config keys that hold
+// credentials.
+var secretDestinationKeys = []string{"secret_access_key", "storage_access_key", "key"}
+
+// Secrets returns every credential in the config, so that it can be redacted
+// from output that might echo it.
+func (b BackupConfig) Secrets() []string {
+	var secrets []string
+	add := func(values ...string) {
+		for _, value := range values {
+			if value != "" {
+				secrets = append(secrets, value)
+			}
+		}
+	}
+
+	for _, destination := range b.Destinations {
+		for _, key := range secretDestinationKeys {
+			if value, ok := destination.Config[key].(string); ok {
+				add(value)
+			}
+		}
+	}
+	if b.Alerts != nil {
+		add(b.Alerts.Config.CloudController.Password, b.Alerts.Config.Notifications.ClientSecret)
+	}
+	if b.ControlAPI != nil {
+		add(b.ControlAPI.Token)
+	}
+	for _, webhook := range b.Webhooks {
+		add(webhook.Secret)
+	}
+	if b.Email != nil {
+		add(b.Email.Password)
+	}
+	if b.PagerDuty != nil {
+		add(b.PagerDuty.RoutingKey)
+	}
+	return secrets
+}
@@ -0,0 +1,43 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package config_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	alerts "github.com/pivotal-cf/service-alerts-client/client"
+	"github.com/pivotal-cf/service-backup/config"
+)
+
+var _ = Describe("Secrets", func() {
+	It("returns every credential in the config", func() {
+		backupConfig := config.BackupConfig{
+			Destinations: []config.Destination{
+				{Type: "s3", Config: map[string]interface{}{"access_key_id": "id", "secret_access_key": "s3-secret"}},
+				{Type: "azure", Config: map[string]interface{}{"storage_access_key": "azure-secret"}},
+				{Type: "scp", Config: map[string]interface{}{"key": "ssh-key", "port": 22}},
+			},
+			Alerts: &config.Alerts{Config: alerts.Config{
+				CloudController: alerts.CloudController{Password: "cf-password"},
+				Notifications:   alerts.Notifications{ClientSecret: "client-secret"},
+			}},
+			ControlAPI: &config.ControlAPI{Token: "control-token"},
+			Webhooks:   []config.Webhook{{Secret: "webhook-secret"}, {}},
+			Email:      &config.Email{Password: "smtp-password"},
+			PagerDuty:  &config.PagerDuty{RoutingKey: "routing-key"},
+		}
+
+		Expect(backupConfig.Secrets()).To(ConsistOf(
+			"s3-secret", "azure-secret", "ssh-key", "cf-password", "client-secret",
+			"control-token", "webhook-secret", "smtp-password", "routing-key",
+		))
+	})
+
+	It("returns nothing when there are no credentials", func() {
+		Expect(config.BackupConfig{}.Secrets()).To(BeEmpty())
+	})
+})
@@ -0,0 +1,81 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package control
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+const clientTimeout = 5 * time.Second
+
+// Client calls the control API of a running daemon.
+type Client struct {
+	baseURL string
+	token   string
+	client  *http.Client
+}
+
+// NewClient builds a client for the API listening on the Unix socket at
+// socketPath if it is set, otherwise on the TCP address.
+func NewClient(socketPath, address, token string) *Client {
+	if socketPath == "" {
+		return &Client{
+			baseURL: "http://" + address,
+			token:   token,
+			client:  &http.Client{Timeout: clientTimeout},
+		}
+	}
+
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", socketPath)
+		},
+	}
+	return &Client{
+		baseURL: "http://service-backup",
+		token:   token,
+		client:  &http.Client{Timeout: clientTimeout, Transport: transport},
+	}
+}
+
+// Status returns the status of the daemon.
+func (c *Client) Status(ctx context.Context) (StatusResponse, error) {
+	var status StatusResponse
+	request, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/status", nil)
+	if err != nil {
+		return status, err
+	}
+	request.Header.Set("Authorization", "Bearer "+c.token)
+
+	response, err := c.client.Do(request)
+	if err != nil {
+		return status, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		body, _ := io.ReadAll(io.LimitReader(response.Body, 4096))
+		if json.Unmarshal(body, &apiErr) != nil || apiErr.Error == "" {
+			apiErr.Error = string(body)
+		}
+		return status, fmt.Errorf("control API returned %s: %s", response.Status, apiErr.Error)
+	}
+	if err := json.NewDecoder(response.Body).Decode(&status); err != nil {
+		return status, fmt.Errorf("error decoding status: %s", err)
+	}
+	return status, nil
+}
@@ -0,0 +1,65 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package control_test
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"time"
+
+	"code.cloudfoundry.org/lager/v3"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-cf/service-backup/control"
+	"github.com/pivotal-cf/service-backup/executor"
+)
+
+var _ = Describe("Client", func() {
+	var (
+		socketPath string
+		listener   net.Listener
+		status     *control.Status
+		nextRun    = time.Date(2024, 1, 2, 3, 0, 0, 0, time.UTC)
+	)
+
+	BeforeEach(func() {
+		socketPath = filepath.Join(GinkgoT().TempDir(), "control.sock")
+		var err error
+		listener, err = control.Listen(socketPath, "")
+		Expect(err).NotTo(HaveOccurred())
+
+		status = control.NewStatus()
+		server := control.NewServer("secret", &fakeScheduler{nextRun: nextRun}, &fakeCanceller{}, status, lager.NewLogger("control"))
+		go server.Serve(listener)
+	})
+
+	AfterEach(func() {
+		listener.Close()
+	})
+
+	It("gets the status of the daemon over its socket", func() {
+		status.RunStarted(executor.RunReport{BackupGUID: "running-guid"})
+
+		response, err := control.NewClient(socketPath, "", "secret").Status(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(*response.NextRun).To(BeTemporally("==", nextRun))
+		Expect(response.InProgress).To(BeTrue())
+		Expect(response.CurrentRuns[0].BackupGUID).To(Equal("running-guid"))
+	})
+
+	It("returns the error the API responds with", func() {
+		_, err := control.NewClient(socketPath, "", "wrong").Status(context.Background())
+		Expect(err).To(MatchError("control API returned 401 Unauthorized: invalid or missing token"))
+	})
+
+	It("returns an error when the daemon is not running", func() {
+		_, err := control.NewClient(filepath.Join(GinkgoT().TempDir(), "missing.sock"), "", "secret").Status(context.Background())
+		Expect(err).To(HaveOccurred())
+	})
+})
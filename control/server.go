@@ -0,0 +1,197 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package control
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/lager/v3"
+	"github.com/pivotal-cf/service-backup/executor"
+)
+
+type Scheduler interface {
+	Trigger() error
+	Pause()
+	Resume()
+	Paused() bool
+	NextRun() time.Time
+	Blackout(at time.Time) (window string, end time.Time, in bool)
+}
+
+type Canceller interface {
+	Cancel() bool
+}
+
+// Server is a small HTTP API for controlling a running daemon. Every request
+// must carry the configured token as a bearer token.
+type Server struct {
+	token     string
+	scheduler Scheduler
+	canceller Canceller
+	status    *Status
+	logger    lager.Logger
+}
+
+func NewServer(token string, scheduler Scheduler, canceller Canceller, status *Status, logger lager.Logger) *Server {
+	return &Server{
+		token:     token,
+		scheduler: scheduler,
+		canceller: canceller,
+		status:    status,
+		logger:    logger.Session("control"),
+	}
+}
+
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /status", s.getStatus)
+	mux.HandleFunc("POST /trigger", s.trigger)
+	mux.HandleFunc("POST /pause", s.pause)
+	mux.HandleFunc("POST /resume", s.resume)
+	mux.HandleFunc("POST /cancel", s.cancel)
+	return s.authenticate(mux)
+}
+
+// Listen opens a listener for the API: a Unix socket if socketPath is set,
+// otherwise a TCP listener on address.
+func Listen(socketPath, address string) (net.Listener, error) {
+	if socketPath == "" {
+		if !isLoopback(address) {
+			return nil, fmt.Errorf("control API address must be on localhost, got %s", address)
+		}
+		return net.Listen("tcp", address)
+	}
+
+	if err := os.Remove(socketPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(socketPath, 0600); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+func isLoopback(address string) bool {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func (s *Server) Serve(listener net.Listener) error {
+	s.logger.Info("Serving control API", lager.Data{"address": listener.Addr().String()})
+	return http.Serve(listener, s.Handler())
+}
+
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !found || subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			writeError(w, http.StatusUnauthorized, "invalid or missing token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) getStatus(w http.ResponseWriter, r *http.Request) {
+	response := StatusResponse{Paused: s.scheduler.Paused()}
+	if next := s.scheduler.NextRun(); !next.IsZero() {
+		response.NextRun = &next
+	}
+	for _, report := range s.status.CurrentRuns() {
+		response.CurrentRuns = append(response.CurrentRuns, newRun(report))
+	}
+	response.InProgress = len(response.CurrentRuns) > 0
+	if report, ok := s.status.LastRun(); ok {
+		lastRun := newRun(report)
+		response.LastRun = &lastRun
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// trigger runs a backup now, unless it is during a blackout window and the
+// request does not override it with override_blackout=true, or the executor
+// refuses to start one.
+func (s *Server) trigger(w http.ResponseWriter, r *http.Request) {
+	override, _ := strconv.ParseBool(r.URL.Query().Get("override_blackout"))
+	if window, end, in := s.scheduler.Blackout(time.Now()); in {
+		data := lager.Data{"window": window, "window_end": end.UTC()}
+		if !override {
+			s.logger.Info("Backup trigger refused during blackout window", data)
+			writeError(w, http.StatusConflict, fmt.Sprintf(
+				"blackout window %s until %s, trigger with override_blackout=true to back up anyway",
+				window, end.UTC().Format(time.RFC3339),
+			))
+			return
+		}
+		s.logger.Info("Backup triggered, overriding blackout window", data)
+	} else {
+		s.logger.Info("Backup triggered")
+	}
+	if err := s.scheduler.Trigger(); err != nil {
+		s.logger.Info("Backup trigger refused", lager.Data{"reason": err.Error()})
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, executor.ErrBackupInProgress):
+			status = http.StatusConflict
+		case errors.Is(err, executor.ErrShuttingDown):
+			status = http.StatusServiceUnavailable
+		}
+		writeError(w, status, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string]string{"message": "backup triggered"})
+}
+
+func (s *Server) pause(w http.ResponseWriter, r *http.Request) {
+	s.scheduler.Pause()
+	writeJSON(w, http.StatusOK, map[string]string{"message": "schedule paused"})
+}
+
+func (s *Server) resume(w http.ResponseWriter, r *http.Request) {
+	s.scheduler.Resume()
+	writeJSON(w, http.StatusOK, map[string]string{"message": "schedule resumed"})
+}
+
+func (s *Server) cancel(w http.ResponseWriter, r *http.Request) {
+	if !s.canceller.Cancel() {
+		writeError(w, http.StatusConflict, "no backup in progress")
+		return
+	}
+	s.logger.Info("Backup cancelled")
+	writeJSON(w, http.StatusOK, map[string]string{"message": "backup cancelled"})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
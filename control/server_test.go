@@ -0,0 +1,231 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package control_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"code.cloudfoundry.org/lager/v3"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-cf/service-backup/control"
+	"github.com/pivotal-cf/service-backup/executor"
+)
+
+var _ = Describe("Server", func() {
+	var (
+		scheduler *fakeScheduler
+		canceller *fakeCanceller
+		status    *control.Status
+		handler   http.Handler
+	)
+
+	request := func(method, path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	BeforeEach(func() {
+		scheduler = &fakeScheduler{nextRun: time.Date(2024, 1, 2, 3, 0, 0, 0, time.UTC)}
+		canceller = &fakeCanceller{}
+		status = control.NewStatus()
+		logger := lager.NewLogger("control")
+		logger.RegisterSink(lager.NewWriterSink(GinkgoWriter, lager.DEBUG))
+		handler = control.NewServer("secret", scheduler, canceller, status, logger).Handler()
+	})
+
+	Describe("authentication", func() {
+		It("rejects requests without a token", func() {
+			Expect(request("GET", "/status", "").Code).To(Equal(http.StatusUnauthorized))
+		})
+
+		It("rejects requests with the wrong token", func() {
+			Expect(request("GET", "/status", "wrong").Code).To(Equal(http.StatusUnauthorized))
+		})
+	})
+
+	Describe("GET /status", func() {
+		var response control.StatusResponse
+
+		JustBeforeEach(func() {
+			recorder := request("GET", "/status", "secret")
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			response = control.StatusResponse{}
+			Expect(json.Unmarshal(recorder.Body.Bytes(), &response)).To(Succeed())
+		})
+
+		Context("before any backup has run", func() {
+			It("reports the schedule", func() {
+				Expect(response.InProgress).To(BeFalse())
+				Expect(response.Paused).To(BeFalse())
+				Expect(*response.NextRun).To(BeTemporally("==", scheduler.nextRun))
+				Expect(response.LastRun).To(BeNil())
+			})
+		})
+
+		Context("while a backup is running", func() {
+			BeforeEach(func() {
+				status.RunStarted(executor.RunReport{BackupGUID: "running-guid"})
+			})
+
+			It("reports the run in progress", func() {
+				Expect(response.InProgress).To(BeTrue())
+				Expect(response.CurrentRuns).To(HaveLen(1))
+				Expect(response.CurrentRuns[0].BackupGUID).To(Equal("running-guid"))
+			})
+		})
+
+		Context("after a backup has failed", func() {
+			BeforeEach(func() {
+				started := time.Now()
+				status.RunStarted(executor.RunReport{BackupGUID: "failed-guid"})
+				status.RunFinished(executor.RunReport{
+					BackupGUID:        "failed-guid",
+					ServiceInstanceID: "instance",
+					StartedAt:         started,
+					FinishedAt:        started.Add(time.Minute),
+					FailedPhase:       executor.PhaseUpload,
+					Err:               errors.New("upload failed"),
+					Destinations:      []executor.DestinationResult{{Name: "s3", Err: errors.New("upload failed")}},
+				})
+			})
+
+			It("reports the last run", func() {
+				Expect(response.InProgress).To(BeFalse())
+				Expect(response.LastRun.BackupGUID).To(Equal("failed-guid"))
+				Expect(response.LastRun.ServiceInstanceID).To(Equal("instance"))
+				Expect(response.LastRun.Succeeded).To(BeFalse())
+				Expect(response.LastRun.FailedPhase).To(Equal("upload"))
+				Expect(response.LastRun.Error).To(Equal("upload failed"))
+				Expect(response.LastRun.Destinations).To(Equal([]control.Destination{{Name: "s3", Error: "upload failed"}}))
+			})
+		})
+	})
+
+	Describe("POST /trigger", func() {
+		It("runs a backup", func() {
+			Expect(request("POST", "/trigger", "secret").Code).To(Equal(http.StatusAccepted))
+			Eventually(scheduler.runs.Load).Should(Equal(int32(1)))
+		})
+
+		It("conflicts when a backup is already in progress", func() {
+			scheduler.triggerErr = executor.ErrBackupInProgress
+
+			response := request("POST", "/trigger", "secret")
+
+			Expect(response.Code).To(Equal(http.StatusConflict))
+			Expect(response.Body.String()).To(ContainSubstring("backup currently in progress"))
+		})
+
+		It("is unavailable while shutting down", func() {
+			scheduler.triggerErr = executor.ErrShuttingDown
+
+			response := request("POST", "/trigger", "secret")
+
+			Expect(response.Code).To(Equal(http.StatusServiceUnavailable))
+			Expect(response.Body.String()).To(ContainSubstring("shutting down, not starting backup"))
+		})
+
+		Context("during a blackout window", func() {
+			BeforeEach(func() {
+				scheduler.blackout = "peak"
+				scheduler.blackoutEnd = time.Date(2024, 1, 2, 22, 0, 0, 0, time.UTC)
+			})
+
+			It("refuses to run a backup", func() {
+				response := request("POST", "/trigger", "secret")
+
+				Expect(response.Code).To(Equal(http.StatusConflict))
+				Expect(response.Body.String()).To(ContainSubstring("blackout window peak until 2024-01-02T22:00:00Z"))
+				Consistently(scheduler.runs.Load).Should(BeZero())
+			})
+
+			It("runs a backup when told to override the window", func() {
+				Expect(request("POST", "/trigger?override_blackout=true", "secret").Code).To(Equal(http.StatusAccepted))
+				Eventually(scheduler.runs.Load).Should(Equal(int32(1)))
+			})
+		})
+	})
+
+	Describe("POST /pause and /resume", func() {
+		It("pauses and resumes the schedule", func() {
+			Expect(request("POST", "/pause", "secret").Code).To(Equal(http.StatusOK))
+			Expect(scheduler.Paused()).To(BeTrue())
+
+			Expect(request("POST", "/resume", "secret").Code).To(Equal(http.StatusOK))
+			Expect(scheduler.Paused()).To(BeFalse())
+		})
+	})
+
+	Describe("POST /cancel", func() {
+		It("cancels the backup in progress", func() {
+			canceller.cancelled = true
+			Expect(request("POST", "/cancel", "secret").Code).To(Equal(http.StatusOK))
+		})
+
+		It("conflicts when there is nothing to cancel", func() {
+			Expect(request("POST", "/cancel", "secret").Code).To(Equal(http.StatusConflict))
+		})
+	})
+
+	Describe("Listen", func() {
+		It("listens on a Unix socket", func() {
+			socketPath := filepath.Join(GinkgoT().TempDir(), "control.sock")
+			listener, err := control.Listen(socketPath, "")
+			Expect(err).NotTo(HaveOccurred())
+			defer listener.Close()
+			Expect(socketPath).To(BeAnExistingFile())
+		})
+
+		It("refuses to listen on a non-loopback address", func() {
+			_, err := control.Listen("", "0.0.0.0:9400")
+			Expect(err).To(MatchError("control API address must be on localhost, got 0.0.0.0:9400"))
+		})
+	})
+})
+
+type fakeScheduler struct {
+	runs        atomic.Int32
+	triggerErr  error
+	paused      bool
+	nextRun     time.Time
+	blackout    string
+	blackoutEnd time.Time
+}
+
+func (f *fakeScheduler) Trigger() error {
+	if f.triggerErr != nil {
+		return f.triggerErr
+	}
+	f.runs.Add(1)
+	return nil
+}
+
+func (f *fakeScheduler) Pause()             { f.paused = true }
+func (f *fakeScheduler) Resume()            { f.paused = false }
+func (f *fakeScheduler) Paused() bool       { return f.paused }
+func (f *fakeScheduler) NextRun() time.Time { return f.nextRun }
+func (f *fakeScheduler) Blackout(time.Time) (string, time.Time, bool) {
+	return f.blackout, f.blackoutEnd, f.blackout != ""
+}
+
+type fakeCanceller struct {
+	cancelled bool
+}
+
+func (f *fakeCanceller) Cancel() bool { return f.cancelled }
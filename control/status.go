@@ -0,0 +1,120 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package control
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pivotal-cf/service-backup/executor"
+)
+
+type Run struct {
+	BackupGUID        string             `json:"backup_guid"`
+	ServiceInstanceID string             `json:"service_instance_id,omitempty"`
+	StartedAt         time.Time          `json:"started_at"`
+	FinishedAt        *time.Time         `json:"finished_at,omitempty"`
+	Succeeded         bool               `json:"succeeded"`
+	Cancelled         bool               `json:"cancelled,omitempty"`
+	FailedPhase       string             `json:"failed_phase,omitempty"`
+	Error             string             `json:"error,omitempty"`
+	CleanupError      string             `json:"cleanup_error,omitempty"`
+	SizeInBytes       int64              `json:"size_in_bytes,omitempty"`
+	DurationsSeconds  map[string]float64 `json:"durations_in_seconds,omitempty"`
+	Destinations      []Destination      `json:"destinations,omitempty"`
+}
+
+type Destination struct {
+	Name  string `json:"name"`
+	Error string `json:"error,omitempty"`
+}
+
+type StatusResponse struct {
+	InProgress  bool       `json:"in_progress"`
+	Paused      bool       `json:"paused"`
+	NextRun     *time.Time `json:"next_run,omitempty"`
+	CurrentRuns []Run      `json:"current_runs,omitempty"`
+	LastRun     *Run       `json:"last_run,omitempty"`
+}
+
+func newRun(report executor.RunReport) Run {
+	run := Run{
+		BackupGUID:        report.BackupGUID,
+		ServiceInstanceID: report.ServiceInstanceID,
+		StartedAt:         report.StartedAt,
+		Succeeded:         report.Succeeded(),
+		Cancelled:         report.Cancelled,
+		FailedPhase:       string(report.FailedPhase),
+		SizeInBytes:       report.SizeInBytes,
+		DurationsSeconds:  map[string]float64{},
+	}
+	if !report.FinishedAt.IsZero() {
+		finishedAt := report.FinishedAt
+		run.FinishedAt = &finishedAt
+	}
+	if report.Err != nil {
+		run.Error = report.Err.Error()
+	}
+	if report.CleanupErr != nil {
+		run.CleanupError = report.CleanupErr.Error()
+	}
+	for phase, duration := range report.Durations {
+		run.DurationsSeconds[string(phase)] = duration.Seconds()
+	}
+	for _, d := range report.Destinations {
+		destination := Destination{Name: d.Name}
+		if d.Err != nil {
+			destination.Error = d.Err.Error()
+		}
+		run.Destinations = append(run.Destinations, destination)
+	}
+	return run
+}
+
+// Status keeps track of runs in progress and the outcome of the last run. It
+// is an executor.Observer.
+type Status struct {
+	lock    sync.Mutex
+	current map[string]executor.RunReport
+	last    *executor.RunReport
+}
+
+func NewStatus() *Status {
+	return &Status{current: map[string]executor.RunReport{}}
+}
+
+func (s *Status) RunStarted(report executor.RunReport) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.current[report.BackupGUID] = report
+}
+
+func (s *Status) RunFinished(report executor.RunReport) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.current, report.BackupGUID)
+	s.last = &report
+}
+
+func (s *Status) LastRun() (executor.RunReport, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.last == nil {
+		return executor.RunReport{}, false
+	}
+	return *s.last, true
+}
+
+func (s *Status) CurrentRuns() []executor.RunReport {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	runs := make([]executor.RunReport, 0, len(s.current))
+	for _, report := range s.current {
+		runs = append(runs, report)
+	}
+	return runs
+}
@@ -6,7 +6,11 @@
 
 package executor
 
-import "code.cloudfoundry.org/lager/v3"
+import (
+	"time"
+
+	"code.cloudfoundry.org/lager/v3"
+)
 
 type dummyExecutor struct {
 	logger lager.Logger
@@ -24,3 +28,12 @@ func (d *dummyExecutor) Execute() error {
 	d.logger.Info("Backups Disabled")
 	return nil
 }
+
+func (d *dummyExecutor) Run() RunReport {
+	now := time.Now()
+	return RunReport{StartedAt: now, FinishedAt: now, Err: d.Execute(), Skipped: true}
+}
+
+func (d *dummyExecutor) Cancel() bool {
+	return false
+}
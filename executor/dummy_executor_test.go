@@ -42,4 +42,13 @@ var _ = Describe("DummyExecutor", func() {
 			Expect(log).To(gbytes.Say("Backups Disabled"))
 		})
 	})
+
+	Describe("Run", func() {
+		It("reports a successful run", func() {
+			report := exec.Run()
+			Expect(report.Succeeded()).To(BeTrue())
+			Expect(report.Skipped).To(BeTrue())
+			Expect(log).To(gbytes.Say("Backups Disabled"))
+		})
+	})
 })
@@ -7,22 +7,29 @@
 package executor
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"code.cloudfoundry.org/lager/v3"
 	"github.com/pivotal-cf/service-backup/process"
+	"github.com/pivotal-cf/service-backup/tracing"
 	"github.com/pivotal-cf/service-backup/upload"
 	"github.com/satori/go.uuid"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
 )
 
 type Executor interface {
 	Execute() error
+	Run() RunReport
+	Cancel() bool
 }
 
 type executor struct {
@@ -34,10 +41,23 @@ type executor struct {
 	serviceIdentifierCmd   string
 	exitIfBackupInProgress bool
 	backupInProgress       bool
+	runsInProgress         int
+	drained                chan struct{}
 	logger                 lager.Logger
 	processManager         process.ProcessManager
 	execCommand            CmdFunc
 	dirSize                DirSizeFunc
+	observers              []Observer
+	cancels                map[string]context.CancelFunc
+	tracer                 trace.Tracer
+	outputTail             OutputTail
+	streamOutput           bool
+}
+
+// outputStreamer is implemented by process managers that can log the output
+// of a process as it runs.
+type outputStreamer interface {
+	StartStreaming(*exec.Cmd, process.Stream) ([]byte, error)
 }
 
 type DirSizeFunc func(string) (int64, error)
@@ -68,6 +88,9 @@ func NewExecutor(
 		processManager:         processManager,
 		execCommand:            exec.Command,
 		dirSize:                calculateDirSize,
+		cancels:                map[string]context.CancelFunc{},
+		tracer:                 noop.NewTracerProvider().Tracer(tracing.InstrumentationName),
+		outputTail:             defaultOutputTail(),
 	}
 
 	for _, opt := range options {
@@ -82,6 +105,10 @@ type ServiceInstanceError struct {
 	ServiceInstanceID string
 }
 
+func (e ServiceInstanceError) Unwrap() error {
+	return e.error
+}
+
 func (e *executor) backupCanBeStarted() bool {
 	e.Lock()
 	defer e.Unlock()
@@ -100,9 +127,78 @@ func (e *executor) doneBackup() {
 }
 
 func (e *executor) Execute() error {
-	sessionLogger := e.logger.WithData(lager.Data{"backup_guid": fmt.Sprint(uuid.NewV4())})
+	return e.Run().Err
+}
+
+// Run performs a backup and reports how it went. The report's Err is the
+// error Execute would have returned.
+func (e *executor) Run() RunReport {
+	return e.run(context.Background())
+}
+
+// RunDestinations performs a backup as Run does, but only uploads it to the
+// named destinations.
+func (e *executor) RunDestinations(destinations []string) RunReport {
+	return e.run(upload.WithDestinations(context.Background(), destinations))
+}
+
+var (
+	// ErrShuttingDown is returned by Start once the executor is draining.
+	ErrShuttingDown = errors.New("shutting down, not starting backup")
+	// ErrBackupInProgress is returned by Start when a backup is in progress
+	// and backups must not overlap.
+	ErrBackupInProgress = errors.New("backup currently in progress")
+)
+
+// Start claims a run at once, so that a caller can learn whether it will
+// happen before the slow work of backing up, and returns the function that
+// performs it, which must be called exactly once.
+func (e *executor) Start() (func() RunReport, error) {
+	if !e.runCanBeStarted() {
+		return nil, ErrShuttingDown
+	}
+	if !e.backupCanBeStarted() {
+		e.runDone()
+		return nil, ErrBackupInProgress
+	}
+	return func() RunReport {
+		defer e.runDone()
+		defer e.doneBackup()
+		return e.runClaimed(context.Background(), true)
+	}, nil
+}
+
+func (e *executor) run(runCtx context.Context) RunReport {
+	if !e.runCanBeStarted() {
+		e.logger.Info("Shutting down, not starting backup")
+		now := time.Now()
+		return RunReport{StartedAt: now, FinishedAt: now, Skipped: true}
+	}
+	defer e.runDone()
+	return e.runClaimed(runCtx, false)
+}
+
+// runClaimed performs a run once runCanBeStarted has allowed it, and once
+// backupCanBeStarted has too if backupClaimed.
+func (e *executor) runClaimed(runCtx context.Context, backupClaimed bool) RunReport {
+	report := RunReport{
+		BackupGUID: fmt.Sprint(uuid.NewV4()),
+		StartedAt:  time.Now(),
+		Durations:  map[Phase]time.Duration{},
+	}
+	sessionLogger := e.logger.WithData(lager.Data{"backup_guid": report.BackupGUID})
 
+	traceCtx, span := e.tracer.Start(runCtx, "backup-run", trace.WithAttributes(
+		tracing.BackupGUIDKey.String(report.BackupGUID),
+	))
+
+	_, identifySpan := e.tracer.Start(traceCtx, string(PhaseIdentify))
+	identifyStart := time.Now()
 	serviceInstanceID := e.identifyService(sessionLogger)
+	report.Durations[PhaseIdentify] = time.Since(identifyStart)
+	report.ServiceInstanceID = serviceInstanceID
+	identifySpan.End()
+	span.SetAttributes(tracing.ServiceInstanceIDKey.String(serviceInstanceID))
 	if serviceInstanceID != "" {
 		sessionLogger = sessionLogger.Session(
 			"WithIdentifier",
@@ -110,37 +206,151 @@ func (e *executor) Execute() error {
 		)
 	}
 
-	if !e.backupCanBeStarted() {
-		errMsg := "Backup currently in progress, exiting. Another backup will not be able to start until this is completed."
-		err := errors.New(errMsg)
-		sessionLogger.Error(errMsg, err)
-		return ServiceInstanceError{
-			error:             err,
-			ServiceInstanceID: serviceInstanceID,
+	if !backupClaimed {
+		if !e.backupCanBeStarted() {
+			errMsg := "Backup currently in progress, exiting. Another backup will not be able to start until this is completed."
+			err := errors.New(errMsg)
+			sessionLogger.Error(errMsg, err)
+			report.FailedPhase = PhaseStart
+			return e.finish(span, report, err)
 		}
+		defer e.doneBackup()
 	}
-	defer e.doneBackup()
 
-	if err := e.performBackup(sessionLogger); err != nil {
-		return ServiceInstanceError{
-			error:             err,
-			ServiceInstanceID: serviceInstanceID,
-		}
+	ctx, cancel := context.WithCancel(traceCtx)
+	e.trackRun(report.BackupGUID, cancel)
+	defer e.untrackRun(report.BackupGUID)
+
+	for _, o := range e.observers {
+		o.RunStarted(report)
 	}
 
-	if err := e.uploadBackup(sessionLogger); err != nil {
-		return ServiceInstanceError{
-			error:             err,
-			ServiceInstanceID: serviceInstanceID,
-		}
+	if err := e.runPhase(ctx, &report, PhaseBackup, func(ctx context.Context) error { return e.performBackup(ctx, sessionLogger) }); err != nil {
+		report.Cancelled = ctx.Err() != nil
+		return e.finish(span, report, err)
+	}
+
+	uploadErr := e.runPhase(ctx, &report, PhaseUpload, func(ctx context.Context) error { return e.uploadBackup(ctx, sessionLogger, &report) })
+	report.Destinations = destinationResults(ctx, e.uploader, uploadErr)
+	if uploadErr != nil {
+		report.Cancelled = ctx.Err() != nil
+		return e.finish(span, report, uploadErr)
 	}
 
 	// Do not return error if cleanup command failed.
-	_ = e.performCleanup(sessionLogger)
+	report.CleanupErr = e.runPhase(ctx, &report, PhaseCleanup, func(ctx context.Context) error { return e.performCleanup(ctx, sessionLogger) })
 
 	sessionLogger = e.logger
 
-	return nil
+	return e.finish(span, report, nil)
+}
+
+// Cancel cancels any runs that are in progress, terminating their child
+// processes and aborting their uploads. It reports whether there was anything
+// to cancel.
+func (e *executor) Cancel() bool {
+	e.Lock()
+	defer e.Unlock()
+
+	for _, cancel := range e.cancels {
+		cancel()
+	}
+	return len(e.cancels) > 0
+}
+
+// Drain refuses to start any more runs, and waits for those in progress to
+// finish or for ctx to be done. It reports whether they all finished.
+func (e *executor) Drain(ctx context.Context) bool {
+	e.Lock()
+	if e.drained == nil {
+		e.drained = make(chan struct{})
+		if e.runsInProgress == 0 {
+			close(e.drained)
+		}
+	}
+	drained := e.drained
+	e.Unlock()
+
+	select {
+	case <-drained:
+		return true
+	default:
+	}
+	select {
+	case <-drained:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (e *executor) runCanBeStarted() bool {
+	e.Lock()
+	defer e.Unlock()
+
+	if e.drained != nil {
+		return false
+	}
+	e.runsInProgress++
+	return true
+}
+
+func (e *executor) runDone() {
+	e.Lock()
+	defer e.Unlock()
+
+	e.runsInProgress--
+	if e.drained != nil && e.runsInProgress == 0 {
+		close(e.drained)
+	}
+}
+
+func (e *executor) trackRun(guid string, cancel context.CancelFunc) {
+	e.Lock()
+	defer e.Unlock()
+	e.cancels[guid] = cancel
+}
+
+func (e *executor) untrackRun(guid string) {
+	e.Lock()
+	defer e.Unlock()
+	e.cancels[guid]()
+	delete(e.cancels, guid)
+}
+
+func (e *executor) runPhase(ctx context.Context, report *RunReport, phase Phase, fn func(context.Context) error) error {
+	ctx, span := e.tracer.Start(ctx, string(phase))
+	start := time.Now()
+	err := fn(ctx)
+	report.Durations[phase] = time.Since(start)
+	tracing.End(span, err)
+	if err != nil && phase != PhaseCleanup {
+		report.FailedPhase = phase
+	}
+	return err
+}
+
+func (e *executor) finish(span trace.Span, report RunReport, err error) RunReport {
+	report.FinishedAt = time.Now()
+	if err != nil {
+		err = ServiceInstanceError{
+			error:             err,
+			ServiceInstanceID: report.ServiceInstanceID,
+		}
+	}
+	report.Err = err
+	var commandErr CommandError
+	if errors.As(err, &commandErr) {
+		report.OutputTail = commandErr.OutputTail
+	}
+
+	span.SetAttributes(tracing.SizeInBytesKey.Int64(report.SizeInBytes))
+	tracing.End(span, err)
+
+	for _, o := range e.observers {
+		o.RunFinished(report)
+	}
+	return report
 }
 
 func (e *executor) identifyService(sessionLogger lager.Logger) string {
@@ -148,35 +358,60 @@ func (e *executor) identifyService(sessionLogger lager.Logger) string {
 		return ""
 	}
 
-	args := strings.Split(e.serviceIdentifierCmd, " ")
-
-	_, err := os.Stat(args[0])
+	serviceInstanceID, err := identifyService(e.execCommand, e.serviceIdentifierCmd)
 	if err != nil {
-		sessionLogger.Error("Service identifier command not found", err)
+		sessionLogger.Error(err.message, err.err)
 		return ""
 	}
+	return serviceInstanceID
+}
+
+// IdentifyService runs the service identifier command and returns the
+// service instance ID it prints.
+func IdentifyService(command string) (string, error) {
+	serviceInstanceID, err := identifyService(exec.Command, command)
+	if err != nil {
+		return "", err
+	}
+	return serviceInstanceID, nil
+}
+
+type identifyError struct {
+	message string
+	err     error
+}
 
-	cmd := e.execCommand(args[0], args[1:]...)
-	out, err := cmd.CombinedOutput()
+func (e *identifyError) Error() string {
+	return e.message + ": " + e.err.Error()
+}
+
+func (e *identifyError) Unwrap() error {
+	return e.err
+}
+
+func identifyService(execCommand CmdFunc, command string) (string, *identifyError) {
+	args := strings.Split(command, " ")
 
+	if _, err := os.Stat(args[0]); err != nil {
+		return "", &identifyError{message: "Service identifier command not found", err: err}
+	}
+
+	out, err := execCommand(args[0], args[1:]...).CombinedOutput()
 	if err != nil {
-		sessionLogger.Error("Service identifier command returned error", err)
-		return ""
+		return "", &identifyError{message: "Service identifier command returned error", err: err}
 	}
 
-	return strings.TrimSpace(string(out))
+	return strings.TrimSpace(string(out)), nil
 }
 
-func (e *executor) performBackup(sessionLogger lager.Logger) error {
+func (e *executor) performBackup(ctx context.Context, sessionLogger lager.Logger) error {
 	if e.backupCreatorCmd == "" {
 		sessionLogger.Info("source_executable not provided, skipping performing of backup")
 		return nil
 	}
 	sessionLogger.Info("Perform backup started")
-	args := strings.Split(e.backupCreatorCmd, " ")
-	cmd := exec.Command(args[0], args[1:]...)
 
-	_, err := e.processManager.Start(cmd)
+	err := e.runCommand(ctx, sessionLogger, PhaseBackup, e.backupCreatorCmd)
 	if err != nil {
 		sessionLogger.Error("Perform backup completed with error", err)
 		return err
@@ -186,18 +421,14 @@ func (e *executor) performBackup(sessionLogger lager.Logger) error {
 	return nil
 }
 
-func (e *executor) performCleanup(sessionLogger lager.Logger) error {
+func (e *executor) performCleanup(ctx context.Context, sessionLogger lager.Logger) error {
 	if e.cleanupCmd == "" {
 		sessionLogger.Info("Cleanup command not provided")
 		return nil
 	}
 	sessionLogger.Info("Cleanup started")
 
-	args := strings.Split(e.cleanupCmd, " ")
-	cmd := exec.Command(args[0], args[1:]...)
-
-	_, err := e.processManager.Start(cmd)
-
+	err := e.runCommand(ctx, sessionLogger, PhaseCleanup, e.cleanupCmd)
 	if err != nil {
 		sessionLogger.Error("Cleanup completed with error", err)
 		return err
@@ -207,19 +438,64 @@ func (e *executor) performCleanup(sessionLogger lager.Logger) error {
 	return nil
 }
 
-func (e *executor) uploadBackup(sessionLogger lager.Logger) error {
+// runCommand runs command through the process manager. Its output is
+// streamed into the log as it runs if possible, or else logged at debug level
+// once it exits. If it fails, the tail of its output is attached to the error.
+func (e *executor) runCommand(ctx context.Context, sessionLogger lager.Logger, phase Phase, command string) error {
+	cmd := commandWithContext(ctx, command)
+
+	var (
+		output []byte
+		err    error
+	)
+	if streamer, ok := e.processManager.(outputStreamer); ok && e.streamOutput {
+		output, err = streamer.StartStreaming(cmd, process.Stream{
+			Logger: sessionLogger.WithData(lager.Data{"phase": phase}),
+			Redact: e.outputTail.Redactor.Redact,
+		})
+	} else {
+		output, err = e.processManager.Start(cmd)
+		if len(output) > 0 {
+			sessionLogger.Debug("Command output", lager.Data{
+				"command": strings.Split(command, " ")[0],
+				"output":  e.outputTail.Redactor.Redact(string(output)),
+			})
+		}
+	}
+	if err != nil && len(output) > 0 {
+		return CommandError{Err: err, OutputTail: e.outputTail.Of(output)}
+	}
+	return err
+}
+
+// commandWithContext builds a command that is sent SIGTERM, rather than
+// killed outright, if ctx is cancelled while it is running.
+func commandWithContext(ctx context.Context, command string) *exec.Cmd {
+	args := strings.Split(command, " ")
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(syscall.SIGTERM)
+	}
+	return cmd
+}
+
+func (e *executor) uploadBackup(ctx context.Context, sessionLogger lager.Logger, report *RunReport) error {
 	sessionLogger.Info("Upload backup started")
 
 	startTime := time.Now()
-	err := e.uploader.Upload(e.sourceFolder, sessionLogger, e.processManager)
+	err := e.uploader.Upload(ctx, e.sourceFolder, sessionLogger, e.processManager)
 	duration := time.Since(startTime)
 
+	// Destinations that succeeded uploaded the whole backup, even when others
+	// failed.
+	size, _ := e.dirSize(e.sourceFolder)
+	report.SizeInBytes = size
+
 	if err != nil {
 		sessionLogger.Error("Upload backup completed with error", err)
 		return err
 	}
 
-	size, _ := e.dirSize(e.sourceFolder)
 	sessionLogger.Info("Upload backup completed successfully", lager.Data{
 		"duration_in_seconds": duration.Seconds(),
 		"size_in_bytes":       size,
@@ -7,10 +7,12 @@
 package executor_test
 
 import (
+	"context"
 	"errors"
 	"os/exec"
 	"strings"
 	"sync"
+	"time"
 
 	"code.cloudfoundry.org/lager/v3"
 
@@ -22,6 +24,11 @@ import (
 	"github.com/pivotal-cf/service-backup/executor"
 	"github.com/pivotal-cf/service-backup/process"
 	processfakes "github.com/pivotal-cf/service-backup/process/fakes"
+	"github.com/pivotal-cf/service-backup/tracing"
+	"github.com/pivotal-cf/service-backup/upload"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 var _ = Describe("Executor", func() {
@@ -128,6 +135,24 @@ var _ = Describe("Executor", func() {
 			Expect(err).To(MatchError("any error"))
 		})
 
+		It("streams the output of the backup command into the log when asked to", func() {
+			backupExecutor = executor.NewExecutor(
+				uploader,
+				"source-folder",
+				"echo dumping password=hunter2",
+				"",
+				"",
+				exitIfBackupInProgress,
+				logger,
+				process.NewManager(),
+				executor.WithStreamedOutput(),
+			)
+
+			Expect(backupExecutor.Execute()).To(Succeed())
+			Expect(log).To(gbytes.Say(`"line":"dumping password=\[REDACTED\]","phase":"backup","stream":"stdout"`))
+			Expect(log.Contents()).NotTo(ContainSubstring("hunter2"))
+		})
+
 		Describe("failures backing up", func() {
 			var serviceIdentifierCmd string
 
@@ -331,6 +356,431 @@ var _ = Describe("Executor", func() {
 			})
 		})
 
+		Describe("observers", func() {
+			var observer *fakeObserver
+
+			BeforeEach(func() {
+				observer = new(fakeObserver)
+			})
+
+			JustBeforeEach(func() {
+				backupExecutor = executor.NewExecutor(
+					uploader,
+					"source-folder",
+					assetPath("fake-snapshotter"),
+					assetPath("fake-cleanup"),
+					"",
+					exitIfBackupInProgress,
+					logger,
+					processManager,
+					executor.WithCommandFunc(fakeExec),
+					executor.WithDirSizeFunc(func(string) (int64, error) { return 200, nil }),
+					executor.WithObserver(observer),
+				)
+
+				executeErr = backupExecutor.Execute()
+			})
+
+			Context("when the backup succeeds", func() {
+				It("reports the start and end of the run", func() {
+					Expect(observer.started).To(HaveLen(1))
+					Expect(observer.finished).To(HaveLen(1))
+
+					report := observer.finished[0]
+					Expect(report.BackupGUID).To(Equal(observer.started[0].BackupGUID))
+					Expect(report.Succeeded()).To(BeTrue())
+					Expect(report.SizeInBytes).To(Equal(int64(200)))
+					Expect(report.Durations).To(HaveKey(executor.PhaseBackup))
+					Expect(report.Durations).To(HaveKey(executor.PhaseUpload))
+					Expect(report.Durations).To(HaveKey(executor.PhaseCleanup))
+					Expect(report.Destinations).To(Equal([]executor.DestinationResult{{Name: "fake"}}))
+					Expect(report.FinishedAt).NotTo(BeTemporally("<", report.StartedAt))
+				})
+			})
+
+			It("returns the report observers are given from Run", func() {
+				report := backupExecutor.Run()
+				Expect(observer.finished).To(HaveLen(2))
+				Expect(report).To(Equal(observer.finished[1]))
+			})
+
+			It("uploads only to the destinations given to RunDestinations", func() {
+				var due []bool
+				uploader.uploadStub = func(ctx context.Context, _ string, _ lager.Logger) error {
+					due = append(due, upload.Due(ctx, "fake"))
+					return nil
+				}
+				runner := backupExecutor.(interface {
+					RunDestinations([]string) executor.RunReport
+				})
+
+				Expect(runner.RunDestinations([]string{"fake"}).Destinations).To(Equal([]executor.DestinationResult{{Name: "fake"}}))
+				Expect(runner.RunDestinations([]string{"other"}).Destinations).To(BeEmpty())
+				Expect(due).To(Equal([]bool{true, false}))
+			})
+
+			Context("when the upload fails", func() {
+				BeforeEach(func() {
+					uploader = &fakeUploader{uploadErr: errors.New("some failure")}
+				})
+
+				It("reports the failed phase and destination", func() {
+					report := observer.finished[0]
+					Expect(report.Succeeded()).To(BeFalse())
+					Expect(report.Err).To(Equal(executeErr))
+					Expect(report.FailedPhase).To(Equal(executor.PhaseUpload))
+					Expect(report.Destinations).To(Equal([]executor.DestinationResult{{Name: "fake", Err: errors.New("some failure")}}))
+				})
+
+				It("reports the size of the backup", func() {
+					Expect(observer.finished[0].SizeInBytes).To(Equal(int64(200)))
+				})
+			})
+
+			Context("when the backup command fails with output", func() {
+				BeforeEach(func() {
+					processManager.StartReturnsOnCall(0, []byte("dumping\npassword=hunter2\nerror: disk full\n"), errors.New("exit status 1"))
+				})
+
+				It("attaches the redacted tail of the output to the error", func() {
+					report := observer.finished[0]
+					Expect(report.FailedPhase).To(Equal(executor.PhaseBackup))
+					Expect(report.OutputTail).To(Equal("dumping\npassword=[REDACTED]\nerror: disk full"))
+					Expect(executeErr).To(MatchError("exit status 1\nlast output:\ndumping\npassword=[REDACTED]\nerror: disk full"))
+
+					var commandErr executor.CommandError
+					Expect(errors.As(executeErr, &commandErr)).To(BeTrue())
+				})
+
+				It("logs the whole output at debug level, redacted", func() {
+					Expect(log).To(gbytes.Say(`"message":"executor.Command output".*"log_level":0`))
+					Expect(log.Contents()).NotTo(ContainSubstring("hunter2"))
+				})
+			})
+
+			Context("when the cleanup fails", func() {
+				BeforeEach(func() {
+					processManager.StartReturnsOnCall(1, nil, errors.New("cleanup failed"))
+				})
+
+				It("reports the cleanup error without failing the run", func() {
+					report := observer.finished[0]
+					Expect(report.Succeeded()).To(BeTrue())
+					Expect(report.CleanupErr).To(MatchError("cleanup failed"))
+					Expect(report.FailedPhase).To(BeEmpty())
+				})
+			})
+		})
+
+		Describe("tracing", func() {
+			var recorder *tracetest.SpanRecorder
+
+			BeforeEach(func() {
+				recorder = tracetest.NewSpanRecorder()
+				uploader = &fakeUploader{
+					uploadStub: func(ctx context.Context, _ string, _ lager.Logger) error {
+						_, span := tracing.StartFileSpan(ctx, "source-folder/dump.rdb", 100)
+						span.End()
+						return nil
+					},
+				}
+			})
+
+			JustBeforeEach(func() {
+				backupExecutor = executor.NewExecutor(
+					uploader,
+					"source-folder",
+					assetPath("fake-snapshotter"),
+					assetPath("fake-cleanup"),
+					"",
+					exitIfBackupInProgress,
+					logger,
+					processManager,
+					executor.WithCommandFunc(fakeExec),
+					executor.WithDirSizeFunc(func(string) (int64, error) { return 200, nil }),
+					executor.WithTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))),
+				)
+
+				executeErr = backupExecutor.Execute()
+			})
+
+			spanNamed := func(name string) sdktrace.ReadOnlySpan {
+				for _, span := range recorder.Ended() {
+					if span.Name() == name {
+						return span
+					}
+				}
+				Fail("no span named " + name)
+				return nil
+			}
+
+			It("records a span for the run with a child span for each phase", func() {
+				Expect(executeErr).NotTo(HaveOccurred())
+
+				run := spanNamed("backup-run")
+				Expect(run.Parent().IsValid()).To(BeFalse())
+				Expect(run.Attributes()).To(ContainElement(tracing.SizeInBytesKey.Int64(200)))
+				Expect(run.Status().Code).To(Equal(codes.Unset))
+
+				for _, phase := range []string{"identify", "backup", "upload", "cleanup"} {
+					Expect(spanNamed(phase).Parent().SpanID()).To(Equal(run.SpanContext().SpanID()), phase)
+				}
+			})
+
+			It("parents spans started by the uploader on the upload span", func() {
+				file := spanNamed("upload-file")
+				Expect(file.Parent().SpanID()).To(Equal(spanNamed("upload").SpanContext().SpanID()))
+				Expect(file.Attributes()).To(ContainElement(tracing.FilePathKey.String("source-folder/dump.rdb")))
+			})
+
+			Context("when the upload fails", func() {
+				BeforeEach(func() {
+					uploader = &fakeUploader{uploadErr: errors.New("some failure")}
+				})
+
+				It("records the error on the upload and run spans", func() {
+					Expect(spanNamed("upload").Status().Code).To(Equal(codes.Error))
+					Expect(spanNamed("backup-run").Status().Code).To(Equal(codes.Error))
+					Expect(spanNamed("backup-run").Status().Description).To(Equal("some failure"))
+				})
+			})
+		})
+
+		Describe("Cancel", func() {
+			It("returns false when no backup is in progress", func() {
+				backupExecutor = executor.NewExecutor(
+					uploader,
+					"source-folder",
+					"",
+					"",
+					"",
+					exitIfBackupInProgress,
+					logger,
+					processManager,
+				)
+
+				Expect(backupExecutor.Cancel()).To(BeFalse())
+			})
+
+			It("cancels the upload in progress", func() {
+				uploadStarted := make(chan struct{})
+				uploader = &fakeUploader{
+					uploadStub: func(ctx context.Context, _ string, _ lager.Logger) error {
+						close(uploadStarted)
+						<-ctx.Done()
+						return ctx.Err()
+					},
+				}
+				observer := new(fakeObserver)
+
+				backupExecutor = executor.NewExecutor(
+					uploader,
+					"source-folder",
+					"",
+					"",
+					"",
+					exitIfBackupInProgress,
+					logger,
+					processManager,
+					executor.WithObserver(observer),
+				)
+
+				errs := make(chan error, 1)
+				go func() {
+					errs <- backupExecutor.Execute()
+				}()
+
+				<-uploadStarted
+				Expect(backupExecutor.Cancel()).To(BeTrue())
+				Eventually(errs).Should(Receive(MatchError(context.Canceled)))
+				Expect(observer.finished[0].Cancelled).To(BeTrue())
+				Expect(backupExecutor.Cancel()).To(BeFalse())
+			})
+
+			It("sends SIGTERM to the backup command", func() {
+				backupExecutor = executor.NewExecutor(
+					uploader,
+					"source-folder",
+					"sleep 10",
+					"",
+					"",
+					exitIfBackupInProgress,
+					logger,
+					process.NewManager(),
+				)
+
+				errs := make(chan error, 1)
+				go func() {
+					errs <- backupExecutor.Execute()
+				}()
+
+				Eventually(backupExecutor.Cancel).Should(BeTrue())
+				Eventually(errs, 5*time.Second).Should(Receive(MatchError("signal: terminated")))
+			})
+		})
+
+		Describe("Drain", func() {
+			type drainer interface {
+				Drain(context.Context) bool
+			}
+
+			var (
+				uploadStarted chan struct{}
+				finishUpload  chan struct{}
+				observer      *fakeObserver
+			)
+
+			BeforeEach(func() {
+				uploadStarted = make(chan struct{})
+				finishUpload = make(chan struct{})
+				uploader = &fakeUploader{
+					uploadStub: func(ctx context.Context, _ string, _ lager.Logger) error {
+						close(uploadStarted)
+						<-finishUpload
+						return nil
+					},
+				}
+				observer = new(fakeObserver)
+				backupExecutor = executor.NewExecutor(
+					uploader,
+					"source-folder",
+					"",
+					"",
+					"",
+					exitIfBackupInProgress,
+					logger,
+					processManager,
+					executor.WithObserver(observer),
+				)
+			})
+
+			It("returns true straight away when no backup is in progress", func() {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				Expect(backupExecutor.(drainer).Drain(ctx)).To(BeTrue())
+			})
+
+			It("waits for the backup in progress to finish", func() {
+				errs := make(chan error, 1)
+				go func() {
+					errs <- backupExecutor.Execute()
+				}()
+				<-uploadStarted
+
+				drained := make(chan bool, 1)
+				go func() {
+					drained <- backupExecutor.(drainer).Drain(context.Background())
+				}()
+				Consistently(drained).ShouldNot(Receive())
+
+				close(finishUpload)
+				Eventually(drained).Should(Receive(BeTrue()))
+				Expect(<-errs).NotTo(HaveOccurred())
+			})
+
+			It("gives up waiting when the context is done", func() {
+				go backupExecutor.Execute()
+				<-uploadStarted
+
+				ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+				defer cancel()
+				Expect(backupExecutor.(drainer).Drain(ctx)).To(BeFalse())
+				close(finishUpload)
+			})
+
+			It("refuses to start new backups", func() {
+				Expect(backupExecutor.(drainer).Drain(context.Background())).To(BeTrue())
+
+				report := backupExecutor.Run()
+				Expect(report.Skipped).To(BeTrue())
+				Expect(report.Err).NotTo(HaveOccurred())
+				Expect(observer.started).To(BeEmpty())
+				Expect(log).To(gbytes.Say("Shutting down, not starting backup"))
+			})
+		})
+
+		Describe("Start", func() {
+			type starter interface {
+				Start() (func() executor.RunReport, error)
+				Drain(context.Context) bool
+			}
+
+			var (
+				uploadStarted chan struct{}
+				finishUpload  chan struct{}
+			)
+
+			BeforeEach(func() {
+				uploadStarted = make(chan struct{}, 2)
+				finishUpload = make(chan struct{})
+				uploader = &fakeUploader{
+					uploadStub: func(ctx context.Context, _ string, _ lager.Logger) error {
+						uploadStarted <- struct{}{}
+						<-finishUpload
+						return nil
+					},
+				}
+				backupExecutor = executor.NewExecutor(
+					uploader,
+					"source-folder",
+					"",
+					"",
+					"",
+					true,
+					logger,
+					processManager,
+				)
+			})
+
+			It("claims a run that is performed when called", func() {
+				run, err := backupExecutor.(starter).Start()
+				Expect(err).NotTo(HaveOccurred())
+
+				reports := make(chan executor.RunReport, 1)
+				go func() { reports <- run() }()
+				<-uploadStarted
+				close(finishUpload)
+
+				Eventually(reports).Should(Receive(WithTransform(executor.RunReport.Succeeded, BeTrue())))
+			})
+
+			It("refuses while a backup is in progress and backups must not overlap", func() {
+				run, err := backupExecutor.(starter).Start()
+				Expect(err).NotTo(HaveOccurred())
+
+				_, err = backupExecutor.(starter).Start()
+				Expect(err).To(MatchError(executor.ErrBackupInProgress))
+
+				reports := make(chan executor.RunReport, 1)
+				go func() { reports <- run() }()
+				<-uploadStarted
+				_, err = backupExecutor.(starter).Start()
+				Expect(err).To(MatchError(executor.ErrBackupInProgress))
+				close(finishUpload)
+				Eventually(reports).Should(Receive())
+			})
+
+			It("is waited for by Drain from the moment it is claimed", func() {
+				run, err := backupExecutor.(starter).Start()
+				Expect(err).NotTo(HaveOccurred())
+
+				drained := make(chan bool, 1)
+				go func() { drained <- backupExecutor.(starter).Drain(context.Background()) }()
+				Consistently(drained).ShouldNot(Receive())
+
+				close(finishUpload)
+				run()
+				Eventually(drained).Should(Receive(BeTrue()))
+			})
+
+			It("refuses while draining", func() {
+				Expect(backupExecutor.(starter).Drain(context.Background())).To(BeTrue())
+
+				_, err := backupExecutor.(starter).Start()
+				Expect(err).To(MatchError(executor.ErrShuttingDown))
+			})
+		})
+
 		Describe("performWithOtherBackupInProgress", func() {
 			Context("when exit_if_in_progress is omitted or set to false", func() {
 				JustBeforeEach(func() {
@@ -375,7 +825,7 @@ var _ = Describe("Executor", func() {
 					firstBackupInProgress.Add(1)
 
 					uploader = &fakeUploader{
-						uploadStub: func(localPath string, _ lager.Logger) error {
+						uploadStub: func(_ context.Context, localPath string, _ lager.Logger) error {
 							firstBackupInProgress.Done()
 							blockfirstUpload.Wait()
 							return nil
@@ -420,15 +870,28 @@ func assetPath(filename string) string {
 }
 
 type fakeUploader struct {
-	uploadStub func(string, lager.Logger) error
+	uploadStub func(context.Context, string, lager.Logger) error
 	uploadErr  error
 }
 
-func (f *fakeUploader) Upload(name string, logger lager.Logger, manager process.ProcessManager) error {
+func (f *fakeUploader) Upload(ctx context.Context, name string, logger lager.Logger, manager process.ProcessManager) error {
 	if f.uploadStub != nil {
-		return f.uploadStub(name, logger)
+		return f.uploadStub(ctx, name, logger)
 	}
 	return f.uploadErr
 }
 
 func (f *fakeUploader) Name() string { return "fake" }
+
+type fakeObserver struct {
+	started  []executor.RunReport
+	finished []executor.RunReport
+}
+
+func (f *fakeObserver) RunStarted(report executor.RunReport) {
+	f.started = append(f.started, report)
+}
+
+func (f *fakeObserver) RunFinished(report executor.RunReport) {
+	f.finished = append(f.finished, report)
+}
@@ -6,6 +6,11 @@
 
 package executor
 
+import (
+	"github.com/pivotal-cf/service-backup/tracing"
+	"go.opentelemetry.io/otel/trace"
+)
+
 type Option func(*executor)
 
 func WithDirSizeFunc(fn DirSizeFunc) Option {
@@ -19,3 +24,34 @@ func WithCommandFunc(fn CmdFunc) Option {
 		e.execCommand = fn
 	}
 }
+
+func WithObserver(o Observer) Option {
+	return func(e *executor) {
+		e.observers = append(e.observers, o)
+	}
+}
+
+// WithOutputTail sets how much of the output of a failing command is
+// attached to its error, and how secrets are redacted from it.
+func WithOutputTail(tail OutputTail) Option {
+	return func(e *executor) {
+		if tail.Redactor == nil {
+			tail.Redactor = defaultOutputTail().Redactor
+		}
+		e.outputTail = tail
+	}
+}
+
+// WithStreamedOutput logs the output of the backup and cleanup commands a
+// line at a time as they run, if the process manager supports it.
+func WithStreamedOutput() Option {
+	return func(e *executor) {
+		e.streamOutput = true
+	}
+}
+
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(e *executor) {
+		e.tracer = tp.Tracer(tracing.InstrumentationName)
+	}
+}
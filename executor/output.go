@@ -0,0 +1,164 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package executor
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pivotal-cf/service-backup/config"
+)
+
+const (
+	DefaultOutputTailLines = 20
+	DefaultOutputTailBytes = 4096
+
+	redacted = "[REDACTED]"
+)
+
+// defaultRedactPatterns catch credentials passed on a command line or
+// printed as key/value pairs, whatever the config says the secrets are.
+var defaultRedactPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)((?:password|passwd|secret|token|api[_-]?key|access[_-]?key)\S*\s*[=:]\s*)("[^"]*"|'[^']*'|\S+)`),
+	regexp.MustCompile(`(?i)(authorization:\s*(?:basic|bearer)\s+)(\S+)`),
+	regexp.MustCompile(`(://[^:/@\s]+:)([^@\s]+)@`),
+}
+
+// Redactor removes secrets from the output of child processes before it is
+// logged or alerted.
+type Redactor struct {
+	secrets  []string
+	patterns []*regexp.Regexp
+}
+
+// NewRedactor builds a redactor that removes each of secrets wherever it
+// appears, and the value matched by the last group of each of patterns, or
+// the whole match if they have no groups, as well as anything that looks
+// like a credential.
+func NewRedactor(secrets []string, patterns []string) (*Redactor, error) {
+	r := &Redactor{patterns: defaultRedactPatterns}
+	for _, secret := range secrets {
+		if secret != "" {
+			r.secrets = append(r.secrets, secret)
+		}
+	}
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redact pattern %q: %s", pattern, err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	return r, nil
+}
+
+func (r *Redactor) Redact(output string) string {
+	for _, secret := range r.secrets {
+		output = strings.ReplaceAll(output, secret, redacted)
+	}
+	for _, re := range r.patterns {
+		output = redactPattern(re, output)
+	}
+	return output
+}
+
+// redactPattern replaces the last group of each match of re, so that the key
+// of a key/value pair stays readable, or the whole match if re has no groups.
+func redactPattern(re *regexp.Regexp, output string) string {
+	last := re.NumSubexp()
+	if last == 0 {
+		return re.ReplaceAllLiteralString(output, redacted)
+	}
+	return re.ReplaceAllStringFunc(output, func(match string) string {
+		groups := re.FindStringSubmatchIndex(match)
+		start, end := groups[2*last], groups[2*last+1]
+		if start < 0 {
+			return match
+		}
+		return match[:start] + redacted + match[end:]
+	})
+}
+
+// OutputTail is how much of the output of a failing command is attached to
+// its error.
+type OutputTail struct {
+	Lines    int
+	Bytes    int
+	Redactor *Redactor
+}
+
+func defaultOutputTail() OutputTail {
+	redactor, _ := NewRedactor(nil, nil)
+	return OutputTail{
+		Lines:    DefaultOutputTailLines,
+		Bytes:    DefaultOutputTailBytes,
+		Redactor: redactor,
+	}
+}
+
+// OutputTailFromConfig builds the output tail configured in backupConfig,
+// redacting every secret in it.
+func OutputTailFromConfig(backupConfig config.BackupConfig) (OutputTail, error) {
+	tail := defaultOutputTail()
+	var patterns []string
+	if childOutput := backupConfig.ChildOutput; childOutput != nil {
+		if childOutput.TailLines != 0 {
+			tail.Lines = childOutput.TailLines
+		}
+		if childOutput.TailBytes != 0 {
+			tail.Bytes = childOutput.TailBytes
+		}
+		patterns = childOutput.RedactPatterns
+	}
+
+	redactor, err := NewRedactor(backupConfig.Secrets(), patterns)
+	if err != nil {
+		return OutputTail{}, err
+	}
+	tail.Redactor = redactor
+	return tail, nil
+}
+
+// Of returns the last lines of output, cut to at most the configured number
+// of lines and bytes. The output is redacted before it is cut, so that a
+// secret straddling the cut cannot be left partly visible.
+func (t OutputTail) Of(output []byte) string {
+	tail := strings.TrimRight(t.Redactor.Redact(string(output)), "\n")
+	if t.Lines > 0 {
+		lines := strings.Split(tail, "\n")
+		if len(lines) > t.Lines {
+			tail = strings.Join(lines[len(lines)-t.Lines:], "\n")
+		}
+	}
+	if t.Bytes > 0 && len(tail) > t.Bytes {
+		tail = tail[len(tail)-t.Bytes:]
+		if i := strings.IndexByte(tail, '\n'); i >= 0 && i < len(tail)-1 {
+			tail = tail[i+1:]
+		}
+		for len(tail) > 0 && !utf8.RuneStart(tail[0]) {
+			tail = tail[1:]
+		}
+	}
+	return tail
+}
+
+// CommandError is the error of a command that failed, with the tail of what
+// it wrote to stdout and stderr.
+type CommandError struct {
+	Err        error
+	OutputTail string
+}
+
+func (e CommandError) Error() string {
+	return fmt.Sprintf("%s\nlast output:\n%s", e.Err, e.OutputTail)
+}
+
+func (e CommandError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,121 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package executor
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/pivotal-cf/service-backup/upload"
+)
+
+type Phase string
+
+const (
+	PhaseIdentify Phase = "identify"
+	PhaseStart    Phase = "start"
+	PhaseBackup   Phase = "backup"
+	PhaseUpload   Phase = "upload"
+	PhaseCleanup  Phase = "cleanup"
+)
+
+type DestinationResult struct {
+	Name string
+	Err  error
+}
+
+// RunReport describes a single run of the executor.
+type RunReport struct {
+	BackupGUID        string
+	ServiceInstanceID string
+	StartedAt         time.Time
+	FinishedAt        time.Time
+	Durations         map[Phase]time.Duration
+	SizeInBytes       int64
+	Destinations      []DestinationResult
+	FailedPhase       Phase
+	Cancelled         bool
+	Err               error
+	CleanupErr        error
+
+	// OutputTail is the end of the output of the command that failed the
+	// run, with secrets redacted.
+	OutputTail string
+
+	// Skipped is set when no backup was taken at all, because backups are
+	// disabled or the executor is draining.
+	Skipped bool
+}
+
+func (r RunReport) Succeeded() bool {
+	return r.Err == nil
+}
+
+// Partial reports whether the upload failed for some destinations but
+// succeeded for others.
+func (r RunReport) Partial() bool {
+	failed, succeeded := r.DestinationsByOutcome()
+	return r.FailedPhase == PhaseUpload && len(failed) > 0 && len(succeeded) > 0
+}
+
+// DestinationsByOutcome returns the names of the destinations the upload
+// failed for, and of those it succeeded for.
+func (r RunReport) DestinationsByOutcome() (failed, succeeded []string) {
+	for _, d := range r.Destinations {
+		if d.Err != nil {
+			failed = append(failed, d.Name)
+		} else {
+			succeeded = append(succeeded, d.Name)
+		}
+	}
+	return failed, succeeded
+}
+
+func (r RunReport) Duration() time.Duration {
+	return r.FinishedAt.Sub(r.StartedAt)
+}
+
+// Observer is notified as runs start and finish.
+type Observer interface {
+	RunStarted(RunReport)
+	RunFinished(RunReport)
+}
+
+type destinationLister interface {
+	Destinations() []string
+}
+
+// destinationResults pairs each destination due with ctx with the error it
+// failed with, if any. Destination errors are reported in the same order as
+// the destinations, so they are matched up in a single pass.
+func destinationResults(ctx context.Context, uploader upload.Uploader, uploadErr error) []DestinationResult {
+	lister, ok := uploader.(destinationLister)
+	if !ok {
+		if !upload.Due(ctx, uploader.Name()) {
+			return nil
+		}
+		return []DestinationResult{{Name: uploader.Name(), Err: uploadErr}}
+	}
+
+	var destinationErrs upload.DestinationErrors
+	errors.As(uploadErr, &destinationErrs)
+
+	var results []DestinationResult
+	for _, name := range lister.Destinations() {
+		if !upload.Due(ctx, name) {
+			continue
+		}
+		result := DestinationResult{Name: name}
+		if len(destinationErrs) > 0 && destinationErrs[0].Destination == name {
+			result.Err = destinationErrs[0].Err
+			destinationErrs = destinationErrs[1:]
+		}
+		results = append(results, result)
+	}
+	return results
+}
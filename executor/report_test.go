@@ -0,0 +1,48 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package executor_test
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-cf/service-backup/executor"
+)
+
+var _ = Describe("RunReport", func() {
+	uploadErr := errors.New("upload failed")
+
+	It("is partial when the upload failed for some destinations but not all", func() {
+		report := executor.RunReport{
+			Err:          uploadErr,
+			FailedPhase:  executor.PhaseUpload,
+			Destinations: []executor.DestinationResult{{Name: "s3", Err: uploadErr}, {Name: "gcs"}, {Name: "azure", Err: uploadErr}},
+		}
+
+		Expect(report.Partial()).To(BeTrue())
+		failed, succeeded := report.DestinationsByOutcome()
+		Expect(failed).To(Equal([]string{"s3", "azure"}))
+		Expect(succeeded).To(Equal([]string{"gcs"}))
+	})
+
+	It("is not partial when the upload failed for every destination", func() {
+		report := executor.RunReport{
+			Err:          uploadErr,
+			FailedPhase:  executor.PhaseUpload,
+			Destinations: []executor.DestinationResult{{Name: "s3", Err: uploadErr}},
+		}
+
+		Expect(report.Partial()).To(BeFalse())
+	})
+
+	It("is not partial when the run succeeded", func() {
+		report := executor.RunReport{Destinations: []executor.DestinationResult{{Name: "s3"}}}
+
+		Expect(report.Partial()).To(BeFalse())
+	})
+})
@@ -17,6 +17,9 @@ import (
 	"cloud.google.com/go/storage"
 	"code.cloudfoundry.org/lager/v3"
 	"github.com/pivotal-cf/service-backup/process"
+	"github.com/pivotal-cf/service-backup/throttle"
+	"github.com/pivotal-cf/service-backup/tracing"
+	"github.com/pivotal-cf/service-backup/workers"
 	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
 )
@@ -27,6 +30,8 @@ type StorageClient struct {
 	bucketName             string
 	name                   string
 	remotePathFn           func() string
+	Throttle               *throttle.Throttle
+	Workers                int
 }
 
 func New(name, serviceAccountFilePath, projectID, bucketName string, remotePathFn func() string) *StorageClient {
@@ -39,7 +44,7 @@ func New(name, serviceAccountFilePath, projectID, bucketName string, remotePathF
 	}
 }
 
-func (s *StorageClient) Upload(dirToUpload string, logger lager.Logger, _ process.ProcessManager) error {
+func (s *StorageClient) Upload(ctx context.Context, dirToUpload string, logger lager.Logger, _ process.ProcessManager) error {
 	errs := func(action string, err error) error {
 		wrappedErr := fmt.Errorf("error %s: %s", action, err)
 		logger.Error("error uploading to Google Cloud Storage", wrappedErr, nil)
@@ -47,8 +52,9 @@ func (s *StorageClient) Upload(dirToUpload string, logger lager.Logger, _ proces
 	}
 
 	logger.Info(fmt.Sprintf("will upload %s to Google Cloud Storage", dirToUpload), nil)
+	s.Throttle.Log(logger)
+	workers.Log(logger, s.Workers)
 
-	ctx := context.Background()
 	client, err := storage.NewClient(ctx, option.WithServiceAccountFile(s.serviceAccountFilePath))
 	if err != nil {
 		return errs("creating Google Cloud Storage client", err)
@@ -61,20 +67,13 @@ func (s *StorageClient) Upload(dirToUpload string, logger lager.Logger, _ proces
 	}
 
 	today := time.Now()
-	if err := filepath.Walk(dirToUpload, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		if info.IsDir() {
-			return nil
-		}
-
-		if err := s.uploadFile(dirToUpload, path, today, ctx, bucket, logger); err != nil {
-			return errs("uploading file", err)
-		}
-		return nil
-	}); err != nil {
+	if err := workers.ForEachFile(ctx, dirToUpload, s.Workers, func(ctx context.Context, path string, info os.FileInfo) error {
+		fileCtx, span := tracing.StartFileSpan(ctx, path, info.Size())
+		err := s.uploadFile(dirToUpload, path, today, fileCtx, bucket, logger)
+		tracing.End(span, err)
 		return err
+	}); err != nil {
+		return errs("uploading file", err)
 	}
 
 	return nil
@@ -98,7 +97,7 @@ func (s *StorageClient) uploadFile(baseDir, fileAbsPath string, timeNow time.Tim
 	}
 	defer file.Close()
 
-	if _, err := io.Copy(bucketWriter, file); err != nil {
+	if _, err := io.Copy(bucketWriter, s.Throttle.Reader(file, logger)); err != nil {
 		return err
 	}
 
@@ -73,7 +73,7 @@ var _ = Describe("backups to Google Cloud Storage", func() {
 		JustBeforeEach(func() {
 			logger := lager.NewLogger("[GCS tests] ")
 			logger.RegisterSink(lager.NewWriterSink(GinkgoWriter, lager.DEBUG))
-			Expect(backuper.Upload(dirToBackup, logger, process.NewManager())).To(Succeed())
+			Expect(backuper.Upload(context.Background(), dirToBackup, logger, process.NewManager())).To(Succeed())
 		})
 
 		AfterEach(func() {
@@ -98,7 +98,7 @@ var _ = Describe("backups to Google Cloud Storage", func() {
 				backuper := gcs.New("icanbeanything", "idontexist", "", "", upload.RemotePathFunc("", ""))
 				logger := lager.NewLogger("[GCS tests] ")
 				logger.RegisterSink(lager.NewWriterSink(GinkgoWriter, lager.DEBUG))
-				Expect(backuper.Upload("", logger, process.NewManager())).To(MatchError(ContainSubstring("error creating Google Cloud Storage client")))
+				Expect(backuper.Upload(context.Background(), "", logger, process.NewManager())).To(MatchError(ContainSubstring("error creating Google Cloud Storage client")))
 			})
 		})
 	})
@@ -0,0 +1,99 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package gcs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"cloud.google.com/go/storage"
+	"code.cloudfoundry.org/lager/v3"
+	"github.com/pivotal-cf/service-backup/lease"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+)
+
+type leaseBackend struct {
+	client *StorageClient
+	key    string
+
+	// clientOptions are applied to the storage client, to point it at a
+	// fake server in tests.
+	clientOptions []option.ClientOption
+}
+
+// LeaseBackend stores a lease as the object key in the bucket this client
+// uploads to. Writes are conditional on the object's generation, so only one
+// node can take the lease.
+func (s *StorageClient) LeaseBackend(key string, _ lager.Logger) lease.Backend {
+	return &leaseBackend{client: s, key: key}
+}
+
+func (b *leaseBackend) Acquire(ctx context.Context, l lease.Lease) (lease.Lease, error) {
+	options := append([]option.ClientOption{option.WithServiceAccountFile(b.client.serviceAccountFilePath)}, b.clientOptions...)
+	client, err := storage.NewClient(ctx, options...)
+	if err != nil {
+		return lease.Lease{}, fmt.Errorf("error creating Google Cloud Storage client: %s", err)
+	}
+	defer client.Close()
+	obj := client.Bucket(b.client.bucketName).Object(b.key)
+
+	current, generation, err := b.get(ctx, obj)
+	if err != nil {
+		return lease.Lease{}, err
+	}
+	if !current.FreeFor(l.Holder, l.AcquiredAt) {
+		return current, nil
+	}
+
+	contents, err := lease.Encode(l)
+	if err != nil {
+		return lease.Lease{}, err
+	}
+	conditions := storage.Conditions{DoesNotExist: true}
+	if generation != 0 {
+		conditions = storage.Conditions{GenerationMatch: generation}
+	}
+	writer := obj.If(conditions).NewWriter(ctx)
+	if _, err := writer.Write(contents); err != nil {
+		writer.Close()
+		return lease.Lease{}, fmt.Errorf("error writing lease: %s", err)
+	}
+	if err := writer.Close(); err != nil {
+		var apiErr *googleapi.Error
+		if !errors.As(err, &apiErr) || apiErr.Code != http.StatusPreconditionFailed {
+			return lease.Lease{}, fmt.Errorf("error writing lease: %s", err)
+		}
+		// Another node changed the lease since it was read.
+		current, _, err := b.get(ctx, obj)
+		return current, err
+	}
+	return l, nil
+}
+
+// get returns the stored lease and its generation, or a free lease if none is
+// stored yet.
+func (b *leaseBackend) get(ctx context.Context, obj *storage.ObjectHandle) (lease.Lease, int64, error) {
+	reader, err := obj.NewReader(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return lease.Lease{}, 0, nil
+	}
+	if err != nil {
+		return lease.Lease{}, 0, fmt.Errorf("error reading lease: %s", err)
+	}
+	defer reader.Close()
+
+	contents, err := io.ReadAll(reader)
+	if err != nil {
+		return lease.Lease{}, 0, fmt.Errorf("error reading lease: %s", err)
+	}
+	current, err := lease.Decode(contents)
+	return current, reader.Attrs.Generation, err
+}
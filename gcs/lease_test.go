@@ -0,0 +1,121 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package gcs
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+	"github.com/pivotal-cf/service-backup/lease"
+	"google.golang.org/api/option"
+)
+
+var _ = Describe("LeaseBackend", func() {
+	const (
+		objectPath = "/bucket/leases/leader.json"
+		uploadPath = "/upload/storage/v1/b/bucket/o"
+	)
+
+	var (
+		server  *ghttp.Server
+		backend *leaseBackend
+		now     time.Time
+		mine    lease.Lease
+		theirs  lease.Lease
+	)
+
+	BeforeEach(func() {
+		server = ghttp.NewTLSServer()
+		client := New("gcs", "", "project", "bucket", func() string { return "path" })
+		backend = client.LeaseBackend("leases/leader.json", nil).(*leaseBackend)
+		backend.clientOptions = []option.ClientOption{option.WithHTTPClient(redirectedClient(server))}
+
+		now = time.Now().UTC().Round(0)
+		mine = lease.Lease{Holder: "node-a", ScheduledAt: now, AcquiredAt: now, ExpiresAt: now.Add(time.Hour)}
+		theirs = lease.Lease{Holder: "node-b", ScheduledAt: now, AcquiredAt: now, ExpiresAt: now.Add(time.Hour)}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	getLease := func(l lease.Lease, generation string) http.HandlerFunc {
+		contents, err := lease.Encode(l)
+		Expect(err).NotTo(HaveOccurred())
+		return ghttp.CombineHandlers(
+			ghttp.VerifyRequest(http.MethodGet, objectPath),
+			ghttp.RespondWith(http.StatusOK, contents, http.Header{"X-Goog-Generation": {generation}}),
+		)
+	}
+	getNoLease := ghttp.CombineHandlers(
+		ghttp.VerifyRequest(http.MethodGet, objectPath),
+		ghttp.RespondWith(http.StatusNotFound, "No such object"),
+	)
+	putLease := func(condition string, status int, response string) http.HandlerFunc {
+		return ghttp.CombineHandlers(
+			ghttp.VerifyRequest(http.MethodPost, uploadPath),
+			func(w http.ResponseWriter, r *http.Request) {
+				Expect(r.URL.Query().Get("ifGenerationMatch")).To(Equal(condition))
+				body, err := io.ReadAll(r.Body)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(body)).To(ContainSubstring(`"holder":"node-a"`))
+			},
+			ghttp.RespondWith(status, response, http.Header{"Content-Type": {"application/json"}}),
+		)
+	}
+	written := `{"bucket":"bucket","name":"leases/leader.json","generation":"2"}`
+	preconditionFailed := `{"error":{"code":412,"message":"At least one of the pre-conditions you specified did not hold."}}`
+
+	It("creates the lease when there is none", func() {
+		server.AppendHandlers(getNoLease, putLease("0", http.StatusOK, written))
+
+		Expect(backend.Acquire(context.Background(), mine)).To(Equal(mine))
+		Expect(server.ReceivedRequests()).To(HaveLen(2))
+	})
+
+	It("replaces an expired lease only if its generation has not changed", func() {
+		expired := theirs
+		expired.ExpiresAt = now.Add(-time.Minute)
+		server.AppendHandlers(getLease(expired, "7"), putLease("7", http.StatusOK, written))
+
+		Expect(backend.Acquire(context.Background(), mine)).To(Equal(mine))
+	})
+
+	It("returns the lease held by another node without writing", func() {
+		server.AppendHandlers(getLease(theirs, "7"))
+
+		Expect(backend.Acquire(context.Background(), mine)).To(Equal(theirs))
+		Expect(server.ReceivedRequests()).To(HaveLen(1))
+	})
+
+	It("returns the winner's lease when another node creates it first", func() {
+		server.AppendHandlers(
+			getNoLease,
+			putLease("0", http.StatusPreconditionFailed, preconditionFailed),
+			getLease(theirs, "1"),
+		)
+
+		Expect(backend.Acquire(context.Background(), mine)).To(Equal(theirs))
+	})
+})
+
+// redirectedClient sends every request to server, whatever host it is for.
+func redirectedClient(server *ghttp.Server) *http.Client {
+	return &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return new(net.Dialer).DialContext(ctx, network, server.Addr())
+		},
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}}
+}
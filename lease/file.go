@@ -0,0 +1,66 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package lease
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"syscall"
+)
+
+// FileBackend stores a lease in a local file, locking it while the lease is
+// taken. It only coordinates processes sharing a filesystem, so is meant for
+// tests and single-host setups.
+type FileBackend struct {
+	path string
+}
+
+func NewFileBackend(path string) *FileBackend {
+	return &FileBackend{path: path}
+}
+
+func (f *FileBackend) Acquire(_ context.Context, lease Lease) (Lease, error) {
+	file, err := os.OpenFile(f.path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return Lease{}, err
+	}
+	defer file.Close()
+
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX); err != nil {
+		return Lease{}, fmt.Errorf("error locking %s: %s", f.path, err)
+	}
+	defer syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+
+	contents, err := io.ReadAll(file)
+	if err != nil {
+		return Lease{}, err
+	}
+	current, err := Decode(contents)
+	if err != nil {
+		return Lease{}, err
+	}
+	if !current.FreeFor(lease.Holder, lease.AcquiredAt) {
+		return current, nil
+	}
+
+	contents, err = Encode(lease)
+	if err != nil {
+		return Lease{}, err
+	}
+	if err := file.Truncate(0); err != nil {
+		return Lease{}, err
+	}
+	if _, err := file.WriteAt(contents, 0); err != nil {
+		return Lease{}, err
+	}
+	if err := file.Sync(); err != nil {
+		return Lease{}, err
+	}
+	return lease, nil
+}
@@ -0,0 +1,64 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+// Package lease elects which of several nodes backing up the same data runs a
+// scheduled backup, by taking a lease stored where every node can see it.
+package lease
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const DefaultTTL = time.Hour
+
+// Lease says which node holds the right to run scheduled backups, and until
+// when.
+type Lease struct {
+	Holder      string    `json:"holder"`
+	ScheduledAt time.Time `json:"scheduled_at"`
+	AcquiredAt  time.Time `json:"acquired_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// FreeFor reports whether holder may take the lease at now: nobody holds it,
+// holder already does, or it has expired.
+func (l Lease) FreeFor(holder string, now time.Time) bool {
+	return l.Holder == "" || l.Holder == holder || !now.Before(l.ExpiresAt)
+}
+
+// HeldBy reports whether holder holds the lease at now.
+func (l Lease) HeldBy(holder string, now time.Time) bool {
+	return l.Holder == holder && now.Before(l.ExpiresAt)
+}
+
+// Backend stores a lease where every node can see it.
+type Backend interface {
+	// Acquire stores lease if the stored lease is free for its holder at the
+	// time it was acquired, atomically with respect to other nodes, and
+	// returns the lease as it then stands.
+	Acquire(ctx context.Context, lease Lease) (Lease, error)
+}
+
+// Encode serializes a lease for storage.
+func Encode(lease Lease) ([]byte, error) {
+	return json.Marshal(lease)
+}
+
+// Decode parses a stored lease. An empty one, as stored before any node took
+// the lease, is free.
+func Decode(contents []byte) (Lease, error) {
+	var lease Lease
+	if len(contents) == 0 {
+		return lease, nil
+	}
+	if err := json.Unmarshal(contents, &lease); err != nil {
+		return lease, fmt.Errorf("error parsing lease: %s", err)
+	}
+	return lease, nil
+}
@@ -0,0 +1,133 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package lease_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-cf/service-backup/lease"
+)
+
+var _ = Describe("Lease", func() {
+	now := time.Date(2026, 10, 19, 2, 0, 0, 0, time.UTC)
+	held := lease.Lease{Holder: "node-a", AcquiredAt: now, ExpiresAt: now.Add(time.Hour)}
+
+	It("is free when nobody holds it", func() {
+		Expect(lease.Lease{}.FreeFor("node-b", now)).To(BeTrue())
+	})
+
+	It("is free for its holder", func() {
+		Expect(held.FreeFor("node-a", now.Add(time.Minute))).To(BeTrue())
+		Expect(held.HeldBy("node-a", now.Add(time.Minute))).To(BeTrue())
+	})
+
+	It("is not free for other nodes until it expires", func() {
+		Expect(held.FreeFor("node-b", now.Add(time.Minute))).To(BeFalse())
+		Expect(held.FreeFor("node-b", now.Add(time.Hour))).To(BeTrue())
+		Expect(held.HeldBy("node-a", now.Add(time.Hour))).To(BeFalse())
+	})
+
+	It("decodes what it encodes, and an empty lease as free", func() {
+		contents, err := lease.Encode(held)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(lease.Decode(contents)).To(Equal(held))
+		Expect(lease.Decode(nil)).To(Equal(lease.Lease{}))
+	})
+
+	It("rejects a corrupt lease", func() {
+		_, err := lease.Decode([]byte("{"))
+		Expect(err).To(MatchError(HavePrefix("error parsing lease:")))
+	})
+})
+
+var _ = Describe("FileBackend", func() {
+	var (
+		path    string
+		backend *lease.FileBackend
+		now     time.Time
+	)
+
+	BeforeEach(func() {
+		path = filepath.Join(GinkgoT().TempDir(), "leader.json")
+		backend = lease.NewFileBackend(path)
+		now = time.Now().UTC().Round(0)
+	})
+
+	leaseFor := func(holder string, at time.Time) lease.Lease {
+		return lease.Lease{Holder: holder, ScheduledAt: at, AcquiredAt: at, ExpiresAt: at.Add(time.Hour)}
+	}
+
+	It("takes a lease nobody holds, and stores it", func() {
+		acquired, err := backend.Acquire(context.Background(), leaseFor("node-a", now))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(acquired.Holder).To(Equal("node-a"))
+
+		contents, err := os.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(lease.Decode(contents)).To(Equal(acquired))
+	})
+
+	It("returns the lease another node holds, without taking it", func() {
+		_, err := backend.Acquire(context.Background(), leaseFor("node-a", now))
+		Expect(err).NotTo(HaveOccurred())
+
+		current, err := backend.Acquire(context.Background(), leaseFor("node-b", now.Add(time.Minute)))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(current.Holder).To(Equal("node-a"))
+		Expect(current.ExpiresAt).To(BeTemporally("==", now.Add(time.Hour)))
+	})
+
+	It("takes an expired lease, and renews one already held", func() {
+		_, err := backend.Acquire(context.Background(), leaseFor("node-a", now))
+		Expect(err).NotTo(HaveOccurred())
+
+		renewed, err := backend.Acquire(context.Background(), leaseFor("node-a", now.Add(time.Minute)))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(renewed.ExpiresAt).To(BeTemporally("==", now.Add(time.Hour+time.Minute)))
+
+		taken, err := backend.Acquire(context.Background(), leaseFor("node-b", now.Add(2*time.Hour)))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(taken.Holder).To(Equal("node-b"))
+	})
+
+	It("lets only one of several nodes take the lease at once", func() {
+		var (
+			wg      sync.WaitGroup
+			lock    sync.Mutex
+			holders []string
+		)
+		for _, holder := range []string{"node-a", "node-b", "node-c", "node-d"} {
+			wg.Add(1)
+			go func() {
+				defer GinkgoRecover()
+				defer wg.Done()
+				current, err := lease.NewFileBackend(path).Acquire(context.Background(), leaseFor(holder, now))
+				Expect(err).NotTo(HaveOccurred())
+				lock.Lock()
+				holders = append(holders, current.Holder)
+				lock.Unlock()
+			}()
+		}
+		wg.Wait()
+
+		Expect(holders).To(HaveLen(4))
+		Expect(holders).To(HaveEach(holders[0]))
+	})
+
+	It("reports a corrupt lease file", func() {
+		Expect(os.WriteFile(path, []byte("{"), 0644)).To(Succeed())
+
+		_, err := backend.Acquire(context.Background(), leaseFor("node-a", now))
+		Expect(err).To(MatchError(HavePrefix("error parsing lease:")))
+	})
+})
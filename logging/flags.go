@@ -0,0 +1,47 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package logging
+
+import (
+	"flag"
+
+	"code.cloudfoundry.org/lager/v3"
+	"github.com/pivotal-cf/service-backup/config"
+)
+
+// Flags holds the -log-format and -log-level command line overrides and the
+// format a binary logs in when neither the flags nor the config set one.
+type Flags struct {
+	Format        string
+	Level         string
+	DefaultFormat string
+}
+
+// RegisterFlags adds -log-format and -log-level to fs.
+func RegisterFlags(fs *flag.FlagSet, defaultFormat string) *Flags {
+	f := &Flags{DefaultFormat: defaultFormat}
+	fs.StringVar(&f.Format, "log-format", "", "log format: pretty, json or plain (overrides config)")
+	fs.StringVar(&f.Level, "log-level", "", "minimum log level: debug, info, error or fatal (overrides config)")
+	return f
+}
+
+// NewLogger builds a logger from conf with the flags overlaid on it. Pass an
+// empty conf to log before the config file has been parsed.
+func (f *Flags) NewLogger(component string, conf config.Logging) (lager.Logger, error) {
+	return NewLogger(component, f.apply(conf), f.DefaultFormat)
+}
+
+// apply returns conf with any format or level set on the command line.
+func (f *Flags) apply(conf config.Logging) config.Logging {
+	if f.Format != "" {
+		conf.Format = f.Format
+	}
+	if f.Level != "" {
+		conf.Level = f.Level
+	}
+	return conf
+}
@@ -0,0 +1,87 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package logging
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"code.cloudfoundry.org/lager/v3"
+	"github.com/pivotal-cf/service-backup/config"
+)
+
+const (
+	FormatPretty = "pretty"
+	FormatJSON   = "json"
+	FormatPlain  = "plain"
+)
+
+// NewLogger builds the logger shared by every component of the daemon from
+// the logging config. defaultFormat is used when the config does not set one.
+func NewLogger(component string, conf config.Logging, defaultFormat string) (lager.Logger, error) {
+	level := lager.INFO
+	if conf.Level != "" {
+		var err error
+		level, err = lager.LogLevelFromString(conf.Level)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	format := conf.Format
+	if format == "" {
+		format = defaultFormat
+	}
+
+	logger := lager.NewLogger(component)
+
+	stdoutSink, err := formatSink(format, os.Stdout, level, component)
+	if err != nil {
+		return nil, err
+	}
+	logger.RegisterSink(stdoutSink)
+
+	if fileConfig := conf.File; fileConfig != nil {
+		file, err := newRotatingFile(fileConfig.Path, fileConfig.MaxSizeBytes, fileConfig.MaxBackups)
+		if err != nil {
+			return nil, err
+		}
+		fileSink, err := formatSink(format, file, level, component)
+		if err != nil {
+			return nil, err
+		}
+		logger.RegisterSink(fileSink)
+	}
+
+	if syslogConfig := conf.Syslog; syslogConfig != nil {
+		tag := syslogConfig.Tag
+		if tag == "" {
+			tag = component
+		}
+		syslogSink, err := newSyslogSink(syslogConfig.Network, syslogConfig.Address, tag, level)
+		if err != nil {
+			return nil, err
+		}
+		logger.RegisterSink(syslogSink)
+	}
+
+	return logger, nil
+}
+
+func formatSink(format string, writer io.Writer, level lager.LogLevel, appName string) (lager.Sink, error) {
+	switch format {
+	case FormatPretty:
+		return lager.NewPrettySink(writer, level), nil
+	case FormatJSON:
+		return lager.NewWriterSink(writer, level), nil
+	case FormatPlain:
+		return newPlainSink(writer, level, appName), nil
+	default:
+		return nil, fmt.Errorf("unknown log format: %s", format)
+	}
+}
@@ -0,0 +1,209 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package logging_test
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+
+	"code.cloudfoundry.org/lager/v3"
+	"github.com/pivotal-cf/service-backup/config"
+	"github.com/pivotal-cf/service-backup/logging"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("NewLogger", func() {
+	var (
+		dir     string
+		logPath string
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = os.MkdirTemp("", "logging-test")
+		Expect(err).NotTo(HaveOccurred())
+		logPath = filepath.Join(dir, "service-backup.log")
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	readLog := func() string {
+		contents, err := os.ReadFile(logPath)
+		Expect(err).NotTo(HaveOccurred())
+		return string(contents)
+	}
+
+	It("writes JSON lines when the format is json", func() {
+		logger, err := logging.NewLogger("ServiceBackup", config.Logging{
+			Format: logging.FormatJSON,
+			File:   &config.LogFile{Path: logPath},
+		}, logging.FormatPretty)
+		Expect(err).NotTo(HaveOccurred())
+
+		logger.Info("backup-started", lager.Data{"guid": "abc"})
+
+		Expect(readLog()).To(ContainSubstring(`"message":"ServiceBackup.backup-started"`))
+		Expect(readLog()).To(ContainSubstring(`"guid":"abc"`))
+	})
+
+	It("writes syslog-style lines when the format is plain", func() {
+		logger, err := logging.NewLogger("ServiceBackup", config.Logging{
+			Format: logging.FormatPlain,
+			File:   &config.LogFile{Path: logPath},
+		}, logging.FormatPretty)
+		Expect(err).NotTo(HaveOccurred())
+
+		logger.Error("upload-failed", os.ErrPermission, lager.Data{"destination": "s3"})
+
+		Expect(readLog()).To(MatchRegexp(
+			`^<11>1 \d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}\.\d{6}Z \S+ ServiceBackup \d+ ServiceBackup - ServiceBackup\.upload-failed \{.*"destination":"s3".*\}\n$`,
+		))
+	})
+
+	It("uses the default format when none is configured", func() {
+		logger, err := logging.NewLogger("ServiceBackup", config.Logging{
+			File: &config.LogFile{Path: logPath},
+		}, logging.FormatPretty)
+		Expect(err).NotTo(HaveOccurred())
+
+		logger.Info("hello")
+
+		Expect(readLog()).To(ContainSubstring(`"level":"info"`))
+	})
+
+	It("drops messages below the configured level", func() {
+		logger, err := logging.NewLogger("ServiceBackup", config.Logging{
+			Level: "error",
+			File:  &config.LogFile{Path: logPath},
+		}, logging.FormatJSON)
+		Expect(err).NotTo(HaveOccurred())
+
+		logger.Info("quiet")
+		logger.Error("loud", os.ErrNotExist)
+
+		Expect(readLog()).NotTo(ContainSubstring("quiet"))
+		Expect(readLog()).To(ContainSubstring("loud"))
+	})
+
+	It("logs debug messages when the level is debug", func() {
+		logger, err := logging.NewLogger("ServiceBackup", config.Logging{
+			Level: "debug",
+			File:  &config.LogFile{Path: logPath},
+		}, logging.FormatJSON)
+		Expect(err).NotTo(HaveOccurred())
+
+		logger.Debug("details")
+
+		Expect(readLog()).To(ContainSubstring("details"))
+	})
+
+	It("rotates the log file when it exceeds the maximum size", func() {
+		logger, err := logging.NewLogger("ServiceBackup", config.Logging{
+			Format: logging.FormatJSON,
+			File:   &config.LogFile{Path: logPath, MaxSizeBytes: 200, MaxBackups: 2},
+		}, logging.FormatJSON)
+		Expect(err).NotTo(HaveOccurred())
+
+		for i := 0; i < 10; i++ {
+			logger.Info("a-message-long-enough-to-fill-the-file")
+		}
+
+		Expect(logPath + ".1").To(BeAnExistingFile())
+		Expect(logPath + ".2").To(BeAnExistingFile())
+		Expect(logPath + ".3").NotTo(BeAnExistingFile())
+		info, err := os.Stat(logPath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(info.Size()).To(BeNumerically("<=", 200))
+	})
+
+	It("returns an error for an unknown level", func() {
+		_, err := logging.NewLogger("ServiceBackup", config.Logging{Level: "loud"}, logging.FormatJSON)
+		Expect(err).To(MatchError("invalid log level: loud"))
+	})
+
+	It("returns an error for an unknown format", func() {
+		_, err := logging.NewLogger("ServiceBackup", config.Logging{Format: "xml"}, logging.FormatJSON)
+		Expect(err).To(MatchError("unknown log format: xml"))
+	})
+})
+
+var _ = Describe("NewStdLogger", func() {
+	It("logs each line through the lager logger", func() {
+		buffer := gbytes.NewBuffer()
+		logger := lager.NewLogger("ServiceBackup")
+		logger.RegisterSink(lager.NewWriterSink(buffer, lager.INFO))
+
+		stdLogger := logging.NewStdLogger(logger.Session("alerts"))
+		stdLogger.Print("first line\nsecond line")
+
+		Eventually(buffer).Should(gbytes.Say(`"message":"ServiceBackup.alerts.first line"`))
+		Eventually(buffer).Should(gbytes.Say(`"message":"ServiceBackup.alerts.second line"`))
+	})
+})
+
+var _ = Describe("Flags", func() {
+	var (
+		flagSet *flag.FlagSet
+		flags   *logging.Flags
+	)
+
+	BeforeEach(func() {
+		flagSet = flag.NewFlagSet("service-backup", flag.ContinueOnError)
+		flags = logging.RegisterFlags(flagSet, logging.FormatJSON)
+	})
+
+	It("overrides the configured level", func() {
+		Expect(flagSet.Parse([]string{"-log-level", "error"})).To(Succeed())
+
+		logPath := filepath.Join(GinkgoT().TempDir(), "service-backup.log")
+		logger, err := flags.NewLogger("ServiceBackup", config.Logging{Level: "debug", File: &config.LogFile{Path: logPath}})
+		Expect(err).NotTo(HaveOccurred())
+
+		logger.Debug("dropped")
+		logger.Error("kept", errors.New("boom"))
+
+		contents, err := os.ReadFile(logPath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(contents)).NotTo(ContainSubstring("dropped"))
+		Expect(string(contents)).To(ContainSubstring("kept"))
+	})
+
+	It("rejects an invalid format from the command line even when the config is valid", func() {
+		Expect(flagSet.Parse([]string{"-log-format", "xml"})).To(Succeed())
+
+		_, err := flags.NewLogger("ServiceBackup", config.Logging{Format: logging.FormatPlain})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("uses the default format when neither the flags nor the config set one", func() {
+		Expect(flagSet.Parse(nil)).To(Succeed())
+
+		logPath := filepath.Join(GinkgoT().TempDir(), "service-backup.log")
+		logger, err := flags.NewLogger("ServiceBackup", config.Logging{File: &config.LogFile{Path: logPath}})
+		Expect(err).NotTo(HaveOccurred())
+
+		logger.Info("hello", lager.Data{"guid": "abc"})
+
+		contents, err := os.ReadFile(logPath)
+		Expect(err).NotTo(HaveOccurred())
+		var line map[string]interface{}
+		Expect(json.Unmarshal(contents, &line)).To(Succeed())
+		Expect(line).To(HaveKeyWithValue("message", "ServiceBackup.hello"))
+		Expect(line).To(HaveKeyWithValue("data", HaveKeyWithValue("guid", "abc")))
+		// The JSON format has a numeric log_level where pretty has a level name.
+		Expect(line).To(HaveKeyWithValue("log_level", BeNumerically("==", lager.INFO)))
+		Expect(line).NotTo(HaveKey("level"))
+	})
+})
@@ -0,0 +1,95 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/lager/v3"
+)
+
+// syslog severities, as defined by RFC 5424
+var severities = map[lager.LogLevel]int{
+	lager.DEBUG: 7,
+	lager.INFO:  6,
+	lager.ERROR: 3,
+	lager.FATAL: 2,
+}
+
+const facilityUser = 1
+
+// plainSink writes one line per log in the style of an RFC 5424 syslog
+// message, with the source as the message ID and the data as trailing JSON.
+type plainSink struct {
+	writer      io.Writer
+	minLogLevel lager.LogLevel
+	hostname    string
+	appName     string
+	pid         int
+	lock        sync.Mutex
+}
+
+func newPlainSink(writer io.Writer, minLogLevel lager.LogLevel, appName string) *plainSink {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "-"
+	}
+	return &plainSink{
+		writer:      writer,
+		minLogLevel: minLogLevel,
+		hostname:    hostname,
+		appName:     appName,
+		pid:         os.Getpid(),
+	}
+}
+
+func (s *plainSink) Log(log lager.LogFormat) {
+	if log.LogLevel < s.minLogLevel {
+		return
+	}
+
+	line := fmt.Sprintf("<%d>1 %s %s %s %d %s - %s\n",
+		facilityUser*8+severities[log.LogLevel],
+		parseTimestamp(log.Timestamp).UTC().Format("2006-01-02T15:04:05.000000Z"),
+		s.hostname,
+		s.appName,
+		s.pid,
+		log.Source,
+		formatMessage(log),
+	)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.writer.Write([]byte(line))
+}
+
+func formatMessage(log lager.LogFormat) string {
+	if len(log.Data) == 0 {
+		return log.Message
+	}
+	data, err := json.Marshal(log.Data)
+	if err != nil {
+		return log.Message
+	}
+	return log.Message + " " + string(data)
+}
+
+// parseTimestamp reads lager's timestamp, which is seconds since the epoch
+// with a fractional part.
+func parseTimestamp(timestamp string) time.Time {
+	seconds, err := strconv.ParseFloat(timestamp, 64)
+	if err != nil {
+		return time.Now()
+	}
+	return time.Unix(0, int64(seconds*float64(time.Second)))
+}
@@ -0,0 +1,34 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package logging
+
+import (
+	"bytes"
+	"log"
+	"strings"
+
+	"code.cloudfoundry.org/lager/v3"
+)
+
+// NewStdLogger returns a standard library logger that writes each line it is
+// given to logger, for components that only accept a *log.Logger.
+func NewStdLogger(logger lager.Logger) *log.Logger {
+	return log.New(&lagerWriter{logger: logger}, "", 0)
+}
+
+type lagerWriter struct {
+	logger lager.Logger
+}
+
+func (w *lagerWriter) Write(p []byte) (int, error) {
+	for _, line := range bytes.Split(bytes.TrimRight(p, "\n"), []byte("\n")) {
+		if message := strings.TrimSpace(string(line)); message != "" {
+			w.logger.Info(message)
+		}
+	}
+	return len(p), nil
+}
@@ -0,0 +1,46 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package logging
+
+import (
+	"log/syslog"
+
+	"code.cloudfoundry.org/lager/v3"
+)
+
+type syslogSink struct {
+	writer      *syslog.Writer
+	minLogLevel lager.LogLevel
+}
+
+// newSyslogSink connects to the syslog daemon at address over network, or
+// to the local daemon if network is empty.
+func newSyslogSink(network, address, tag string, minLogLevel lager.LogLevel) (*syslogSink, error) {
+	writer, err := syslog.Dial(network, address, syslog.LOG_USER|syslog.LOG_INFO, tag)
+	if err != nil {
+		return nil, err
+	}
+	return &syslogSink{writer: writer, minLogLevel: minLogLevel}, nil
+}
+
+func (s *syslogSink) Log(log lager.LogFormat) {
+	if log.LogLevel < s.minLogLevel {
+		return
+	}
+
+	message := log.Source + " " + formatMessage(log)
+	switch log.LogLevel {
+	case lager.DEBUG:
+		s.writer.Debug(message)
+	case lager.INFO:
+		s.writer.Info(message)
+	case lager.ERROR:
+		s.writer.Err(message)
+	case lager.FATAL:
+		s.writer.Crit(message)
+	}
+}
@@ -7,18 +7,30 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"os"
 	"os/signal"
+	"path"
+	"path/filepath"
 	"syscall"
+	"time"
 
 	"code.cloudfoundry.org/lager/v3"
-
 	alerts "github.com/pivotal-cf/service-alerts-client/client"
 	"github.com/pivotal-cf/service-backup/config"
+	"github.com/pivotal-cf/service-backup/control"
 	"github.com/pivotal-cf/service-backup/executor"
+	"github.com/pivotal-cf/service-backup/lease"
+	"github.com/pivotal-cf/service-backup/logging"
+	"github.com/pivotal-cf/service-backup/metrics"
+	"github.com/pivotal-cf/service-backup/notify"
 	"github.com/pivotal-cf/service-backup/process"
 	"github.com/pivotal-cf/service-backup/scheduler"
+	"github.com/pivotal-cf/service-backup/throttle"
+	"github.com/pivotal-cf/service-backup/tracing"
 	"github.com/pivotal-cf/service-backup/upload"
 )
 
@@ -26,21 +38,96 @@ func main() {
 	sigterms := make(chan os.Signal, 1)
 	signal.Notify(sigterms, syscall.SIGTERM)
 
-	logger := lager.NewLogger("ServiceBackup")
-	logger.RegisterSink(lager.NewPrettySink(os.Stdout, lager.INFO))
-	configPath := os.Args[1]
+	logFlags := logging.RegisterFlags(flag.CommandLine, logging.FormatPretty)
+	flag.Parse()
+
+	logger, err := logFlags.NewLogger("ServiceBackup", config.Logging{})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid logging flags: %s\n", err)
+		os.Exit(2)
+	}
+
+	configPath := flag.Arg(0)
 	backupConfig, err := config.Parse(configPath, logger)
 	if err != nil {
 		logger.Error("failed to parse config", err)
 		os.Exit(2)
 	}
 
-	uploader, err := upload.Initialize(&backupConfig, logger)
+	configuredLogger, err := logFlags.NewLogger("ServiceBackup", backupConfig.Logging)
+	if err != nil {
+		logger.Error("failed to configure logging", err)
+		os.Exit(2)
+	}
+	logger = configuredLogger
+
+	scheduleLocation, err := scheduler.ScheduleLocation(backupConfig.CronSchedule, backupConfig.CronTimezone)
+	if err != nil {
+		logger.Error("failed to parse cron schedule", err)
+		os.Exit(2)
+	}
+	uploader, err := upload.Initialize(&backupConfig, logger, upload.WithLocation(scheduleLocation))
 	if err != nil {
 		logger.Error("failed to initialize uploader", err)
 		os.Exit(2)
 	}
-	manager := process.NewManager()
+	managerOptions, err := process.OptionsFromConfig(backupConfig.ProcessPriority, logger)
+	if err != nil {
+		logger.Error("failed to configure process priority", err)
+		os.Exit(2)
+	}
+	managerOptions = append(managerOptions, process.OutputOptionsFromConfig(backupConfig.ChildOutput)...)
+	manager := process.NewManager(managerOptions...)
+
+	tracerProvider, shutdownTracing, err := tracing.NewTracerProvider(backupConfig.Tracing, backupConfig.DeploymentName, logger)
+	if err != nil {
+		logger.Error("failed to configure tracing", err)
+		os.Exit(2)
+	}
+
+	outputTail, err := executor.OutputTailFromConfig(backupConfig)
+	if err != nil {
+		logger.Error("failed to configure child output", err)
+		os.Exit(2)
+	}
+
+	var (
+		executorOptions = []executor.Option{
+			executor.WithTracerProvider(tracerProvider),
+			executor.WithOutputTail(outputTail),
+		}
+		schedulerOptions []scheduler.Option
+	)
+	if backupConfig.ChildOutput != nil && backupConfig.ChildOutput.Stream {
+		executorOptions = append(executorOptions, executor.WithStreamedOutput())
+	}
+	if backupConfig.Metrics != nil {
+		backupMetrics := metrics.New()
+		executorOptions = append(executorOptions, executor.WithObserver(backupMetrics))
+		schedulerOptions = append(schedulerOptions,
+			scheduler.WithNextRunFunc(backupMetrics.SetNextRun),
+			scheduler.WithStartFunc(backupMetrics.SetScheduledStart),
+		)
+		go func() {
+			if err := backupMetrics.ListenAndServe(backupConfig.Metrics.Address, logger); err != nil {
+				logger.Error("metrics server stopped", err)
+			}
+		}()
+	}
+
+	notifiers, err := notify.NotifiersFromConfig(backupConfig, logger)
+	if err != nil {
+		logger.Error("failed to configure notifications", err)
+		os.Exit(2)
+	}
+	var dispatcher *notify.Dispatcher
+	if len(notifiers) > 0 {
+		dispatcher = notify.NewDispatcher(logger, notifiers...)
+		executorOptions = append(executorOptions, executor.WithObserver(dispatcher))
+	}
+
+	status := control.NewStatus()
+	executorOptions = append(executorOptions, executor.WithObserver(status))
 
 	var backupExecutor executor.Executor
 	if backupConfig.NoDestinations() {
@@ -61,24 +148,245 @@ func main() {
 			backupConfig.ExitIfInProgress,
 			logger,
 			manager,
+			executorOptions...,
 		)
 	}
 
-	logFlags := log.Ldate | log.Ltime | log.Lmicroseconds | log.LUTC
-	alertsLogger := log.New(os.Stderr, "[ServiceBackup] ", logFlags)
+	alertsLogger := logging.NewStdLogger(logger.Session("alerts"))
 
 	var alertsClient *alerts.ServiceAlertsClient
 	if backupConfig.Alerts != nil {
 		alertsClient = alerts.New(backupConfig.Alerts.Config, alertsLogger)
 	}
+	alertChannels, err := notify.AlertChannelsFromConfig(backupConfig, logger)
+	if err != nil {
+		logger.Error("failed to configure alerts", err)
+		os.Exit(2)
+	}
+	for _, channel := range alertChannels {
+		schedulerOptions = append(schedulerOptions, scheduler.WithAlertChannel(channel))
+	}
+	if deadMansSwitch := backupConfig.DeadMansSwitch; deadMansSwitch != nil && deadMansSwitch.MaxAgeSeconds > 0 {
+		schedulerOptions = append(schedulerOptions, scheduler.WithMaxBackupAge(
+			time.Duration(deadMansSwitch.MaxAgeSeconds)*time.Second,
+			time.Duration(deadMansSwitch.CheckIntervalSeconds)*time.Second,
+		))
+		schedulerOptions = append(schedulerOptions, scheduler.WithLastSuccessState(lastSuccessStatePath(backupConfig)))
+	}
+
+	if rateLimit := backupConfig.AlertRateLimit; rateLimit != nil {
+		schedulerOptions = append(schedulerOptions, scheduler.WithRenotifyInterval(
+			time.Duration(rateLimit.RenotifyIntervalSeconds)*time.Second,
+		))
+		if rateLimit.DigestTime != "" {
+			digestAt, err := throttle.ParseTimeOfDay(rateLimit.DigestTime)
+			if err != nil {
+				logger.Error("failed to configure alert digest", err)
+				os.Exit(2)
+			}
+			schedulerOptions = append(schedulerOptions, scheduler.WithDailyDigest(digestAt))
+		}
+	}
+
+	if splay := backupConfig.Splay; splay != nil {
+		schedulerOptions = append(schedulerOptions, scheduler.WithSplay(
+			time.Duration(splay.MaxRandomSeconds)*time.Second,
+			time.Duration(splay.MaxOffsetSeconds)*time.Second,
+			splayInstanceKey(backupConfig, logger),
+		))
+	}
+
+	if catchUp := backupConfig.CatchUp; catchUp != nil {
+		if catchUp.StatePath == "" {
+			logger.Error("failed to configure catch-up runs", errors.New("catch_up.state_path must be set"))
+			os.Exit(2)
+		}
+		policy, err := scheduler.ParseCatchUpPolicy(catchUp.Policy)
+		if err != nil {
+			logger.Error("failed to configure catch-up runs", err)
+			os.Exit(2)
+		}
+		schedulerOptions = append(schedulerOptions, scheduler.WithCatchUp(
+			catchUp.StatePath,
+			policy,
+			time.Duration(catchUp.DelaySeconds)*time.Second,
+			time.Duration(catchUp.MinIntervalSeconds)*time.Second,
+		))
+	}
+
+	blackoutWindows, err := scheduler.BlackoutWindowsFromConfig(backupConfig.BlackoutWindows, backupConfig.CronTimezone)
+	if err != nil {
+		logger.Error("failed to configure blackout windows", err)
+		os.Exit(2)
+	}
+	schedulerOptions = append(schedulerOptions, scheduler.WithBlackoutWindows(blackoutWindows...))
+
+	if election := backupConfig.LeaderElection; election != nil {
+		backend, err := leaseBackend(*election, backupConfig.DeploymentName, uploader, logger)
+		if err != nil {
+			logger.Error("failed to configure leader election", err)
+			os.Exit(2)
+		}
+		holder := election.Holder
+		if holder == "" {
+			if holder, err = os.Hostname(); err != nil {
+				logger.Error("failed to configure leader election", err)
+				os.Exit(2)
+			}
+		}
+		schedulerOptions = append(schedulerOptions, scheduler.WithLeaderElection(
+			backend,
+			holder,
+			time.Duration(election.TTLSeconds)*time.Second,
+		))
+	}
+
+	scheduler := scheduler.NewScheduler(backupExecutor, backupConfig, alertsClient, logger, schedulerOptions...)
+	if apiConfig := backupConfig.ControlAPI; apiConfig != nil {
+		if apiConfig.Token == "" {
+			logger.Error("failed to start control API", errors.New("control_api.token must be set"))
+			os.Exit(2)
+		}
+		listener, err := control.Listen(apiConfig.SocketPath, apiConfig.Address)
+		if err != nil {
+			logger.Error("failed to start control API", err)
+			os.Exit(2)
+		}
+		server := control.NewServer(apiConfig.Token, scheduler, backupExecutor, status, logger)
+		go func() {
+			if err := server.Serve(listener); err != nil {
+				logger.Error("control API stopped", err)
+			}
+		}()
+	}
 
-	scheduler := scheduler.NewScheduler(backupExecutor, backupConfig, alertsClient, logger)
 	go func() {
 		<-sigterms
 		scheduler.Stop()
-		manager.Terminate()
-		logger.Info("All backup processes terminated. Exiting")
-		os.Exit(1)
+		exitCode, message := drainAndTerminate(backupConfig.Shutdown, backupExecutor, manager, logger)
+		if dispatcher != nil {
+			ctx, cancel := context.WithTimeout(context.Background(), finalReportTimeout)
+			dispatcher.Close(ctx)
+			cancel()
+		}
+		if err := shutdownTracing(context.Background()); err != nil {
+			logger.Error("failed to flush traces", err)
+		}
+		logger.Info(message, lager.Data{"exit_code": exitCode})
+		os.Exit(exitCode)
 	}()
 	scheduler.Run()
 }
+
+// Exit codes on SIGTERM, saying how far shutting down had to go.
+const (
+	exitDrained    = 0
+	exitTerminated = 1
+	exitKilled     = 3
+)
+
+// finalReportTimeout bounds how long cancelled runs are given to report how
+// they finished, and notifications still queued given to be sent, before
+// exiting.
+const finalReportTimeout = 5 * time.Second
+
+type drainer interface {
+	Drain(context.Context) bool
+}
+
+// drainAndTerminate refuses new runs and gives those in progress the drain
+// period to finish. Runs still going after it are cancelled, aborting their
+// uploads and sending their children SIGTERM, and children still running
+// after the kill period are sent SIGKILL. It returns the exit code and final
+// log message saying which of these happened.
+func drainAndTerminate(shutdown *config.Shutdown, backupExecutor executor.Executor, manager *process.Manager, logger lager.Logger) (int, string) {
+	var drainPeriod, killAfter time.Duration
+	if shutdown != nil {
+		drainPeriod = time.Duration(shutdown.DrainSeconds) * time.Second
+		killAfter = time.Duration(shutdown.KillAfterSeconds) * time.Second
+	}
+
+	backups, ok := backupExecutor.(drainer)
+	if !ok {
+		manager.Terminate()
+		return exitDrained, "No backups in progress. Exiting"
+	}
+
+	if drainPeriod > 0 {
+		logger.Info("Draining backups in progress", lager.Data{"drain_period": drainPeriod.String()})
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), drainPeriod)
+	drained := backups.Drain(ctx)
+	cancel()
+	if drained {
+		manager.Terminate()
+		return exitDrained, "All backups drained. Exiting"
+	}
+
+	logger.Info("Cancelling backups in progress", lager.Data{"kill_after": killAfter.String()})
+	backupExecutor.Cancel()
+	killed := manager.TerminateWithin(killAfter)
+
+	ctx, cancel = context.WithTimeout(context.Background(), finalReportTimeout)
+	backups.Drain(ctx)
+	cancel()
+
+	if killed {
+		return exitKilled, "Backup processes killed after not exiting on SIGTERM. Exiting"
+	}
+	return exitTerminated, "All backup processes terminated. Exiting"
+}
+
+type leaseStores interface {
+	LeaseBackend(destination, key string, logger lager.Logger) (lease.Backend, error)
+}
+
+// leaseBackend returns where the leader election lease is kept: in a local
+// file, or as an object in a destination's bucket or container.
+func leaseBackend(election config.LeaderElection, deploymentName string, stores leaseStores, logger lager.Logger) (lease.Backend, error) {
+	switch {
+	case election.LeasePath != "" && election.Destination != "":
+		return nil, errors.New("only one of leader_election.lease_path and leader_election.destination may be set")
+	case election.LeasePath != "":
+		return lease.NewFileBackend(election.LeasePath), nil
+	case election.Destination != "":
+		key := election.Key
+		if key == "" {
+			key = path.Join("service-backup", deploymentName, "leader.json")
+		}
+		return stores.LeaseBackend(election.Destination, key, logger)
+	default:
+		return nil, errors.New("one of leader_election.lease_path and leader_election.destination must be set")
+	}
+}
+
+// splayInstanceKey identifies this instance for its splay offset, by its
+// deployment and service instance, falling back to the hostname when the
+// service cannot be identified.
+func splayInstanceKey(backupConfig config.BackupConfig, logger lager.Logger) string {
+	var serviceInstanceID string
+	if backupConfig.ServiceIdentifierExecutable != "" {
+		id, err := executor.IdentifyService(backupConfig.ServiceIdentifierExecutable)
+		if err != nil {
+			logger.Error("failed to identify service for splay", err)
+		}
+		serviceInstanceID = id
+	}
+	if serviceInstanceID == "" {
+		serviceInstanceID, _ = os.Hostname()
+	}
+	return backupConfig.DeploymentName + "/" + serviceInstanceID
+}
+
+// lastSuccessStatePath is where the time of the last successful backup is kept
+// across restarts: dead_mans_switch.state_path, or else the catch-up state
+// file, or else a file in the temporary directory.
+func lastSuccessStatePath(backupConfig config.BackupConfig) string {
+	if statePath := backupConfig.DeadMansSwitch.StatePath; statePath != "" {
+		return statePath
+	}
+	if catchUp := backupConfig.CatchUp; catchUp != nil && catchUp.StatePath != "" {
+		return catchUp.StatePath
+	}
+	return filepath.Join(os.TempDir(), "service-backup-last-success.json")
+}
@@ -0,0 +1,154 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"code.cloudfoundry.org/lager/v3"
+	"github.com/pivotal-cf/service-backup/executor"
+	"github.com/pivotal-cf/service-backup/upload"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "service_backup"
+
+// Metrics records backup runs as Prometheus metrics. It is an
+// executor.Observer.
+type Metrics struct {
+	registry            *prometheus.Registry
+	lastSuccess         prometheus.Gauge
+	lastFailure         prometheus.Gauge
+	phaseDuration       *prometheus.HistogramVec
+	uploadedBytes       *prometheus.CounterVec
+	destinationFailures *prometheus.CounterVec
+	inProgress          prometheus.Gauge
+	nextRun             prometheus.Gauge
+	lastScheduledStart  prometheus.Gauge
+	lastStartDelay      prometheus.Gauge
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		lastSuccess: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "last_success_timestamp_seconds",
+			Help:      "Unix time at which the last successful backup finished.",
+		}),
+		lastFailure: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "last_failure_timestamp_seconds",
+			Help:      "Unix time at which the last failed backup finished.",
+		}),
+		phaseDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "phase_duration_seconds",
+			Help:      "Duration of each phase of a backup run.",
+			Buckets:   prometheus.ExponentialBuckets(1, 2, 16),
+		}, []string{"phase"}),
+		uploadedBytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "uploaded_bytes_total",
+			Help:      "Bytes successfully uploaded to each destination.",
+		}, []string{"destination"}),
+		destinationFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "destination_failures_total",
+			Help:      "Failed uploads to each destination, by error class.",
+		}, []string{"destination", "class"}),
+		inProgress: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "in_progress",
+			Help:      "Number of backup runs currently in progress.",
+		}),
+		nextRun: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "next_run_timestamp_seconds",
+			Help:      "Unix time at which the next backup is scheduled.",
+		}),
+		lastScheduledStart: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "last_scheduled_start_timestamp_seconds",
+			Help:      "Unix time at which the last scheduled backup actually started.",
+		}),
+		lastStartDelay: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "last_start_delay_seconds",
+			Help:      "Delay between when the last scheduled backup was due and when it started.",
+		}),
+	}
+
+	m.registry.MustRegister(
+		m.lastSuccess,
+		m.lastFailure,
+		m.phaseDuration,
+		m.uploadedBytes,
+		m.destinationFailures,
+		m.inProgress,
+		m.nextRun,
+		m.lastScheduledStart,
+		m.lastStartDelay,
+	)
+	return m
+}
+
+func (m *Metrics) RunStarted(executor.RunReport) {
+	m.inProgress.Inc()
+}
+
+func (m *Metrics) RunFinished(report executor.RunReport) {
+	// Runs rejected because another is in progress never started.
+	if report.FailedPhase != executor.PhaseStart {
+		m.inProgress.Dec()
+	}
+
+	for phase, duration := range report.Durations {
+		m.phaseDuration.WithLabelValues(string(phase)).Observe(duration.Seconds())
+	}
+
+	for _, destination := range report.Destinations {
+		if destination.Err != nil {
+			m.destinationFailures.WithLabelValues(destination.Name, upload.ErrorClass(destination.Err)).Inc()
+		} else {
+			m.uploadedBytes.WithLabelValues(destination.Name).Add(float64(report.SizeInBytes))
+		}
+	}
+
+	if report.Succeeded() {
+		m.lastSuccess.Set(float64(report.FinishedAt.Unix()))
+	} else {
+		m.lastFailure.Set(float64(report.FinishedAt.Unix()))
+	}
+}
+
+func (m *Metrics) SetNextRun(next time.Time) {
+	m.nextRun.Set(float64(next.Unix()))
+}
+
+// SetScheduledStart records when a scheduled backup was due and when it
+// started, after any splay.
+func (m *Metrics) SetScheduledStart(scheduled, started time.Time) {
+	m.lastScheduledStart.Set(float64(started.Unix()))
+	m.lastStartDelay.Set(started.Sub(scheduled).Seconds())
+}
+
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// ListenAndServe serves the metrics on /metrics at address. It only returns
+// if the listener fails.
+func (m *Metrics) ListenAndServe(address string, logger lager.Logger) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m.Handler())
+
+	logger.Info("Serving metrics", lager.Data{"address": address})
+	return http.ListenAndServe(address, mux)
+}
@@ -0,0 +1,145 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package metrics_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"time"
+
+	"code.cloudfoundry.org/lager/v3"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-cf/service-backup/executor"
+	"github.com/pivotal-cf/service-backup/metrics"
+	"github.com/pivotal-cf/service-backup/process"
+	"github.com/pivotal-cf/service-backup/upload"
+)
+
+// partialUploader uploads to two destinations, one of which fails.
+type partialUploader struct {
+	failed string
+	err    error
+}
+
+func (u partialUploader) Upload(context.Context, string, lager.Logger, process.ProcessManager) error {
+	return upload.DestinationErrors{{Destination: u.failed, Err: u.err}}
+}
+
+func (u partialUploader) Name() string { return "partial" }
+
+func (u partialUploader) Destinations() []string { return []string{"s3", "scp"} }
+
+var _ = Describe("Metrics", func() {
+	var (
+		m        *metrics.Metrics
+		finished time.Time
+	)
+
+	scrape := func() string {
+		recorder := httptest.NewRecorder()
+		m.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+		body, err := io.ReadAll(recorder.Body)
+		Expect(err).NotTo(HaveOccurred())
+		return string(body)
+	}
+
+	BeforeEach(func() {
+		m = metrics.New()
+		finished = time.Unix(1700000000, 0)
+	})
+
+	It("tracks runs in progress", func() {
+		m.RunStarted(executor.RunReport{})
+		Expect(scrape()).To(ContainSubstring("service_backup_in_progress 1"))
+
+		m.RunFinished(executor.RunReport{FinishedAt: finished})
+		Expect(scrape()).To(ContainSubstring("service_backup_in_progress 0"))
+	})
+
+	It("does not count runs rejected because another is in progress", func() {
+		m.RunStarted(executor.RunReport{})
+		m.RunFinished(executor.RunReport{FailedPhase: executor.PhaseStart, Err: errors.New("in progress")})
+		Expect(scrape()).To(ContainSubstring("service_backup_in_progress 1"))
+	})
+
+	Context("when a run succeeds", func() {
+		BeforeEach(func() {
+			m.RunStarted(executor.RunReport{})
+			m.RunFinished(executor.RunReport{
+				FinishedAt:  finished,
+				SizeInBytes: 200,
+				Durations: map[executor.Phase]time.Duration{
+					executor.PhaseBackup: 3 * time.Second,
+					executor.PhaseUpload: 10 * time.Second,
+				},
+				Destinations: []executor.DestinationResult{{Name: "s3"}, {Name: "scp"}},
+			})
+		})
+
+		It("records the time of the last success", func() {
+			Expect(scrape()).To(ContainSubstring("service_backup_last_success_timestamp_seconds 1.7e+09"))
+			Expect(scrape()).To(ContainSubstring("service_backup_last_failure_timestamp_seconds 0"))
+		})
+
+		It("records the duration of each phase", func() {
+			Expect(scrape()).To(ContainSubstring(`service_backup_phase_duration_seconds_sum{phase="backup"} 3`))
+			Expect(scrape()).To(ContainSubstring(`service_backup_phase_duration_seconds_sum{phase="upload"} 10`))
+		})
+
+		It("records the bytes uploaded to each destination", func() {
+			Expect(scrape()).To(ContainSubstring(`service_backup_uploaded_bytes_total{destination="s3"} 200`))
+			Expect(scrape()).To(ContainSubstring(`service_backup_uploaded_bytes_total{destination="scp"} 200`))
+		})
+	})
+
+	Context("when a destination fails", func() {
+		BeforeEach(func() {
+			logger := lager.NewLogger("metrics-test")
+			backupExecutor := executor.NewExecutor(
+				partialUploader{failed: "s3", err: errors.New("AccessDenied: nope")},
+				GinkgoT().TempDir(),
+				"true",
+				"",
+				"",
+				false,
+				logger,
+				process.NewManager(),
+				executor.WithDirSizeFunc(func(string) (int64, error) { return 200, nil }),
+				executor.WithObserver(m),
+			)
+
+			Expect(backupExecutor.Execute()).To(MatchError("AccessDenied: nope"))
+		})
+
+		It("records the failure by destination and error class", func() {
+			Expect(scrape()).To(ContainSubstring(`service_backup_destination_failures_total{class="auth",destination="s3"} 1`))
+			Expect(scrape()).NotTo(ContainSubstring(`service_backup_uploaded_bytes_total{destination="s3"}`))
+		})
+
+		It("records the bytes uploaded to the destinations that succeeded", func() {
+			Expect(scrape()).To(ContainSubstring(`service_backup_uploaded_bytes_total{destination="scp"} 200`))
+		})
+
+		It("records the time of the last failure", func() {
+			Expect(scrape()).To(MatchRegexp(`service_backup_last_failure_timestamp_seconds \d\.\d+e\+09`))
+		})
+	})
+
+	It("records the next scheduled run", func() {
+		m.SetNextRun(finished)
+		Expect(scrape()).To(ContainSubstring("service_backup_next_run_timestamp_seconds 1.7e+09"))
+	})
+
+	It("records when the last scheduled run started and how late", func() {
+		m.SetScheduledStart(finished, finished.Add(90*time.Second))
+		Expect(scrape()).To(ContainSubstring("service_backup_last_scheduled_start_timestamp_seconds 1.70000009e+09"))
+		Expect(scrape()).To(ContainSubstring("service_backup_last_start_delay_seconds 90"))
+	})
+})
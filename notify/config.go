@@ -0,0 +1,65 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package notify
+
+import (
+	"fmt"
+
+	"code.cloudfoundry.org/lager/v3"
+	"github.com/pivotal-cf/service-backup/config"
+)
+
+// NotifiersFromConfig builds every notifier configured in backupConfig.
+func NotifiersFromConfig(backupConfig config.BackupConfig, logger lager.Logger) ([]Notifier, error) {
+	source := "service-backup"
+	if backupConfig.DeploymentName != "" {
+		source = "service-backup/" + backupConfig.DeploymentName
+	}
+
+	var notifiers []Notifier
+	for _, webhookConfig := range backupConfig.Webhooks {
+		webhook, err := NewWebhook(webhookConfig, source, logger)
+		if err != nil {
+			return nil, err
+		}
+		notifiers = append(notifiers, webhook)
+	}
+	if deadMansSwitch := backupConfig.DeadMansSwitch; deadMansSwitch != nil && deadMansSwitch.HeartbeatURL != "" {
+		heartbeat, err := NewHeartbeat(deadMansSwitch.HeartbeatURL, logger)
+		if err != nil {
+			return nil, err
+		}
+		notifiers = append(notifiers, heartbeat)
+	}
+	return notifiers, nil
+}
+
+// AlertChannelsFromConfig builds the alert channels configured in
+// backupConfig, other than the service alerts client.
+func AlertChannelsFromConfig(backupConfig config.BackupConfig, logger lager.Logger) ([]Notifier, error) {
+	severities, err := NewSeverities(backupConfig.AlertSeverities)
+	if err != nil {
+		return nil, fmt.Errorf("invalid alert_severities: %s", err)
+	}
+
+	var channels []Notifier
+	if backupConfig.Email != nil {
+		email, err := NewEmail(*backupConfig.Email, backupConfig.DeploymentName, severities, logger)
+		if err != nil {
+			return nil, err
+		}
+		channels = append(channels, email)
+	}
+	if backupConfig.PagerDuty != nil {
+		pagerDuty, err := NewPagerDuty(*backupConfig.PagerDuty, backupConfig.DeploymentName, severities, logger)
+		if err != nil {
+			return nil, err
+		}
+		channels = append(channels, pagerDuty)
+	}
+	return channels, nil
+}
@@ -0,0 +1,128 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package notify
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"code.cloudfoundry.org/lager/v3"
+	"github.com/pivotal-cf/service-backup/executor"
+)
+
+// Notifier sends events somewhere outside the process.
+type Notifier interface {
+	Name() string
+	Notify(Event) error
+}
+
+// Resolver is implemented by notifiers that can close an alert they raised
+// once the failure behind it has cleared.
+type Resolver interface {
+	Resolve(Event) error
+}
+
+// dispatchQueueSize bounds the events waiting to be sent. Events raised while
+// the queue is full are dropped and logged.
+const dispatchQueueSize = 100
+
+// Dispatcher turns the runs it observes into events and sends each of them
+// to every notifier. Events are sent from a queue in the background, so that
+// a slow notifier cannot hold up a backup, and a notifier that fails is
+// logged and otherwise ignored, so that notifications can never fail one.
+type Dispatcher struct {
+	notifiers []Notifier
+	logger    lager.Logger
+
+	lock   sync.Mutex
+	queue  chan Event
+	closed bool
+	done   chan struct{}
+}
+
+func NewDispatcher(logger lager.Logger, notifiers ...Notifier) *Dispatcher {
+	d := &Dispatcher{
+		notifiers: notifiers,
+		logger:    logger.Session("notify"),
+		queue:     make(chan Event, dispatchQueueSize),
+		done:      make(chan struct{}),
+	}
+	go d.send()
+	return d
+}
+
+func (d *Dispatcher) RunStarted(report executor.RunReport) {
+	d.dispatch(NewEvent(EventRunStarted, report))
+}
+
+func (d *Dispatcher) RunFinished(report executor.RunReport) {
+	for i := range report.Destinations {
+		destination := report.Destinations[i]
+		if destination.Err == nil {
+			continue
+		}
+		event := NewEvent(EventDestinationFailed, report)
+		event.Destination = &destination
+		d.dispatch(event)
+	}
+
+	d.dispatch(OutcomeEvent(report))
+	if report.CleanupErr != nil {
+		d.dispatch(NewEvent(EventCleanupFailed, report))
+	}
+}
+
+// Close stops accepting events and waits for those queued to be sent, or for
+// ctx to be done, reporting whether they all were.
+func (d *Dispatcher) Close(ctx context.Context) bool {
+	d.lock.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.queue)
+	}
+	d.lock.Unlock()
+
+	select {
+	case <-d.done:
+		return true
+	case <-ctx.Done():
+		d.logger.Info("Gave up waiting for notifications to be sent", lager.Data{"queued": len(d.queue)})
+		return false
+	}
+}
+
+func (d *Dispatcher) dispatch(event Event) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	data := lager.Data{"event_type": event.Type, "backup_guid": event.Report.BackupGUID}
+	if d.closed {
+		d.logger.Info("Notifications closed, dropping event", data)
+		return
+	}
+	select {
+	case d.queue <- event:
+	default:
+		d.logger.Error("failed to queue notification", errors.New("notification queue is full"), data)
+	}
+}
+
+func (d *Dispatcher) send() {
+	defer close(d.done)
+	for event := range d.queue {
+		for _, n := range d.notifiers {
+			if err := n.Notify(event); err != nil {
+				d.logger.Error("failed to send notification", err, lager.Data{
+					"notifier":    n.Name(),
+					"event_type":  event.Type,
+					"backup_guid": event.Report.BackupGUID,
+				})
+			}
+		}
+	}
+}
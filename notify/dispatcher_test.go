@@ -0,0 +1,211 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package notify_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"code.cloudfoundry.org/lager/v3"
+	"github.com/pivotal-cf/service-backup/executor"
+	"github.com/pivotal-cf/service-backup/notify"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+// blockingNotifier blocks every notification until released.
+type blockingNotifier struct {
+	release chan struct{}
+}
+
+func (n blockingNotifier) Name() string {
+	return "blocking"
+}
+
+func (n blockingNotifier) Notify(notify.Event) error {
+	<-n.release
+	return nil
+}
+
+type fakeNotifier struct {
+	events    []notify.Event
+	notifyErr error
+}
+
+func (n *fakeNotifier) Name() string {
+	return "fake"
+}
+
+func (n *fakeNotifier) Notify(event notify.Event) error {
+	n.events = append(n.events, event)
+	return n.notifyErr
+}
+
+func eventTypes(events []notify.Event) []notify.EventType {
+	var types []notify.EventType
+	for _, e := range events {
+		types = append(types, e.Type)
+	}
+	return types
+}
+
+var _ = Describe("Dispatcher", func() {
+	var (
+		notifier   *fakeNotifier
+		dispatcher *notify.Dispatcher
+		log        *gbytes.Buffer
+	)
+
+	BeforeEach(func() {
+		notifier = new(fakeNotifier)
+		log = gbytes.NewBuffer()
+		logger := lager.NewLogger("dispatcher-test")
+		logger.RegisterSink(lager.NewWriterSink(log, lager.DEBUG))
+		dispatcher = notify.NewDispatcher(logger, notifier)
+	})
+
+	sent := func() []notify.Event {
+		Expect(dispatcher.Close(context.Background())).To(BeTrue())
+		return notifier.events
+	}
+
+	It("sends an event when a run starts", func() {
+		dispatcher.RunStarted(executor.RunReport{BackupGUID: "guid"})
+
+		Expect(eventTypes(sent())).To(Equal([]notify.EventType{notify.EventRunStarted}))
+		Expect(sent()[0].Report.BackupGUID).To(Equal("guid"))
+		Expect(sent()[0].ID).NotTo(BeEmpty())
+	})
+
+	It("sends an event when a run succeeds", func() {
+		dispatcher.RunFinished(executor.RunReport{BackupGUID: "guid"})
+
+		Expect(eventTypes(sent())).To(Equal([]notify.EventType{notify.EventRunSucceeded}))
+	})
+
+	It("sends an event for each failed destination before the run failure", func() {
+		uploadErr := errors.New("upload failed")
+		dispatcher.RunFinished(executor.RunReport{
+			Err: uploadErr,
+			Destinations: []executor.DestinationResult{
+				{Name: "s3", Err: uploadErr},
+				{Name: "gcs"},
+			},
+		})
+
+		Expect(eventTypes(sent())).To(Equal([]notify.EventType{
+			notify.EventDestinationFailed,
+			notify.EventRunFailed,
+		}))
+		Expect(sent()[0].Destination.Name).To(Equal("s3"))
+		Expect(sent()[0].Data().Destination).To(Equal("s3"))
+		Expect(sent()[0].Data().Error).To(Equal("upload failed"))
+	})
+
+	It("sends a partial event when the upload failed for only some destinations", func() {
+		uploadErr := errors.New("upload failed")
+		dispatcher.RunFinished(executor.RunReport{
+			Err:         uploadErr,
+			FailedPhase: executor.PhaseUpload,
+			Destinations: []executor.DestinationResult{
+				{Name: "s3", Err: uploadErr},
+				{Name: "gcs"},
+			},
+		})
+
+		Expect(eventTypes(sent())).To(Equal([]notify.EventType{
+			notify.EventDestinationFailed,
+			notify.EventRunPartial,
+		}))
+		Expect(sent()[1].FailureClass()).To(Equal(notify.FailureClassPartial))
+		Expect(sent()[1].Summary()).To(Equal("A backup was uploaded to gcs but its upload to s3 has failed with the following error: upload failed"))
+	})
+
+	It("sends a cleanup event after the outcome when the cleanup failed", func() {
+		dispatcher.RunFinished(executor.RunReport{CleanupErr: errors.New("rm failed")})
+
+		Expect(eventTypes(sent())).To(Equal([]notify.EventType{
+			notify.EventRunSucceeded,
+			notify.EventCleanupFailed,
+		}))
+	})
+
+	It("sends a cancelled event when a run is cancelled", func() {
+		dispatcher.RunFinished(executor.RunReport{Err: errors.New("context canceled"), Cancelled: true})
+
+		Expect(eventTypes(sent())).To(Equal([]notify.EventType{notify.EventRunCancelled}))
+	})
+
+	It("logs notifier errors without stopping other notifiers", func() {
+		failing := &fakeNotifier{notifyErr: errors.New("unreachable")}
+		logger := lager.NewLogger("dispatcher-test")
+		logger.RegisterSink(lager.NewWriterSink(log, lager.DEBUG))
+		dispatcher = notify.NewDispatcher(logger, failing, notifier)
+
+		dispatcher.RunStarted(executor.RunReport{})
+
+		Expect(sent()).To(HaveLen(1))
+		Expect(log).To(gbytes.Say("failed to send notification"))
+		Expect(log).To(gbytes.Say("unreachable"))
+	})
+
+	It("does not hold up the run while a notifier is slow", func() {
+		release := make(chan struct{})
+		logger := lager.NewLogger("dispatcher-test")
+		dispatcher = notify.NewDispatcher(logger, blockingNotifier{release: release}, notifier)
+
+		done := make(chan struct{})
+		go func() {
+			dispatcher.RunStarted(executor.RunReport{})
+			dispatcher.RunFinished(executor.RunReport{})
+			close(done)
+		}()
+		Eventually(done).Should(BeClosed())
+
+		close(release)
+		Expect(eventTypes(sent())).To(Equal([]notify.EventType{notify.EventRunStarted, notify.EventRunSucceeded}))
+	})
+
+	It("stops waiting for queued events when closing times out", func() {
+		logger := lager.NewLogger("dispatcher-test")
+		logger.RegisterSink(lager.NewWriterSink(log, lager.DEBUG))
+		dispatcher = notify.NewDispatcher(logger, blockingNotifier{release: make(chan struct{})})
+		dispatcher.RunStarted(executor.RunReport{})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		Expect(dispatcher.Close(ctx)).To(BeFalse())
+		Expect(log).To(gbytes.Say("Gave up waiting for notifications to be sent"))
+	})
+
+	It("drops events raised after closing", func() {
+		Expect(dispatcher.Close(context.Background())).To(BeTrue())
+
+		dispatcher.RunStarted(executor.RunReport{})
+
+		Expect(notifier.events).To(BeEmpty())
+		Expect(log).To(gbytes.Say("Notifications closed, dropping event"))
+	})
+})
+
+var _ = Describe("ParseEventTypes", func() {
+	It("selects every event type when none are named", func() {
+		types, err := notify.ParseEventTypes(nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(types).To(HaveLen(7))
+		Expect(types).To(HaveKey(notify.EventRunPartial))
+		Expect(types).To(HaveKey(notify.EventCleanupFailed))
+	})
+
+	It("returns an error for an unknown event type", func() {
+		_, err := notify.ParseEventTypes([]string{"run_failed", "run_exploded"})
+		Expect(err).To(MatchError("unknown event type: run_exploded"))
+	})
+})
@@ -0,0 +1,226 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package notify
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"code.cloudfoundry.org/lager/v3"
+	"github.com/pivotal-cf/service-backup/config"
+)
+
+const (
+	TLSStartTLS = "starttls"
+	TLSImplicit = "tls"
+	TLSNone     = "none"
+
+	DefaultEmailSubject = `{{.Title}}{{with .ServiceInstanceID}}: {{.}}{{end}}`
+	DefaultEmailBody    = `{{.Summary}}
+
+Deployment: {{.DeploymentName}}
+{{with .BackupGUID}}Backup GUID: {{.}}
+{{end}}{{with .ServiceInstanceID}}Service instance: {{.}}
+{{end}}{{with .FailedPhase}}Failed phase: {{.}}
+{{end}}{{if not .StartedAt.IsZero}}Started at: {{.StartedAt.UTC.Format "2006-01-02T15:04:05Z07:00"}}
+{{end}}`
+
+	smtpTimeout = time.Minute
+)
+
+// Email sends events as plain text mail through an SMTP server.
+type Email struct {
+	address        string
+	host           string
+	tlsMode        string
+	tlsConfig      *tls.Config
+	auth           smtp.Auth
+	from           string
+	to             []string
+	subject        *template.Template
+	body           *template.Template
+	deploymentName string
+	severities     Severities
+	logger         lager.Logger
+}
+
+func NewEmail(conf config.Email, deploymentName string, severities Severities, logger lager.Logger) (*Email, error) {
+	if conf.Host == "" {
+		return nil, errors.New("email.host must be set")
+	}
+	if conf.From == "" || len(conf.To) == 0 {
+		return nil, errors.New("email.from and email.to must be set")
+	}
+
+	tlsMode := conf.TLS
+	if tlsMode == "" {
+		tlsMode = TLSStartTLS
+	}
+	port := conf.Port
+	switch tlsMode {
+	case TLSStartTLS:
+		if port == 0 {
+			port = 587
+		}
+	case TLSImplicit:
+		if port == 0 {
+			port = 465
+		}
+	case TLSNone:
+		if port == 0 {
+			port = 25
+		}
+	default:
+		return nil, fmt.Errorf("unknown email tls mode: %s", conf.TLS)
+	}
+
+	subject, err := parseTemplate("subject", conf.Subject, DefaultEmailSubject)
+	if err != nil {
+		return nil, err
+	}
+	body, err := parseTemplate("body", conf.Body, DefaultEmailBody)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &Email{
+		address: net.JoinHostPort(conf.Host, strconv.Itoa(port)),
+		host:    conf.Host,
+		tlsMode: tlsMode,
+		tlsConfig: &tls.Config{
+			ServerName:         conf.Host,
+			InsecureSkipVerify: conf.SkipSSLValidation,
+		},
+		from:           conf.From,
+		to:             conf.To,
+		subject:        subject,
+		body:           body,
+		deploymentName: deploymentName,
+		severities:     severities,
+		logger:         logger.Session("email"),
+	}
+	if conf.Username != "" {
+		e.auth = smtp.PlainAuth("", conf.Username, conf.Password, conf.Host)
+	}
+	return e, nil
+}
+
+func (e *Email) Name() string {
+	return "email"
+}
+
+func (e *Email) Notify(event Event) error {
+	data := NewTemplateData(event, e.deploymentName, nil)
+	data.Severity = e.severities.Of(event.FailureClass())
+
+	subject, err := render(e.subject, data)
+	if err != nil {
+		return err
+	}
+	body, err := render(e.body, data)
+	if err != nil {
+		return err
+	}
+
+	message := e.message(event, strings.TrimSpace(subject), body)
+	if err := e.send(message); err != nil {
+		return err
+	}
+	e.logger.Info("sent email", lager.Data{"event_type": event.Type, "recipients": len(e.to)})
+	return nil
+}
+
+func (e *Email) message(event Event, subject, body string) []byte {
+	var message bytes.Buffer
+	fmt.Fprintf(&message, "From: %s\r\n", e.from)
+	fmt.Fprintf(&message, "To: %s\r\n", strings.Join(e.to, ", "))
+	fmt.Fprintf(&message, "Subject: %s\r\n", encodeHeader(subject))
+	fmt.Fprintf(&message, "Date: %s\r\n", event.Time.Format(time.RFC1123Z))
+	fmt.Fprintf(&message, "Message-ID: <%s@service-backup>\r\n", event.ID)
+	message.WriteString("MIME-Version: 1.0\r\n")
+	message.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	message.WriteString("\r\n")
+	message.WriteString(strings.NewReplacer("\r\n", "\r\n", "\r", "\r\n", "\n", "\r\n").Replace(body))
+	return message.Bytes()
+}
+
+// encodeHeader folds line breaks in rendered data, which would otherwise end
+// the header early, and RFC 2047 encodes anything that is not plain ASCII.
+func encodeHeader(value string) string {
+	value = strings.NewReplacer("\r\n", " ", "\r", " ", "\n", " ").Replace(value)
+	return mime.QEncoding.Encode("UTF-8", value)
+}
+
+func (e *Email) send(message []byte) error {
+	dialer := &net.Dialer{Timeout: smtpTimeout}
+
+	var (
+		conn net.Conn
+		err  error
+	)
+	if e.tlsMode == TLSImplicit {
+		conn, err = tls.DialWithDialer(dialer, "tcp", e.address, e.tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", e.address)
+	}
+	if err != nil {
+		return fmt.Errorf("error connecting to SMTP server: %s", err)
+	}
+	conn.SetDeadline(time.Now().Add(smtpTimeout))
+
+	client, err := smtp.NewClient(conn, e.host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("error connecting to SMTP server: %s", err)
+	}
+	defer client.Close()
+
+	if e.tlsMode == TLSStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return errors.New("SMTP server does not support STARTTLS")
+		}
+		if err := client.StartTLS(e.tlsConfig); err != nil {
+			return fmt.Errorf("error starting TLS: %s", err)
+		}
+	}
+
+	if e.auth != nil {
+		if err := client.Auth(e.auth); err != nil {
+			return fmt.Errorf("error authenticating with SMTP server: %s", err)
+		}
+	}
+
+	if err := client.Mail(e.from); err != nil {
+		return fmt.Errorf("error sending email: %s", err)
+	}
+	for _, recipient := range e.to {
+		if err := client.Rcpt(recipient); err != nil {
+			return fmt.Errorf("error sending email to %s: %s", recipient, err)
+		}
+	}
+
+	writer, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("error sending email: %s", err)
+	}
+	if _, err := writer.Write(message); err != nil {
+		return fmt.Errorf("error sending email: %s", err)
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("error sending email: %s", err)
+	}
+	return client.Quit()
+}
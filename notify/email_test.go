@@ -0,0 +1,338 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package notify_test
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"mime"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/lager/v3"
+	"github.com/pivotal-cf/service-backup/config"
+	"github.com/pivotal-cf/service-backup/executor"
+	"github.com/pivotal-cf/service-backup/notify"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// fakeSMTPServer accepts one mail per connection and records the commands
+// and message it was sent.
+type fakeSMTPServer struct {
+	listener  net.Listener
+	tlsConfig *tls.Config
+	startTLS  bool
+	commands  chan string
+	messages  chan string
+	rejectTo  string
+	extension string
+}
+
+func newFakeSMTPServer() *fakeSMTPServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	Expect(err).NotTo(HaveOccurred())
+	return startFakeSMTPServer(&fakeSMTPServer{listener: listener})
+}
+
+// newFakeTLSSMTPServer serves over TLS from the first byte, or only once the
+// client has sent STARTTLS when startTLS is set.
+func newFakeTLSSMTPServer(startTLS bool) *fakeSMTPServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	Expect(err).NotTo(HaveOccurred())
+	tlsConfig := testTLSConfig()
+	if !startTLS {
+		listener = tls.NewListener(listener, tlsConfig)
+	}
+	return startFakeSMTPServer(&fakeSMTPServer{listener: listener, tlsConfig: tlsConfig, startTLS: startTLS})
+}
+
+// testTLSConfig borrows the self-signed certificate httptest serves with.
+func testTLSConfig() *tls.Config {
+	httpsServer := httptest.NewTLSServer(http.NotFoundHandler())
+	defer httpsServer.Close()
+	return &tls.Config{Certificates: httpsServer.TLS.Certificates}
+}
+
+func startFakeSMTPServer(server *fakeSMTPServer) *fakeSMTPServer {
+	server.commands = make(chan string, 100)
+	server.messages = make(chan string, 10)
+	server.extension = "AUTH PLAIN"
+	go server.serve()
+	return server
+}
+
+func (s *fakeSMTPServer) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (s *fakeSMTPServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeSMTPServer) handle(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+	reply("220 fake ESMTP")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command := strings.TrimRight(line, "\r\n")
+		s.commands <- command
+
+		switch {
+		case strings.HasPrefix(command, "EHLO"):
+			reply("250-fake")
+			if s.startTLS {
+				reply("250-STARTTLS")
+			}
+			reply("250 " + s.extension)
+		case command == "STARTTLS" && s.startTLS:
+			reply("220 ready to start TLS")
+			tlsConn := tls.Server(conn, s.tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn = tlsConn
+			reader = bufio.NewReader(conn)
+		case strings.HasPrefix(command, "RCPT TO:") && s.rejectTo != "" && strings.Contains(command, s.rejectTo):
+			reply("550 no such user")
+		case command == "DATA":
+			reply("354 go ahead")
+			var message strings.Builder
+			for {
+				dataLine, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if dataLine == ".\r\n" {
+					break
+				}
+				message.WriteString(dataLine)
+			}
+			s.messages <- message.String()
+			reply("250 queued")
+		case command == "QUIT":
+			reply("221 bye")
+			return
+		case strings.HasPrefix(command, "AUTH"):
+			reply("235 authenticated")
+		default:
+			reply("250 ok")
+		}
+	}
+}
+
+func (s *fakeSMTPServer) receivedCommands() []string {
+	var commands []string
+	for {
+		select {
+		case command := <-s.commands:
+			commands = append(commands, command)
+		default:
+			return commands
+		}
+	}
+}
+
+var _ = Describe("Email", func() {
+	var (
+		server *fakeSMTPServer
+		conf   config.Email
+		event  notify.Event
+	)
+
+	BeforeEach(func() {
+		server = newFakeSMTPServer()
+		conf = config.Email{
+			Host:     "127.0.0.1",
+			Port:     server.port(),
+			TLS:      notify.TLSNone,
+			Username: "backup",
+			Password: "secret",
+			From:     "backups@example.com",
+			To:       []string{"ops@example.com", "dba@example.com"},
+		}
+		event = notify.Event{
+			ID:   "event-id",
+			Type: notify.EventRunFailed,
+			Time: time.Date(2026, 10, 19, 3, 0, 0, 0, time.UTC),
+			Report: executor.RunReport{
+				BackupGUID:        "backup-guid",
+				ServiceInstanceID: "instance-id",
+				StartedAt:         time.Date(2026, 10, 19, 2, 59, 0, 0, time.UTC),
+				FailedPhase:       executor.PhaseUpload,
+				Err:               errors.New("upload failed"),
+			},
+		}
+	})
+
+	AfterEach(func() {
+		server.listener.Close()
+	})
+
+	notifier := func() *notify.Email {
+		email, err := notify.NewEmail(conf, "redis-deployment", nil, lager.NewLogger("email-test"))
+		Expect(err).NotTo(HaveOccurred())
+		return email
+	}
+
+	It("authenticates and sends the default message to every recipient", func() {
+		Expect(notifier().Notify(event)).To(Succeed())
+
+		var message string
+		Eventually(server.messages).Should(Receive(&message))
+		Expect(message).To(ContainSubstring("From: backups@example.com\r\n"))
+		Expect(message).To(ContainSubstring("To: ops@example.com, dba@example.com\r\n"))
+		Expect(message).To(ContainSubstring("Subject: Service Backup Failed: instance-id\r\n"))
+		Expect(message).To(ContainSubstring("A backup run has failed with the following error: upload failed\r\n"))
+		Expect(message).To(ContainSubstring("Deployment: redis-deployment\r\n"))
+		Expect(message).To(ContainSubstring("Failed phase: upload\r\n"))
+
+		commands := server.receivedCommands()
+		credentials := base64.StdEncoding.EncodeToString([]byte("\x00backup\x00secret"))
+		Expect(commands).To(ContainElement("AUTH PLAIN " + credentials))
+		Expect(commands).To(ContainElement("MAIL FROM:<backups@example.com>"))
+		Expect(commands).To(ContainElement("RCPT TO:<ops@example.com>"))
+		Expect(commands).To(ContainElement("RCPT TO:<dba@example.com>"))
+	})
+
+	It("renders custom subject and body templates", func() {
+		conf.Subject = "[{{.DeploymentName}}] {{.Type}}"
+		conf.Body = "Backup {{.BackupGUID}} failed in {{.FailedPhase}}"
+
+		Expect(notifier().Notify(event)).To(Succeed())
+
+		var message string
+		Eventually(server.messages).Should(Receive(&message))
+		Expect(message).To(ContainSubstring("Subject: [redis-deployment] run_failed\r\n"))
+		Expect(message).To(HaveSuffix("\r\nBackup backup-guid failed in upload\r\n"))
+	})
+
+	It("folds line breaks in the subject and encodes non-ASCII text", func() {
+		conf.Subject = "{{.Error}}"
+		event.Report.Err = errors.New("ошибка\r\nBcc: attacker@example.com\rX-Injected: yes")
+
+		Expect(notifier().Notify(event)).To(Succeed())
+
+		var message string
+		Eventually(server.messages).Should(Receive(&message))
+		header, body, _ := strings.Cut(message, "\r\n\r\n")
+		Expect(header).NotTo(ContainSubstring("\r\nBcc:"))
+		Expect(header).NotTo(ContainSubstring("\rX-Injected"))
+		Expect(header).To(ContainSubstring("Subject: =?UTF-8?q?"))
+		Expect(body).NotTo(MatchRegexp("\r[^\n]"))
+
+		var subject string
+		for _, line := range strings.Split(header, "\r\n") {
+			if value, ok := strings.CutPrefix(line, "Subject: "); ok {
+				var err error
+				subject, err = new(mime.WordDecoder).DecodeHeader(value)
+				Expect(err).NotTo(HaveOccurred())
+			}
+		}
+		Expect(subject).To(Equal("ошибка Bcc: attacker@example.com X-Injected: yes"))
+	})
+
+	Context("over TLS", func() {
+		BeforeEach(func() {
+			conf.SkipSSLValidation = true
+		})
+
+		It("sends after upgrading the connection with STARTTLS", func() {
+			server.listener.Close()
+			server = newFakeTLSSMTPServer(true)
+			conf.Port = server.port()
+			conf.TLS = notify.TLSStartTLS
+
+			Expect(notifier().Notify(event)).To(Succeed())
+
+			var message string
+			Eventually(server.messages).Should(Receive(&message))
+			Expect(message).To(ContainSubstring("Subject: Service Backup Failed: instance-id\r\n"))
+			Expect(server.receivedCommands()).To(ContainElements("STARTTLS", "MAIL FROM:<backups@example.com>"))
+		})
+
+		It("sends over implicit TLS", func() {
+			server.listener.Close()
+			server = newFakeTLSSMTPServer(false)
+			conf.Port = server.port()
+			conf.TLS = notify.TLSImplicit
+
+			Expect(notifier().Notify(event)).To(Succeed())
+
+			var message string
+			Eventually(server.messages).Should(Receive(&message))
+			Expect(message).To(ContainSubstring("Subject: Service Backup Failed: instance-id\r\n"))
+			Expect(server.receivedCommands()).NotTo(ContainElement("STARTTLS"))
+		})
+
+		It("refuses a certificate it cannot verify", func() {
+			server.listener.Close()
+			server = newFakeTLSSMTPServer(false)
+			conf.Port = server.port()
+			conf.TLS = notify.TLSImplicit
+			conf.SkipSSLValidation = false
+
+			Expect(notifier().Notify(event)).To(MatchError(ContainSubstring("error connecting to SMTP server")))
+		})
+	})
+
+	It("returns an error when a recipient is rejected", func() {
+		server.rejectTo = "dba@example.com"
+
+		Expect(notifier().Notify(event)).To(MatchError(ContainSubstring("error sending email to dba@example.com")))
+	})
+
+	It("refuses to send without STARTTLS when STARTTLS is required", func() {
+		conf.TLS = notify.TLSStartTLS
+
+		Expect(notifier().Notify(event)).To(MatchError("SMTP server does not support STARTTLS"))
+	})
+
+	It("returns an error when the server cannot be reached", func() {
+		server.listener.Close()
+
+		Expect(notifier().Notify(event)).To(MatchError(ContainSubstring("error connecting to SMTP server")))
+	})
+
+	Describe("NewEmail", func() {
+		It("rejects an unknown TLS mode", func() {
+			conf.TLS = "ssl3"
+			_, err := notify.NewEmail(conf, "", nil, lager.NewLogger("email-test"))
+			Expect(err).To(MatchError("unknown email tls mode: ssl3"))
+		})
+
+		It("rejects an invalid template", func() {
+			conf.Subject = "{{.Nope"
+			_, err := notify.NewEmail(conf, "", nil, lager.NewLogger("email-test"))
+			Expect(err).To(MatchError(ContainSubstring("invalid subject template")))
+		})
+
+		It("requires recipients", func() {
+			conf.To = nil
+			_, err := notify.NewEmail(conf, "", nil, lager.NewLogger("email-test"))
+			Expect(err).To(MatchError("email.from and email.to must be set"))
+		})
+	})
+})
@@ -0,0 +1,280 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package notify
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pivotal-cf/service-backup/executor"
+	"github.com/pivotal-cf/service-backup/upload"
+	"github.com/satori/go.uuid"
+)
+
+type EventType string
+
+const (
+	EventRunStarted        EventType = "run_started"
+	EventRunSucceeded      EventType = "run_succeeded"
+	EventRunFailed         EventType = "run_failed"
+	EventRunCancelled      EventType = "run_cancelled"
+	EventDestinationFailed EventType = "destination_failed"
+
+	// EventRunPartial is the outcome of a run whose upload failed for some
+	// destinations but succeeded for others.
+	EventRunPartial EventType = "run_partial"
+
+	// EventCleanupFailed is raised, as well as the outcome, for a run whose
+	// backup succeeded but whose cleanup command failed.
+	EventCleanupFailed EventType = "cleanup_failed"
+
+	// EventBackupOverdue is raised by the scheduler, not for any one run,
+	// when no backup has succeeded for longer than the configured maximum
+	// age. Its report carries only the error describing how overdue it is.
+	EventBackupOverdue EventType = "backup_overdue"
+
+	// EventRunRecovered is raised by the scheduler for the first successful
+	// run of a service instance after failed ones.
+	EventRunRecovered EventType = "run_recovered"
+
+	// EventAlertDigest is raised by the scheduler once a day to summarise the
+	// failures it did not alert on because they repeated earlier ones.
+	EventAlertDigest EventType = "alert_digest"
+)
+
+var eventTypes = []EventType{
+	EventRunStarted,
+	EventRunSucceeded,
+	EventRunFailed,
+	EventRunCancelled,
+	EventDestinationFailed,
+	EventRunPartial,
+	EventCleanupFailed,
+}
+
+// ParseEventTypes converts event names from the config into the set of event
+// types they name. No names at all selects every event type.
+func ParseEventTypes(names []string) (map[EventType]bool, error) {
+	selected := map[EventType]bool{}
+	if len(names) == 0 {
+		for _, t := range eventTypes {
+			selected[t] = true
+		}
+		return selected, nil
+	}
+
+	for _, name := range names {
+		if !isEventType(EventType(name)) {
+			return nil, fmt.Errorf("unknown event type: %s", name)
+		}
+		selected[EventType(name)] = true
+	}
+	return selected, nil
+}
+
+func isEventType(t EventType) bool {
+	for _, known := range eventTypes {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Event is something that happened during a backup run that notifiers may
+// want to tell someone about.
+type Event struct {
+	ID     string
+	Type   EventType
+	Time   time.Time
+	Report executor.RunReport
+
+	// Destination is set for EventDestinationFailed only.
+	Destination *executor.DestinationResult
+
+	// Message describes events that are not about a single run, such as
+	// recovery notices and digests, in place of the report's error.
+	Message string
+
+	// NextRun is when the next backup is scheduled. Only the scheduler knows,
+	// so it is set on alerts but not on notifications.
+	NextRun time.Time
+}
+
+func NewEvent(t EventType, report executor.RunReport) Event {
+	return Event{
+		ID:     fmt.Sprint(uuid.NewV4()),
+		Type:   t,
+		Time:   time.Now(),
+		Report: report,
+	}
+}
+
+// OutcomeEvent returns the event that says how a finished run ended.
+func OutcomeEvent(report executor.RunReport) Event {
+	switch {
+	case report.Succeeded():
+		return NewEvent(EventRunSucceeded, report)
+	case report.Cancelled:
+		return NewEvent(EventRunCancelled, report)
+	case report.Partial():
+		return NewEvent(EventRunPartial, report)
+	default:
+		return NewEvent(EventRunFailed, report)
+	}
+}
+
+const (
+	FailureClassCancelled = "cancelled"
+	FailureClassOverdue   = "overdue"
+	FailureClassPartial   = "partial"
+	FailureClassCleanup   = "cleanup"
+)
+
+// FailureClass buckets the reason a run failed: upload failures that left
+// the backup on some destinations as partial, other upload failures by the
+// kind of error the destination returned, cancelled runs together, and any
+// other failure by the phase it happened in.
+func FailureClass(report executor.RunReport) string {
+	switch {
+	case report.Succeeded():
+		return ""
+	case report.Cancelled:
+		return FailureClassCancelled
+	case report.Partial():
+		return FailureClassPartial
+	case report.FailedPhase == executor.PhaseUpload:
+		return upload.ErrorClass(report.Err)
+	default:
+		return string(report.FailedPhase)
+	}
+}
+
+// Title is a short, human readable description of the event, suitable for an
+// alert subject.
+func (e Event) Title() string {
+	switch e.Type {
+	case EventRunStarted:
+		return "Service Backup Started"
+	case EventRunSucceeded:
+		return "Service Backup Succeeded"
+	case EventBackupOverdue:
+		return "Service Backup Overdue"
+	case EventRunRecovered:
+		return "Service Backup Recovered"
+	case EventAlertDigest:
+		return "Service Backup Failure Digest"
+	case EventRunPartial:
+		return "Service Backup Partially Failed"
+	case EventCleanupFailed:
+		return "Service Backup Cleanup Failed"
+	default:
+		return "Service Backup Failed"
+	}
+}
+
+// Summary describes the event in a sentence or, for digests, a few lines.
+func (e Event) Summary() string {
+	if e.Message != "" {
+		return e.Message
+	}
+
+	switch e.Type {
+	case EventRunStarted:
+		return "A backup run has started"
+	case EventRunSucceeded:
+		return "A backup run has succeeded"
+	case EventBackupOverdue:
+		return fmt.Sprintf("No backup has succeeded recently: %s", e.Report.Err)
+	case EventDestinationFailed:
+		return fmt.Sprintf("A backup upload to %s has failed with the following error: %s", e.Destination.Name, e.Destination.Err)
+	case EventRunPartial:
+		failed, succeeded := e.Report.DestinationsByOutcome()
+		return fmt.Sprintf("A backup was uploaded to %s but its upload to %s has failed with the following error: %s",
+			strings.Join(succeeded, ", "), strings.Join(failed, ", "), e.Report.Err)
+	case EventCleanupFailed:
+		return fmt.Sprintf("A backup run has succeeded but its cleanup has failed with the following error: %s", e.Report.CleanupErr)
+	default:
+		return fmt.Sprintf("A backup run has failed with the following error: %s", e.Report.Err)
+	}
+}
+
+// FailureClass is the failure class of the event's run, or overdue for
+// EventBackupOverdue and cleanup for EventCleanupFailed.
+func (e Event) FailureClass() string {
+	switch e.Type {
+	case EventBackupOverdue:
+		return FailureClassOverdue
+	case EventCleanupFailed:
+		return FailureClassCleanup
+	default:
+		return FailureClass(e.Report)
+	}
+}
+
+// EventData is the JSON representation of an event's run report.
+type EventData struct {
+	BackupGUID        string            `json:"backup_guid"`
+	ServiceInstanceID string            `json:"service_instance_id,omitempty"`
+	StartedAt         time.Time         `json:"started_at"`
+	FinishedAt        *time.Time        `json:"finished_at,omitempty"`
+	DurationSeconds   float64           `json:"duration_in_seconds,omitempty"`
+	SizeInBytes       int64             `json:"size_in_bytes,omitempty"`
+	FailedPhase       string            `json:"failed_phase,omitempty"`
+	FailureClass      string            `json:"failure_class,omitempty"`
+	Error             string            `json:"error,omitempty"`
+	CleanupError      string            `json:"cleanup_error,omitempty"`
+	Destination       string            `json:"destination,omitempty"`
+	Destinations      []DestinationData `json:"destinations,omitempty"`
+	Message           string            `json:"message,omitempty"`
+	OutputTail        string            `json:"output_tail,omitempty"`
+}
+
+type DestinationData struct {
+	Name  string `json:"name"`
+	Error string `json:"error,omitempty"`
+}
+
+func (e Event) Data() EventData {
+	report := e.Report
+	data := EventData{
+		BackupGUID:        report.BackupGUID,
+		ServiceInstanceID: report.ServiceInstanceID,
+		StartedAt:         report.StartedAt,
+		SizeInBytes:       report.SizeInBytes,
+		FailedPhase:       string(report.FailedPhase),
+		FailureClass:      e.FailureClass(),
+		Message:           e.Message,
+		OutputTail:        report.OutputTail,
+	}
+	if !report.FinishedAt.IsZero() {
+		finishedAt := report.FinishedAt
+		data.FinishedAt = &finishedAt
+		data.DurationSeconds = report.Duration().Seconds()
+	}
+	if report.Err != nil {
+		data.Error = report.Err.Error()
+	}
+	if report.CleanupErr != nil {
+		data.CleanupError = report.CleanupErr.Error()
+	}
+	for _, d := range report.Destinations {
+		destination := DestinationData{Name: d.Name}
+		if d.Err != nil {
+			destination.Error = d.Err.Error()
+		}
+		data.Destinations = append(data.Destinations, destination)
+	}
+	if e.Destination != nil {
+		data.Destination = e.Destination.Name
+		if e.Destination.Err != nil {
+			data.Error = e.Destination.Err.Error()
+		}
+	}
+	return data
+}
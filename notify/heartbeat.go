@@ -0,0 +1,64 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package notify
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"code.cloudfoundry.org/lager/v3"
+)
+
+const heartbeatTimeout = 10 * time.Second
+
+// Heartbeat pings a URL after every successful run, so that a monitoring
+// service in the style of healthchecks.io can raise an alarm when the pings
+// stop coming.
+type Heartbeat struct {
+	url    string
+	client *http.Client
+	logger lager.Logger
+}
+
+func NewHeartbeat(heartbeatURL string, logger lager.Logger) (*Heartbeat, error) {
+	parsed, err := url.Parse(heartbeatURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("invalid heartbeat url: %q", heartbeatURL)
+	}
+
+	return &Heartbeat{
+		url:    heartbeatURL,
+		client: &http.Client{Timeout: heartbeatTimeout},
+		logger: logger.Session("heartbeat", lager.Data{"host": parsed.Host}),
+	}, nil
+}
+
+func (h *Heartbeat) Name() string {
+	return "heartbeat"
+}
+
+func (h *Heartbeat) Notify(event Event) error {
+	if event.Type != EventRunSucceeded {
+		return nil
+	}
+
+	response, err := h.client.Get(h.url)
+	if err != nil {
+		return fmt.Errorf("error sending heartbeat: %s", err)
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, response.Body)
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("heartbeat returned %s", response.Status)
+	}
+	h.logger.Info("sent heartbeat")
+	return nil
+}
@@ -0,0 +1,64 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package notify_test
+
+import (
+	"net/http"
+
+	"code.cloudfoundry.org/lager/v3"
+	"github.com/pivotal-cf/service-backup/notify"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("Heartbeat", func() {
+	var (
+		server    *ghttp.Server
+		heartbeat *notify.Heartbeat
+	)
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+
+		var err error
+		heartbeat, err = notify.NewHeartbeat(server.URL()+"/ping/check-id", lager.NewLogger("heartbeat-test"))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("pings the URL when a run succeeds", func() {
+		server.AppendHandlers(ghttp.CombineHandlers(
+			ghttp.VerifyRequest("GET", "/ping/check-id"),
+			ghttp.RespondWith(http.StatusOK, "OK"),
+		))
+
+		Expect(heartbeat.Notify(notify.Event{Type: notify.EventRunSucceeded})).To(Succeed())
+		Expect(server.ReceivedRequests()).To(HaveLen(1))
+	})
+
+	It("does not ping for other events", func() {
+		Expect(heartbeat.Notify(notify.Event{Type: notify.EventRunFailed})).To(Succeed())
+		Expect(heartbeat.Notify(notify.Event{Type: notify.EventRunStarted})).To(Succeed())
+		Expect(server.ReceivedRequests()).To(BeEmpty())
+	})
+
+	It("returns an error when the ping is rejected", func() {
+		server.AppendHandlers(ghttp.RespondWith(http.StatusNotFound, nil))
+
+		Expect(heartbeat.Notify(notify.Event{Type: notify.EventRunSucceeded})).To(MatchError("heartbeat returned 404 Not Found"))
+	})
+
+	It("rejects an invalid URL", func() {
+		_, err := notify.NewHeartbeat("not a url", lager.NewLogger("heartbeat-test"))
+		Expect(err).To(MatchError(`invalid heartbeat url: "not a url"`))
+	})
+})
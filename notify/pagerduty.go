@@ -0,0 +1,245 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/lager/v3"
+	"github.com/pivotal-cf/service-backup/config"
+)
+
+const (
+	DefaultPagerDutyURL = "https://events.pagerduty.com/v2/enqueue"
+
+	pagerDutyTimeout    = 10 * time.Second
+	maxPagerDutySummary = 1024
+)
+
+// PagerDuty triggers a PagerDuty incident for each failed run and resolves it
+// when the next run for the same service instance succeeds. Repeated failures
+// of one instance share a dedup key, so they update a single incident.
+type PagerDuty struct {
+	routingKey     string
+	url            string
+	severities     Severities
+	deploymentName string
+	client         *http.Client
+	logger         lager.Logger
+
+	lock sync.Mutex
+	// resolved records, by dedup key, whether the last event sent was a
+	// resolve. Keys not present have not been seen since the process started,
+	// so the first success for them resolves anything left open.
+	resolved map[string]bool
+}
+
+// NewPagerDuty builds a PagerDuty channel that uses severities, overridden
+// by any configured for PagerDuty alone.
+func NewPagerDuty(conf config.PagerDuty, deploymentName string, severities Severities, logger lager.Logger) (*PagerDuty, error) {
+	if conf.RoutingKey == "" {
+		return nil, errors.New("pagerduty.routing_key must be set")
+	}
+
+	severities, err := NewSeverities(severities, conf.Severities)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pagerduty severities: %s", err)
+	}
+
+	url := conf.APIURL
+	if url == "" {
+		url = DefaultPagerDutyURL
+	}
+
+	return &PagerDuty{
+		routingKey:     conf.RoutingKey,
+		url:            url,
+		severities:     severities,
+		deploymentName: deploymentName,
+		client:         &http.Client{Timeout: pagerDutyTimeout},
+		logger:         logger.Session("pagerduty"),
+		resolved:       map[string]bool{},
+	}, nil
+}
+
+func (p *PagerDuty) Name() string {
+	return "pagerduty"
+}
+
+type pagerDutyEvent struct {
+	RoutingKey  string            `json:"routing_key"`
+	EventAction string            `json:"event_action"`
+	DedupKey    string            `json:"dedup_key"`
+	Payload     *pagerDutyPayload `json:"payload,omitempty"`
+}
+
+type pagerDutyPayload struct {
+	Summary       string    `json:"summary"`
+	Source        string    `json:"source"`
+	Severity      string    `json:"severity"`
+	Timestamp     time.Time `json:"timestamp"`
+	Component     string    `json:"component"`
+	Group         string    `json:"group,omitempty"`
+	Class         string    `json:"class,omitempty"`
+	CustomDetails EventData `json:"custom_details"`
+}
+
+// Notify triggers an incident for the event's service instance. Recovery
+// notices and digests are ignored: incidents are closed by Resolve, and
+// repeated failures are already grouped by their dedup key.
+func (p *PagerDuty) Notify(event Event) error {
+	if event.Type == EventRunRecovered || event.Type == EventAlertDigest {
+		return nil
+	}
+
+	class := event.FailureClass()
+	dedupKey := p.dedupKey(event)
+
+	err := p.send(pagerDutyEvent{
+		RoutingKey:  p.routingKey,
+		EventAction: "trigger",
+		DedupKey:    dedupKey,
+		Payload: &pagerDutyPayload{
+			Summary:       p.summary(event),
+			Source:        p.source(),
+			Severity:      p.Severity(class),
+			Timestamp:     event.Time.UTC(),
+			Component:     "service-backup",
+			Group:         p.deploymentName,
+			Class:         class,
+			CustomDetails: event.Data(),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	p.lock.Lock()
+	p.resolved[dedupKey] = false
+	p.lock.Unlock()
+	p.logger.Info("triggered incident", lager.Data{"dedup_key": dedupKey})
+	return nil
+}
+
+// Resolve resolves the incident for the event's service instance, or the
+// overdue incident for EventBackupOverdue, unless it is already known to be
+// resolved.
+func (p *PagerDuty) Resolve(event Event) error {
+	dedupKey := p.dedupKey(event)
+
+	p.lock.Lock()
+	alreadyResolved := p.resolved[dedupKey]
+	p.lock.Unlock()
+	if alreadyResolved {
+		return nil
+	}
+
+	if err := p.send(pagerDutyEvent{
+		RoutingKey:  p.routingKey,
+		EventAction: "resolve",
+		DedupKey:    dedupKey,
+	}); err != nil {
+		return err
+	}
+
+	p.lock.Lock()
+	p.resolved[dedupKey] = true
+	p.lock.Unlock()
+	p.logger.Info("resolved incident", lager.Data{"dedup_key": dedupKey})
+	return nil
+}
+
+// DedupKey identifies the incident for a service instance of this deployment.
+func (p *PagerDuty) DedupKey(serviceInstanceID string) string {
+	key := "service-backup/" + p.deploymentName
+	if serviceInstanceID != "" {
+		key += "/" + serviceInstanceID
+	}
+	return key
+}
+
+func (p *PagerDuty) dedupKey(event Event) string {
+	switch event.Type {
+	case EventBackupOverdue:
+		return p.DedupKey("") + "/overdue"
+	case EventCleanupFailed:
+		return p.DedupKey(event.Report.ServiceInstanceID) + "/cleanup"
+	default:
+		return p.DedupKey(event.Report.ServiceInstanceID)
+	}
+}
+
+// Severity returns the PagerDuty severity for a failure class.
+func (p *PagerDuty) Severity(class string) string {
+	return p.severities.Of(class)
+}
+
+func (p *PagerDuty) source() string {
+	if p.deploymentName != "" {
+		return p.deploymentName
+	}
+	return "service-backup"
+}
+
+func (p *PagerDuty) summary(event Event) string {
+	summary := "Service backup failed"
+	var detail string
+	if event.Report.Err != nil {
+		detail = event.Report.Err.Error()
+	}
+	switch event.Type {
+	case EventBackupOverdue:
+		summary = "Service backup overdue"
+	case EventRunPartial:
+		failed, _ := event.Report.DestinationsByOutcome()
+		summary = "Service backup partially failed"
+		detail = "upload to " + strings.Join(failed, ", ") + " failed: " + detail
+	case EventCleanupFailed:
+		summary = "Service backup cleanup failed"
+		detail = event.Report.CleanupErr.Error()
+	}
+	if id := event.Report.ServiceInstanceID; id != "" {
+		summary += " for " + id
+	}
+	if p.deploymentName != "" {
+		summary += " on " + p.deploymentName
+	}
+	if detail != "" {
+		summary += ": " + detail
+	}
+	if len(summary) > maxPagerDutySummary {
+		summary = summary[:maxPagerDutySummary]
+	}
+	return summary
+}
+
+func (p *PagerDuty) send(event pagerDutyEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	response, err := p.client.Post(p.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error sending PagerDuty %s event: %s", event.EventAction, err)
+	}
+	defer response.Body.Close()
+	responseBody, _ := io.ReadAll(io.LimitReader(response.Body, 4096))
+
+	if response.StatusCode != http.StatusAccepted && response.StatusCode != http.StatusOK {
+		return fmt.Errorf("PagerDuty rejected %s event with %s: %s", event.EventAction, response.Status, bytes.TrimSpace(responseBody))
+	}
+	return nil
+}
@@ -0,0 +1,265 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package notify_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/lager/v3"
+	"github.com/pivotal-cf/service-backup/config"
+	"github.com/pivotal-cf/service-backup/executor"
+	"github.com/pivotal-cf/service-backup/notify"
+	"github.com/pivotal-cf/service-backup/upload"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("PagerDuty", func() {
+	var (
+		server    *ghttp.Server
+		conf      config.PagerDuty
+		pagerDuty *notify.PagerDuty
+		failed    notify.Event
+		succeeded notify.Event
+	)
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+		conf = config.PagerDuty{
+			RoutingKey: "routing-key",
+			APIURL:     server.URL() + "/v2/enqueue",
+		}
+
+		failed = notify.Event{
+			Type: notify.EventRunFailed,
+			Time: time.Date(2026, 10, 19, 3, 0, 0, 0, time.UTC),
+			Report: executor.RunReport{
+				BackupGUID:        "backup-guid",
+				ServiceInstanceID: "instance-id",
+				FailedPhase:       executor.PhaseUpload,
+				Err:               errors.New("AccessDenied: nope"),
+			},
+		}
+		succeeded = notify.Event{
+			Type:   notify.EventRunSucceeded,
+			Report: executor.RunReport{ServiceInstanceID: "instance-id"},
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	JustBeforeEach(func() {
+		var err error
+		pagerDuty, err = notify.NewPagerDuty(conf, "redis", nil, lager.NewLogger("pagerduty-test"))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("triggers an incident keyed by deployment and service instance", func() {
+		server.AppendHandlers(ghttp.CombineHandlers(
+			ghttp.VerifyRequest("POST", "/v2/enqueue"),
+			ghttp.VerifyJSONRepresenting(map[string]interface{}{
+				"routing_key":  "routing-key",
+				"event_action": "trigger",
+				"dedup_key":    "service-backup/redis/instance-id",
+				"payload": map[string]interface{}{
+					"summary":   "Service backup failed for instance-id on redis: AccessDenied: nope",
+					"source":    "redis",
+					"severity":  "critical",
+					"timestamp": "2026-10-19T03:00:00Z",
+					"component": "service-backup",
+					"group":     "redis",
+					"class":     "auth",
+					"custom_details": map[string]interface{}{
+						"backup_guid":         "backup-guid",
+						"service_instance_id": "instance-id",
+						"started_at":          "0001-01-01T00:00:00Z",
+						"failed_phase":        "upload",
+						"failure_class":       "auth",
+						"error":               "AccessDenied: nope",
+					},
+				},
+			}),
+			ghttp.RespondWith(http.StatusAccepted, `{"status":"success"}`),
+		))
+
+		Expect(pagerDuty.Notify(failed)).To(Succeed())
+	})
+
+	It("resolves the incident on the next success and only once", func() {
+		server.AppendHandlers(
+			ghttp.RespondWith(http.StatusAccepted, nil),
+			ghttp.CombineHandlers(
+				ghttp.VerifyJSONRepresenting(map[string]interface{}{
+					"routing_key":  "routing-key",
+					"event_action": "resolve",
+					"dedup_key":    "service-backup/redis/instance-id",
+				}),
+				ghttp.RespondWith(http.StatusAccepted, nil),
+			),
+		)
+
+		Expect(pagerDuty.Notify(failed)).To(Succeed())
+		Expect(pagerDuty.Resolve(succeeded)).To(Succeed())
+		Expect(pagerDuty.Resolve(succeeded)).To(Succeed())
+		Expect(server.ReceivedRequests()).To(HaveLen(2))
+	})
+
+	It("resolves on the first success after starting, in case an incident was left open", func() {
+		server.AppendHandlers(ghttp.RespondWith(http.StatusAccepted, nil))
+
+		Expect(pagerDuty.Resolve(succeeded)).To(Succeed())
+		Expect(server.ReceivedRequests()).To(HaveLen(1))
+	})
+
+	It("keeps overdue alerts in an incident of their own", func() {
+		overdue := notify.Event{Type: notify.EventBackupOverdue, Report: executor.RunReport{Err: errors.New("no backup")}}
+		server.AppendHandlers(
+			ghttp.CombineHandlers(
+				func(w http.ResponseWriter, r *http.Request) {
+					var event map[string]interface{}
+					Expect(json.NewDecoder(r.Body).Decode(&event)).To(Succeed())
+					Expect(event["dedup_key"]).To(Equal("service-backup/redis/overdue"))
+					Expect(event["payload"]).To(HaveKeyWithValue("severity", "critical"))
+					Expect(event["payload"]).To(HaveKeyWithValue("summary", "Service backup overdue on redis: no backup"))
+				},
+				ghttp.RespondWith(http.StatusAccepted, nil),
+			),
+			ghttp.CombineHandlers(
+				ghttp.VerifyJSONRepresenting(map[string]interface{}{
+					"routing_key":  "routing-key",
+					"event_action": "resolve",
+					"dedup_key":    "service-backup/redis/overdue",
+				}),
+				ghttp.RespondWith(http.StatusAccepted, nil),
+			),
+		)
+
+		Expect(pagerDuty.Notify(overdue)).To(Succeed())
+		Expect(pagerDuty.Resolve(notify.Event{Type: notify.EventBackupOverdue})).To(Succeed())
+	})
+
+	It("names the failed destinations of partial failures", func() {
+		failed.Type = notify.EventRunPartial
+		failed.Report.Destinations = []executor.DestinationResult{{Name: "s3", Err: failed.Report.Err}, {Name: "gcs"}}
+		server.AppendHandlers(ghttp.CombineHandlers(
+			func(w http.ResponseWriter, r *http.Request) {
+				var event map[string]interface{}
+				Expect(json.NewDecoder(r.Body).Decode(&event)).To(Succeed())
+				Expect(event["dedup_key"]).To(Equal("service-backup/redis/instance-id"))
+				Expect(event["payload"]).To(HaveKeyWithValue("severity", "error"))
+				Expect(event["payload"]).To(HaveKeyWithValue("class", "partial"))
+				Expect(event["payload"]).To(HaveKeyWithValue("summary", "Service backup partially failed for instance-id on redis: upload to s3 failed: AccessDenied: nope"))
+			},
+			ghttp.RespondWith(http.StatusAccepted, nil),
+		))
+
+		Expect(pagerDuty.Notify(failed)).To(Succeed())
+	})
+
+	It("keeps cleanup failures in an incident of their own, at warning severity", func() {
+		cleanupFailed := notify.Event{
+			Type:   notify.EventCleanupFailed,
+			Report: executor.RunReport{ServiceInstanceID: "instance-id", CleanupErr: errors.New("rm failed")},
+		}
+		server.AppendHandlers(ghttp.CombineHandlers(
+			func(w http.ResponseWriter, r *http.Request) {
+				var event map[string]interface{}
+				Expect(json.NewDecoder(r.Body).Decode(&event)).To(Succeed())
+				Expect(event["dedup_key"]).To(Equal("service-backup/redis/instance-id/cleanup"))
+				Expect(event["payload"]).To(HaveKeyWithValue("severity", "warning"))
+				Expect(event["payload"]).To(HaveKeyWithValue("summary", "Service backup cleanup failed for instance-id on redis: rm failed"))
+			},
+			ghttp.RespondWith(http.StatusAccepted, nil),
+		))
+
+		Expect(pagerDuty.Notify(cleanupFailed)).To(Succeed())
+	})
+
+	It("returns an error when PagerDuty rejects the event", func() {
+		server.AppendHandlers(ghttp.RespondWith(http.StatusBadRequest, `{"status":"invalid event"}`))
+
+		Expect(pagerDuty.Notify(failed)).To(MatchError(`PagerDuty rejected trigger event with 400 Bad Request: {"status":"invalid event"}`))
+	})
+
+	It("tries to resolve again when resolving failed", func() {
+		server.AppendHandlers(
+			ghttp.RespondWith(http.StatusInternalServerError, nil),
+			ghttp.RespondWith(http.StatusAccepted, nil),
+		)
+
+		Expect(pagerDuty.Resolve(succeeded)).NotTo(Succeed())
+		Expect(pagerDuty.Resolve(succeeded)).To(Succeed())
+	})
+
+	It("truncates long summaries", func() {
+		failed.Report.Err = errors.New(strings.Repeat("x", 2000))
+		server.AppendHandlers(ghttp.CombineHandlers(
+			func(w http.ResponseWriter, r *http.Request) {
+				var event struct {
+					Payload struct {
+						Summary string `json:"summary"`
+					} `json:"payload"`
+				}
+				Expect(json.NewDecoder(r.Body).Decode(&event)).To(Succeed())
+				Expect(event.Payload.Summary).To(HaveLen(1024))
+			},
+			ghttp.RespondWith(http.StatusAccepted, nil),
+		))
+
+		Expect(pagerDuty.Notify(failed)).To(Succeed())
+	})
+
+	Describe("Severity", func() {
+		It("maps failure classes to severities", func() {
+			Expect(pagerDuty.Severity(upload.ErrorClassAuth)).To(Equal("critical"))
+			Expect(pagerDuty.Severity(upload.ErrorClassTimeout)).To(Equal("warning"))
+			Expect(pagerDuty.Severity(notify.FailureClassCancelled)).To(Equal("warning"))
+			Expect(pagerDuty.Severity(string(executor.PhaseBackup))).To(Equal("error"))
+		})
+
+		Context("when severities are configured", func() {
+			BeforeEach(func() {
+				conf.Severities = map[string]string{"timeout": "error", "backup": "critical"}
+			})
+
+			It("uses them in place of the defaults", func() {
+				Expect(pagerDuty.Severity(upload.ErrorClassTimeout)).To(Equal("error"))
+				Expect(pagerDuty.Severity(string(executor.PhaseBackup))).To(Equal("critical"))
+				Expect(pagerDuty.Severity(upload.ErrorClassAuth)).To(Equal("critical"))
+			})
+		})
+	})
+
+	Describe("DedupKey", func() {
+		It("omits the service instance when there is none", func() {
+			Expect(pagerDuty.DedupKey("")).To(Equal("service-backup/redis"))
+		})
+	})
+})
+
+var _ = Describe("NewPagerDuty", func() {
+	It("requires a routing key", func() {
+		_, err := notify.NewPagerDuty(config.PagerDuty{}, "redis", nil, lager.NewLogger("test"))
+		Expect(err).To(MatchError("pagerduty.routing_key must be set"))
+	})
+
+	It("rejects unknown severities", func() {
+		_, err := notify.NewPagerDuty(config.PagerDuty{
+			RoutingKey: "key",
+			Severities: map[string]string{"auth": "panic"},
+		}, "redis", nil, lager.NewLogger("test"))
+		Expect(err).To(MatchError("invalid pagerduty severities: unknown severity for auth: panic"))
+	})
+})
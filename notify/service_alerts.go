@@ -0,0 +1,77 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package notify
+
+import (
+	"strings"
+	"text/template"
+
+	"github.com/pivotal-cf/service-backup/config"
+)
+
+const (
+	DefaultServiceAlertSubject = `{{.Title}}`
+	DefaultServiceAlertBody    = `{{.Summary}}`
+)
+
+// ServiceAlertSender is satisfied by the service alerts client.
+type ServiceAlertSender interface {
+	SendServiceAlert(product, subject, serviceInstanceID, content string) error
+}
+
+// ServiceAlerts sends events as service alerts through the Cloud Foundry
+// notifications service.
+type ServiceAlerts struct {
+	sender         ServiceAlertSender
+	productName    string
+	subject        *template.Template
+	body           *template.Template
+	deploymentName string
+	labels         map[string]string
+	severities     Severities
+}
+
+func NewServiceAlerts(sender ServiceAlertSender, conf config.Alerts, deploymentName string, severities Severities) (*ServiceAlerts, error) {
+	subject, err := parseTemplate("subject", conf.Subject, DefaultServiceAlertSubject)
+	if err != nil {
+		return nil, err
+	}
+	body, err := parseTemplate("body", conf.Body, DefaultServiceAlertBody)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ServiceAlerts{
+		sender:         sender,
+		productName:    conf.ProductName,
+		subject:        subject,
+		body:           body,
+		deploymentName: deploymentName,
+		labels:         conf.Labels,
+		severities:     severities,
+	}, nil
+}
+
+func (a *ServiceAlerts) Name() string {
+	return "service-alerts"
+}
+
+func (a *ServiceAlerts) Notify(event Event) error {
+	data := NewTemplateData(event, a.deploymentName, a.labels)
+	data.Severity = a.severities.Of(event.FailureClass())
+
+	subject, err := render(a.subject, data)
+	if err != nil {
+		return err
+	}
+	body, err := render(a.body, data)
+	if err != nil {
+		return err
+	}
+
+	return a.sender.SendServiceAlert(a.productName, strings.TrimSpace(subject), event.Report.ServiceInstanceID, body)
+}
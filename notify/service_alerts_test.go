@@ -0,0 +1,142 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package notify_test
+
+import (
+	"errors"
+	"time"
+
+	"github.com/pivotal-cf/service-backup/config"
+	"github.com/pivotal-cf/service-backup/executor"
+	"github.com/pivotal-cf/service-backup/notify"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+type sentServiceAlert struct {
+	product, subject, serviceInstanceID, content string
+}
+
+type fakeServiceAlertSender struct {
+	sent []sentServiceAlert
+	err  error
+}
+
+func (s *fakeServiceAlertSender) SendServiceAlert(product, subject, serviceInstanceID, content string) error {
+	s.sent = append(s.sent, sentServiceAlert{product, subject, serviceInstanceID, content})
+	return s.err
+}
+
+var _ = Describe("ServiceAlerts", func() {
+	var (
+		sender *fakeServiceAlertSender
+		conf   config.Alerts
+		report executor.RunReport
+	)
+
+	BeforeEach(func() {
+		sender = new(fakeServiceAlertSender)
+		conf = config.Alerts{ProductName: "MySQL"}
+		report = executor.RunReport{
+			BackupGUID:        "backup-guid",
+			ServiceInstanceID: "instance",
+			Durations:         map[executor.Phase]time.Duration{executor.PhaseBackup: 90 * time.Second},
+			FailedPhase:       executor.PhaseUpload,
+			Destinations: []executor.DestinationResult{
+				{Name: "s3"},
+				{Name: "azure", Err: errors.New("forbidden")},
+			},
+			Err:        errors.New("upload failed"),
+			OutputTail: "disk full",
+		}
+	})
+
+	It("sends the event title and summary by default", func() {
+		serviceAlerts, err := notify.NewServiceAlerts(sender, conf, "deployment", nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(serviceAlerts.Notify(notify.OutcomeEvent(report))).To(Succeed())
+
+		Expect(sender.sent).To(Equal([]sentServiceAlert{{
+			product:           "MySQL",
+			subject:           "Service Backup Partially Failed",
+			serviceInstanceID: "instance",
+			content:           "A backup was uploaded to s3 but its upload to azure has failed with the following error: upload failed",
+		}}))
+	})
+
+	It("renders the severity of the event", func() {
+		conf.Subject = "[{{.Severity}}] {{.Title}}"
+		severities, err := notify.NewSeverities(map[string]string{"partial": "critical"})
+		Expect(err).NotTo(HaveOccurred())
+		serviceAlerts, err := notify.NewServiceAlerts(sender, conf, "deployment", severities)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(serviceAlerts.Notify(notify.OutcomeEvent(report))).To(Succeed())
+		Expect(serviceAlerts.Notify(notify.NewEvent(notify.EventCleanupFailed, executor.RunReport{CleanupErr: errors.New("rm failed")}))).To(Succeed())
+
+		Expect(sender.sent).To(HaveLen(2))
+		Expect(sender.sent[0].subject).To(Equal("[critical] Service Backup Partially Failed"))
+		Expect(sender.sent[1].subject).To(Equal("[warning] Service Backup Cleanup Failed"))
+		Expect(sender.sent[1].content).To(Equal("A backup run has succeeded but its cleanup has failed with the following error: rm failed"))
+	})
+
+	It("renders the configured templates from the run report", func() {
+		conf.Subject = "[{{.Labels.team}}] {{.DeploymentName}} backup failed in {{.FailedPhase}}"
+		conf.Body = "{{range .FailedDestinations}}{{.Name}}: {{.Error}}\n{{end}}backup took {{.Durations.backup}}{{with .NextRun}}, next run {{.UTC.Format \"15:04\"}}{{end}}\n{{.OutputTail}}"
+		conf.Labels = map[string]string{"team": "data"}
+		serviceAlerts, err := notify.NewServiceAlerts(sender, conf, "deployment", nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		event := notify.OutcomeEvent(report)
+		event.NextRun = time.Date(2024, 3, 10, 2, 30, 0, 0, time.UTC)
+		Expect(serviceAlerts.Notify(event)).To(Succeed())
+
+		Expect(sender.sent).To(HaveLen(1))
+		Expect(sender.sent[0].subject).To(Equal("[data] deployment backup failed in upload"))
+		Expect(sender.sent[0].content).To(Equal("azure: forbidden\nbackup took 1m30s, next run 02:30\ndisk full"))
+	})
+
+	It("fails to build with an invalid template", func() {
+		conf.Body = "{{.Summary"
+
+		_, err := notify.NewServiceAlerts(sender, conf, "deployment", nil)
+
+		Expect(err).To(MatchError(ContainSubstring("invalid body template")))
+	})
+
+	It("renders missing labels and phase durations as zero values", func() {
+		conf.Subject = "[{{.Labels.missing}}] {{.Title}}"
+		conf.Body = "backup took {{.Durations.backup}}"
+		serviceAlerts, err := notify.NewServiceAlerts(sender, conf, "deployment", nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		overdue := notify.NewEvent(notify.EventBackupOverdue, executor.RunReport{Err: errors.New("overdue")})
+		Expect(serviceAlerts.Notify(overdue)).To(Succeed())
+
+		Expect(sender.sent).To(HaveLen(1))
+		Expect(sender.sent[0].subject).To(Equal("[] Service Backup Overdue"))
+		Expect(sender.sent[0].content).To(Equal("backup took 0s"))
+	})
+
+	It("fails to build with a template that cannot be rendered for every event type", func() {
+		conf.Body = "next run {{.NextRun.UTC}}"
+
+		_, err := notify.NewServiceAlerts(sender, conf, "deployment", nil)
+
+		Expect(err).To(MatchError(ContainSubstring("invalid body template for run_started events")))
+	})
+
+	It("returns the error from sending the alert", func() {
+		sender.err = errors.New("notifications unavailable")
+		serviceAlerts, err := notify.NewServiceAlerts(sender, conf, "deployment", nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(serviceAlerts.Notify(notify.OutcomeEvent(report))).To(MatchError("notifications unavailable"))
+	})
+})
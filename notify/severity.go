@@ -0,0 +1,73 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package notify
+
+import (
+	"fmt"
+
+	"github.com/pivotal-cf/service-backup/upload"
+)
+
+const (
+	SeverityCritical = "critical"
+	SeverityError    = "error"
+	SeverityWarning  = "warning"
+	SeverityInfo     = "info"
+)
+
+// defaultSeverities maps failure classes to alert severities. Classes not
+// listed here are errors.
+var defaultSeverities = map[string]string{
+	upload.ErrorClassAuth:     SeverityCritical,
+	upload.ErrorClassNotFound: SeverityCritical,
+	upload.ErrorClassTimeout:  SeverityWarning,
+	upload.ErrorClassNetwork:  SeverityWarning,
+	FailureClassCancelled:     SeverityWarning,
+	FailureClassOverdue:       SeverityCritical,
+	FailureClassPartial:       SeverityError,
+	FailureClassCleanup:       SeverityWarning,
+}
+
+// Severities maps the failure class of an event to how severe an alert for
+// it is.
+type Severities map[string]string
+
+// NewSeverities returns the default severities with each of overrides
+// applied in turn.
+func NewSeverities(overrides ...map[string]string) (Severities, error) {
+	severities := Severities{}
+	for class, severity := range defaultSeverities {
+		severities[class] = severity
+	}
+	for _, override := range overrides {
+		for class, severity := range override {
+			switch severity {
+			case SeverityCritical, SeverityError, SeverityWarning, SeverityInfo:
+				severities[class] = severity
+			default:
+				return nil, fmt.Errorf("unknown severity for %s: %s", class, severity)
+			}
+		}
+	}
+	return severities, nil
+}
+
+// Of returns the severity of an event with the given failure class. Events
+// that are not failures, such as recovery notices, are info. Nil Severities
+// are the defaults.
+func (s Severities) Of(class string) string {
+	if class == "" {
+		return SeverityInfo
+	}
+	if severity, ok := s[class]; ok {
+		return severity
+	}
+	if severity, ok := defaultSeverities[class]; ok {
+		return severity
+	}
+	return SeverityError
+}
@@ -0,0 +1,49 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package notify_test
+
+import (
+	"github.com/pivotal-cf/service-backup/notify"
+	"github.com/pivotal-cf/service-backup/upload"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Severities", func() {
+	It("defaults partial failures to error and cleanup failures to warning", func() {
+		severities, err := notify.NewSeverities()
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(severities.Of(notify.FailureClassPartial)).To(Equal(notify.SeverityError))
+		Expect(severities.Of(notify.FailureClassCleanup)).To(Equal(notify.SeverityWarning))
+		Expect(severities.Of(upload.ErrorClassAuth)).To(Equal(notify.SeverityCritical))
+		Expect(severities.Of("backup")).To(Equal(notify.SeverityError))
+		Expect(severities.Of("")).To(Equal(notify.SeverityInfo))
+	})
+
+	It("applies overrides in order", func() {
+		severities, err := notify.NewSeverities(
+			map[string]string{"cleanup": "info", "partial": "critical"},
+			map[string]string{"partial": "warning"},
+		)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(severities.Of(notify.FailureClassCleanup)).To(Equal(notify.SeverityInfo))
+		Expect(severities.Of(notify.FailureClassPartial)).To(Equal(notify.SeverityWarning))
+	})
+
+	It("rejects unknown severities", func() {
+		_, err := notify.NewSeverities(map[string]string{"cleanup": "meh"})
+		Expect(err).To(MatchError("unknown severity for cleanup: meh"))
+	})
+
+	It("treats nil severities as the defaults", func() {
+		var severities notify.Severities
+		Expect(severities.Of(notify.FailureClassOverdue)).To(Equal(notify.SeverityCritical))
+	})
+})
@@ -0,0 +1,131 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package notify
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"text/template"
+	"time"
+
+	"github.com/pivotal-cf/service-backup/executor"
+)
+
+// TemplateData is what the subject and body templates of a notification are
+// executed with.
+type TemplateData struct {
+	EventData
+	Type           EventType
+	Title          string
+	Summary        string
+	DeploymentName string
+
+	// Severity is how severe the channel's severities say the event is.
+	Severity string
+
+	// Labels are the labels configured for the channel, for branding or
+	// routing alerts.
+	Labels map[string]string
+
+	// Durations is how long each phase of the run took, by phase name.
+	Durations map[string]time.Duration
+
+	// FailedDestinations are the destinations the backup could not be
+	// uploaded to.
+	FailedDestinations []DestinationData
+
+	// NextRun is when the next backup is scheduled, if the event was raised
+	// by the scheduler.
+	NextRun *time.Time
+}
+
+// NewTemplateData collects everything known about event for a template.
+func NewTemplateData(event Event, deploymentName string, labels map[string]string) TemplateData {
+	data := TemplateData{
+		EventData:      event.Data(),
+		Type:           event.Type,
+		Title:          event.Title(),
+		Summary:        event.Summary(),
+		DeploymentName: deploymentName,
+		Labels:         labels,
+		Durations:      map[string]time.Duration{},
+	}
+	if data.Labels == nil {
+		data.Labels = map[string]string{}
+	}
+	for phase, duration := range event.Report.Durations {
+		data.Durations[string(phase)] = duration
+	}
+	for _, destination := range data.Destinations {
+		if destination.Error != "" {
+			data.FailedDestinations = append(data.FailedDestinations, destination)
+		}
+	}
+	if !event.NextRun.IsZero() {
+		nextRun := event.NextRun
+		data.NextRun = &nextRun
+	}
+	return data
+}
+
+// parseTemplate parses a subject or body template, defaulting to
+// defaultText. Missing map keys, such as the durations of phases that did not
+// run, render as zero values, and the template is rendered for every type of
+// event up front, so that one that cannot be rendered fails when the config
+// is loaded rather than dropping alerts later.
+func parseTemplate(name, text, defaultText string) (*template.Template, error) {
+	if text == "" {
+		text = defaultText
+	}
+	tmpl, err := template.New(name).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s template: %s", name, err)
+	}
+	for _, event := range sampleEvents() {
+		if err := tmpl.Execute(io.Discard, NewTemplateData(event, "", nil)); err != nil {
+			return nil, fmt.Errorf("invalid %s template for %s events: %s", name, event.Type, err)
+		}
+	}
+	return tmpl, nil
+}
+
+// sampleEvents returns an event of every type, with only what is always
+// known about events of that type.
+func sampleEvents() []Event {
+	failed := executor.RunReport{
+		FailedPhase:  executor.PhaseUpload,
+		Err:          errors.New("upload failed"),
+		Destinations: []executor.DestinationResult{{Name: "destination", Err: errors.New("upload failed")}},
+	}
+
+	var events []Event
+	for _, t := range eventTypes {
+		// Destination failures always have a destination, below.
+		if t != EventDestinationFailed {
+			events = append(events, NewEvent(t, executor.RunReport{}))
+		}
+	}
+	destinationFailed := NewEvent(EventDestinationFailed, failed)
+	destinationFailed.Destination = &failed.Destinations[0]
+	return append(events,
+		NewEvent(EventRunFailed, failed),
+		destinationFailed,
+		NewEvent(EventBackupOverdue, executor.RunReport{Err: errors.New("overdue")}),
+		NewEvent(EventRunRecovered, executor.RunReport{}),
+		NewEvent(EventAlertDigest, executor.RunReport{}),
+	)
+}
+
+func render(tmpl *template.Template, data TemplateData) (string, error) {
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", fmt.Errorf("error rendering %s template: %s", tmpl.Name(), err)
+	}
+	return out.String(), nil
+}
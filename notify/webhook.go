@@ -0,0 +1,213 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"code.cloudfoundry.org/lager/v3"
+	"github.com/pivotal-cf/service-backup/config"
+)
+
+const (
+	SignatureHeader = "X-Service-Backup-Signature"
+
+	cloudEventsContentType = "application/cloudevents+json"
+	cloudEventTypePrefix   = "io.pivotal.service-backup."
+
+	defaultWebhookTimeout = 10 * time.Second
+	defaultRetryDelay     = time.Second
+
+	// defaultMaxEventDuration bounds how long the attempts to send one event
+	// may take, however many retries are configured.
+	defaultMaxEventDuration = 2 * time.Minute
+)
+
+// Webhook POSTs events to a URL as structured-mode CloudEvents.
+type Webhook struct {
+	url        string
+	secret     string
+	headers    map[string]string
+	events     map[EventType]bool
+	maxRetries int
+	retryDelay time.Duration
+	maxElapsed time.Duration
+	source     string
+	client     *http.Client
+	logger     lager.Logger
+}
+
+type WebhookOption func(*Webhook)
+
+// WithRetryDelay sets the delay before the first retry. The delay doubles
+// with each retry after that.
+func WithRetryDelay(delay time.Duration) WebhookOption {
+	return func(w *Webhook) {
+		w.retryDelay = delay
+	}
+}
+
+// WithMaxEventDuration bounds how long sending a single event may take,
+// including retries. No retry is made that would end after it.
+func WithMaxEventDuration(duration time.Duration) WebhookOption {
+	return func(w *Webhook) {
+		w.maxElapsed = duration
+	}
+}
+
+// NewWebhook builds a webhook notifier from the config. source identifies
+// this deployment in the CloudEvents it sends.
+func NewWebhook(conf config.Webhook, source string, logger lager.Logger, options ...WebhookOption) (*Webhook, error) {
+	parsed, err := url.Parse(conf.URL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("invalid webhook url: %q", conf.URL)
+	}
+
+	events, err := ParseEventTypes(conf.Events)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := defaultWebhookTimeout
+	if conf.TimeoutSeconds > 0 {
+		timeout = time.Duration(conf.TimeoutSeconds) * time.Second
+	}
+
+	w := &Webhook{
+		url:        conf.URL,
+		secret:     conf.Secret,
+		headers:    conf.Headers,
+		events:     events,
+		maxRetries: conf.MaxRetries,
+		retryDelay: defaultRetryDelay,
+		maxElapsed: defaultMaxEventDuration,
+		source:     source,
+		client:     &http.Client{Timeout: timeout},
+		logger:     logger.Session("webhook", lager.Data{"host": parsed.Host}),
+	}
+	for _, opt := range options {
+		opt(w)
+	}
+	return w, nil
+}
+
+func (w *Webhook) Name() string {
+	return "webhook"
+}
+
+type cloudEvent struct {
+	SpecVersion     string    `json:"specversion"`
+	ID              string    `json:"id"`
+	Source          string    `json:"source"`
+	Type            string    `json:"type"`
+	Subject         string    `json:"subject,omitempty"`
+	Time            time.Time `json:"time"`
+	DataContentType string    `json:"datacontenttype"`
+	Data            EventData `json:"data"`
+}
+
+// Notify sends the event if the webhook is subscribed to it, retrying server
+// errors and connection failures up to the configured number of times, and
+// for no longer than the maximum event duration in all.
+func (w *Webhook) Notify(event Event) error {
+	if !w.events[event.Type] {
+		return nil
+	}
+
+	body, err := json.Marshal(cloudEvent{
+		SpecVersion:     "1.0",
+		ID:              event.ID,
+		Source:          w.source,
+		Type:            cloudEventTypePrefix + string(event.Type),
+		Subject:         event.Report.ServiceInstanceID,
+		Time:            event.Time.UTC(),
+		DataContentType: "application/json",
+		Data:            event.Data(),
+	})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), w.maxElapsed)
+	defer cancel()
+
+	delay := w.retryDelay
+	for attempt := 0; ; attempt++ {
+		retryable, err := w.post(ctx, body)
+		if err == nil {
+			w.logger.Info("sent webhook", lager.Data{"event_type": event.Type, "event_id": event.ID})
+			return nil
+		}
+		if !retryable || attempt >= w.maxRetries {
+			return err
+		}
+		if deadline, _ := ctx.Deadline(); time.Now().Add(delay).After(deadline) {
+			return fmt.Errorf("giving up after %d attempts in %s: %w", attempt+1, w.maxElapsed, err)
+		}
+
+		w.logger.Info("retrying webhook", lager.Data{
+			"event_type": event.Type,
+			"attempt":    attempt + 1,
+			"error":      err.Error(),
+		})
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		delay *= 2
+	}
+}
+
+func (w *Webhook) post(ctx context.Context, body []byte) (bool, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	for name, value := range w.headers {
+		request.Header.Set(name, value)
+	}
+	request.Header.Set("Content-Type", cloudEventsContentType)
+	if w.secret != "" {
+		request.Header.Set(SignatureHeader, "sha256="+Sign(w.secret, body))
+	}
+
+	response, err := w.client.Do(request)
+	if err != nil {
+		return true, err
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, response.Body)
+
+	switch {
+	case response.StatusCode >= 200 && response.StatusCode < 300:
+		return false, nil
+	case response.StatusCode == http.StatusTooManyRequests || response.StatusCode >= 500:
+		return true, fmt.Errorf("webhook returned %s", response.Status)
+	default:
+		return false, fmt.Errorf("webhook returned %s", response.Status)
+	}
+}
+
+// Sign returns the hex-encoded HMAC-SHA256 of body, keyed by secret.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
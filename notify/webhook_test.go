@@ -0,0 +1,198 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package notify_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"code.cloudfoundry.org/lager/v3"
+	"github.com/pivotal-cf/service-backup/config"
+	"github.com/pivotal-cf/service-backup/executor"
+	"github.com/pivotal-cf/service-backup/notify"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("Webhook", func() {
+	var (
+		server  *ghttp.Server
+		conf    config.Webhook
+		logger  lager.Logger
+		event   notify.Event
+		webhook *notify.Webhook
+	)
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+		logger = lager.NewLogger("webhook-test")
+		conf = config.Webhook{URL: server.URL() + "/hook"}
+
+		event = notify.Event{
+			ID:   "event-id",
+			Type: notify.EventRunFailed,
+			Time: time.Date(2026, 10, 19, 3, 0, 0, 0, time.UTC),
+			Report: executor.RunReport{
+				BackupGUID:        "backup-guid",
+				ServiceInstanceID: "instance-id",
+				StartedAt:         time.Date(2026, 10, 19, 2, 59, 0, 0, time.UTC),
+				FinishedAt:        time.Date(2026, 10, 19, 3, 0, 0, 0, time.UTC),
+				FailedPhase:       executor.PhaseUpload,
+				Err:               io.ErrUnexpectedEOF,
+			},
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	JustBeforeEach(func() {
+		var err error
+		webhook, err = notify.NewWebhook(conf, "service-backup/deployment", logger, notify.WithRetryDelay(time.Millisecond))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("posts the event as a CloudEvent", func() {
+		server.AppendHandlers(ghttp.CombineHandlers(
+			ghttp.VerifyRequest("POST", "/hook"),
+			ghttp.VerifyContentType("application/cloudevents+json"),
+			func(w http.ResponseWriter, r *http.Request) {
+				var body map[string]interface{}
+				Expect(json.NewDecoder(r.Body).Decode(&body)).To(Succeed())
+				Expect(body).To(Equal(map[string]interface{}{
+					"specversion":     "1.0",
+					"id":              "event-id",
+					"source":          "service-backup/deployment",
+					"type":            "io.pivotal.service-backup.run_failed",
+					"subject":         "instance-id",
+					"time":            "2026-10-19T03:00:00Z",
+					"datacontenttype": "application/json",
+					"data": map[string]interface{}{
+						"backup_guid":         "backup-guid",
+						"service_instance_id": "instance-id",
+						"started_at":          "2026-10-19T02:59:00Z",
+						"finished_at":         "2026-10-19T03:00:00Z",
+						"duration_in_seconds": 60.0,
+						"failed_phase":        "upload",
+						"failure_class":       "unknown",
+						"error":               "unexpected EOF",
+					},
+				}))
+			},
+		))
+
+		Expect(webhook.Notify(event)).To(Succeed())
+		Expect(server.ReceivedRequests()).To(HaveLen(1))
+	})
+
+	Context("when a secret and headers are configured", func() {
+		BeforeEach(func() {
+			conf.Secret = "shh"
+			conf.Headers = map[string]string{"X-Team": "data"}
+		})
+
+		It("signs the body and sends the headers", func() {
+			server.AppendHandlers(ghttp.CombineHandlers(
+				ghttp.VerifyHeaderKV("X-Team", "data"),
+				func(w http.ResponseWriter, r *http.Request) {
+					body, err := io.ReadAll(r.Body)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(r.Header.Get(notify.SignatureHeader)).To(Equal("sha256=" + notify.Sign("shh", body)))
+				},
+			))
+
+			Expect(webhook.Notify(event)).To(Succeed())
+		})
+	})
+
+	Context("when the webhook is not subscribed to the event", func() {
+		BeforeEach(func() {
+			conf.Events = []string{"run_succeeded"}
+		})
+
+		It("does not send anything", func() {
+			Expect(webhook.Notify(event)).To(Succeed())
+			Expect(server.ReceivedRequests()).To(BeEmpty())
+		})
+	})
+
+	Context("when the server fails", func() {
+		BeforeEach(func() {
+			conf.MaxRetries = 2
+		})
+
+		It("retries until the event is accepted", func() {
+			server.AppendHandlers(
+				ghttp.RespondWith(http.StatusBadGateway, nil),
+				ghttp.RespondWith(http.StatusTooManyRequests, nil),
+				ghttp.RespondWith(http.StatusOK, nil),
+			)
+
+			Expect(webhook.Notify(event)).To(Succeed())
+			Expect(server.ReceivedRequests()).To(HaveLen(3))
+		})
+
+		It("gives up after the configured number of retries", func() {
+			server.AppendHandlers(
+				ghttp.RespondWith(http.StatusInternalServerError, nil),
+				ghttp.RespondWith(http.StatusInternalServerError, nil),
+				ghttp.RespondWith(http.StatusInternalServerError, nil),
+			)
+
+			Expect(webhook.Notify(event)).To(MatchError("webhook returned 500 Internal Server Error"))
+			Expect(server.ReceivedRequests()).To(HaveLen(3))
+		})
+
+		It("gives up retrying once the event has taken too long to send", func() {
+			server.AppendHandlers(ghttp.RespondWith(http.StatusInternalServerError, nil))
+			webhook, err := notify.NewWebhook(conf, "service-backup/deployment", logger,
+				notify.WithRetryDelay(time.Hour), notify.WithMaxEventDuration(time.Minute))
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(webhook.Notify(event)).To(MatchError("giving up after 1 attempts in 1m0s: webhook returned 500 Internal Server Error"))
+			Expect(server.ReceivedRequests()).To(HaveLen(1))
+		})
+
+		It("does not retry when the event is rejected", func() {
+			server.AppendHandlers(ghttp.RespondWith(http.StatusBadRequest, nil))
+
+			Expect(webhook.Notify(event)).To(MatchError("webhook returned 400 Bad Request"))
+			Expect(server.ReceivedRequests()).To(HaveLen(1))
+		})
+	})
+
+	Context("when the server does not respond in time", func() {
+		BeforeEach(func() {
+			conf.TimeoutSeconds = 1
+		})
+
+		It("returns an error", func() {
+			server.AppendHandlers(func(w http.ResponseWriter, r *http.Request) {
+				time.Sleep(1500 * time.Millisecond)
+			})
+
+			Expect(webhook.Notify(event)).To(MatchError(ContainSubstring("Client.Timeout exceeded")))
+		})
+	})
+})
+
+var _ = Describe("NewWebhook", func() {
+	It("rejects a URL that is not http or https", func() {
+		_, err := notify.NewWebhook(config.Webhook{URL: "ftp://example.com"}, "source", lager.NewLogger("test"))
+		Expect(err).To(MatchError(`invalid webhook url: "ftp://example.com"`))
+	})
+
+	It("rejects unknown event types", func() {
+		_, err := notify.NewWebhook(config.Webhook{URL: "https://example.com", Events: []string{"bogus"}}, "source", lager.NewLogger("test"))
+		Expect(err).To(MatchError("unknown event type: bogus"))
+	})
+})
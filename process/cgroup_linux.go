@@ -0,0 +1,98 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+//go:build linux
+
+package process
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"syscall"
+)
+
+const (
+	cgroupRoot      = "/sys/fs/cgroup"
+	cgroupCPUPeriod = 100000
+)
+
+// Cgroup is a cgroup v2 group that child processes are placed into as they
+// are started, so the limits also apply to anything they fork.
+type Cgroup struct {
+	dir *os.File
+}
+
+func NewCgroup(name string, cpuMaxPercent int, memoryMaxBytes int64) (*Cgroup, error) {
+	if _, err := os.Stat(filepath.Join(cgroupRoot, "cgroup.controllers")); err != nil {
+		return nil, ErrCgroupsUnsupported
+	}
+
+	// Enabling the controllers for children of the root group is best effort:
+	// they may already be enabled, or delegated to us by the init system.
+	_ = os.WriteFile(filepath.Join(cgroupRoot, "cgroup.subtree_control"), []byte("+cpu +memory"), 0644)
+
+	path := filepath.Join(cgroupRoot, name)
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return nil, fmt.Errorf("error creating cgroup %s: %w", path, err)
+	}
+
+	cpuMax := "max"
+	if cpuMaxPercent > 0 {
+		cpuMax = strconv.Itoa(cpuMaxPercent * cgroupCPUPeriod / 100)
+	}
+	if err := writeCgroupFile(path, "cpu.max", fmt.Sprintf("%s %d", cpuMax, cgroupCPUPeriod)); err != nil {
+		return nil, err
+	}
+
+	memoryMax := "max"
+	if memoryMaxBytes > 0 {
+		memoryMax = strconv.FormatInt(memoryMaxBytes, 10)
+	}
+	if err := writeCgroupFile(path, "memory.max", memoryMax); err != nil {
+		return nil, err
+	}
+
+	dir, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening cgroup %s: %w", path, err)
+	}
+	cgroup := &Cgroup{dir: dir}
+	if err := cgroup.probe(); err != nil {
+		dir.Close()
+		return nil, err
+	}
+	return cgroup, nil
+}
+
+// probe starts a process in the cgroup. Being able to write to the cgroup
+// does not mean the kernel can start processes straight into it, and if it
+// cannot, every command would fail to start.
+func (c *Cgroup) probe() error {
+	cmd := exec.Command("true")
+	c.apply(cmd)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("error starting a process in cgroup %s: %w", c.dir.Name(), err)
+	}
+	return nil
+}
+
+func writeCgroupFile(path, name, value string) error {
+	if err := os.WriteFile(filepath.Join(path, name), []byte(value), 0644); err != nil {
+		return fmt.Errorf("error setting %s on cgroup %s: %w", name, path, err)
+	}
+	return nil
+}
+
+func (c *Cgroup) apply(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = int(c.dir.Fd())
+}
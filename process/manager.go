@@ -1,22 +1,34 @@
 package process
 
 import (
-	"bytes"
 	"errors"
+	"io"
 	"os/exec"
 	"sync"
 	"syscall"
+	"time"
 )
 
+// ProcessManager starts child processes. It is shared between destinations
+// uploaded to in parallel, so must be safe for concurrent use.
+//
 //go:generate counterfeiter -o fakes/process_manager.go . ProcessManager
 type ProcessManager interface {
 	Start(*exec.Cmd) ([]byte, error)
 }
 
+var ErrCgroupsUnsupported = errors.New("cgroup v2 is not available on this system")
+
 type Manager struct {
-	wg      sync.WaitGroup
-	killAll chan struct{}
-	lock    sync.Mutex
+	wg       sync.WaitGroup
+	killAll  chan struct{}
+	killNow  chan struct{}
+	lock     sync.Mutex
+	priority Priority
+	cgroup   *Cgroup
+
+	maxBufferedBytes     int
+	streamLinesPerSecond int
 }
 
 func (m *Manager) isBeingShutdown() bool {
@@ -28,24 +40,57 @@ func (m *Manager) isBeingShutdown() bool {
 	}
 }
 
-func NewManager() *Manager {
-	pt := &Manager{}
+func NewManager(options ...Option) *Manager {
+	pt := &Manager{
+		maxBufferedBytes:     DefaultMaxBufferedBytes,
+		streamLinesPerSecond: DefaultStreamLinesPerSecond,
+	}
 	pt.killAll = make(chan struct{})
+	pt.killNow = make(chan struct{})
+	for _, opt := range options {
+		opt(pt)
+	}
 	return pt
 }
 
+// Start runs cmd to completion, returning the end of its combined stdout and
+// stderr, up to the maximum buffered bytes.
 func (m *Manager) Start(cmd *exec.Cmd) ([]byte, error) {
+	return m.StartStreaming(cmd, Stream{})
+}
+
+// StartStreaming runs cmd like Start, also logging its output to the stream's
+// logger a line at a time, as it is written.
+func (m *Manager) StartStreaming(cmd *exec.Cmd, stream Stream) ([]byte, error) {
 	m.lock.Lock()
 	if m.isBeingShutdown() {
+		m.lock.Unlock()
 		return nil, errors.New("Shutdown in progress")
 	}
 
-	processExitChan := make(chan error, 1)
+	if err := m.priority.wrap(cmd); err != nil {
+		m.lock.Unlock()
+		return nil, err
+	}
+	if m.cgroup != nil {
+		m.cgroup.apply(cmd)
+	}
 
-	var cmdOutput bytes.Buffer
+	processExitChan := make(chan error, 1)
 
-	cmd.Stdout = &cmdOutput
-	cmd.Stderr = &cmdOutput
+	cmdOutput := &tailBuffer{max: m.maxBufferedBytes}
+	if stream.Logger == nil {
+		cmd.Stdout = cmdOutput
+		cmd.Stderr = cmdOutput
+	} else {
+		limiter := newLineRateLimiter(m.streamLinesPerSecond, time.Now)
+		stdout := &lineLogger{logger: stream.Logger, stream: "stdout", redact: stream.Redact, limiter: limiter}
+		stderr := &lineLogger{logger: stream.Logger, stream: "stderr", redact: stream.Redact, limiter: limiter}
+		cmd.Stdout = io.MultiWriter(cmdOutput, stdout)
+		cmd.Stderr = io.MultiWriter(cmdOutput, stderr)
+		defer stdout.flush()
+		defer stderr.flush()
+	}
 
 	err := cmd.Start()
 	if err != nil {
@@ -63,16 +108,52 @@ func (m *Manager) Start(cmd *exec.Cmd) ([]byte, error) {
 	select {
 	case <-m.killAll:
 		cmd.Process.Signal(syscall.SIGTERM)
-		<-processExitChan
-		return cmdOutput.Bytes(), errors.New("SIGTERM propagated to child process")
+		select {
+		case <-processExitChan:
+			return cmdOutput.Bytes(), errors.New("SIGTERM propagated to child process")
+		case <-m.killNow:
+			cmd.Process.Kill()
+			<-processExitChan
+			return cmdOutput.Bytes(), errors.New("SIGKILL sent to child process after SIGTERM")
+		}
 	case retVal := <-processExitChan:
 		return cmdOutput.Bytes(), retVal
 	}
 }
 
+// Terminate sends SIGTERM to every running child, refuses to start any more,
+// and waits for them all to exit.
 func (m *Manager) Terminate() {
+	m.TerminateWithin(0)
+}
+
+// TerminateWithin is like Terminate, but sends SIGKILL to any child still
+// running after the timeout. A zero timeout waits indefinitely. It reports
+// whether any child had to be killed.
+func (m *Manager) TerminateWithin(timeout time.Duration) bool {
 	m.lock.Lock()
+	defer m.lock.Unlock()
 	close(m.killAll)
-	m.wg.Wait()
-	m.lock.Unlock()
+
+	exited := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(exited)
+	}()
+
+	if timeout <= 0 {
+		<-exited
+		return false
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-exited:
+		return false
+	case <-timer.C:
+		close(m.killNow)
+		<-exited
+		return true
+	}
 }
@@ -2,6 +2,7 @@ package process_test
 
 import (
 	"os/exec"
+	"path/filepath"
 	"strings"
 	"syscall"
 	"time"
@@ -108,6 +109,49 @@ var _ = Describe("process manager", func() {
 		Expect(true).To(BeTrue())
 	})
 
+	It("refuses to start anything once terminated", func() {
+		pt := process.NewManager()
+		pt.Terminate()
+
+		_, err := pt.Start(exec.Command("true"))
+		Expect(err).To(MatchError("Shutdown in progress"))
+		_, err = pt.Start(exec.Command("true"))
+		Expect(err).To(MatchError("Shutdown in progress"))
+	})
+
+	Describe("TerminateWithin", func() {
+		It("does not kill children that exit on SIGTERM", func() {
+			pt := process.NewManager()
+			cmd := exec.Command("sleep", "42")
+			errCh := make(chan error, 1)
+			go func() {
+				_, err := pt.Start(cmd)
+				errCh <- err
+			}()
+			Eventually(func() bool { return alive(cmd) }).Should(BeTrue())
+
+			Expect(pt.TerminateWithin(5 * time.Second)).To(BeFalse())
+			Expect(<-errCh).To(MatchError("SIGTERM propagated to child process"))
+		})
+
+		It("kills children still running after the timeout", func() {
+			pt := process.NewManager()
+			ready := filepath.Join(GinkgoT().TempDir(), "ready")
+			cmd := exec.Command("bash", "-c", "trap '' TERM; touch "+ready+"; exec sleep 42")
+			errCh := make(chan error, 1)
+			go func() {
+				_, err := pt.Start(cmd)
+				errCh <- err
+			}()
+			Eventually(ready).Should(BeAnExistingFile())
+
+			start := time.Now()
+			Expect(pt.TerminateWithin(200 * time.Millisecond)).To(BeTrue())
+			Expect(time.Since(start)).To(BeNumerically("<", 5*time.Second))
+			Expect(<-errCh).To(MatchError("SIGKILL sent to child process after SIGTERM"))
+		})
+	})
+
 	It("captures stdout from the executable", func() {
 		const minimalBlockingByteCount = 128*1024 + 1
 		cmdstr := "for i in $(seq 1 131073); do echo -n X; done"
@@ -141,4 +185,51 @@ var _ = Describe("process manager", func() {
 		out, _ := pt.Start(cmd)
 		Expect(string(out)).Should(ContainSubstring("No such file or directory"))
 	})
+
+	Context("when a priority is configured", func() {
+		It("runs the executable with the configured niceness", func() {
+			pt := process.NewManager(process.WithPriority(process.Priority{Nice: 5}))
+			cmd := exec.Command("nice")
+
+			out, err := pt.Start(cmd)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(strings.TrimSpace(string(out))).To(Equal("5"))
+		})
+
+		It("runs the executable with the configured io scheduling class", func() {
+			pt := process.NewManager(process.WithPriority(process.Priority{IONiceClass: "idle"}))
+			cmd := exec.Command("ionice")
+
+			out, err := pt.Start(cmd)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(strings.TrimSpace(string(out))).To(Equal("idle"))
+		})
+
+		It("passes the original arguments through", func() {
+			pt := process.NewManager(process.WithPriority(process.Priority{Nice: 3, IONiceClass: "best-effort", IONiceLevel: 7}))
+			cmd := exec.Command("echo", "hello", "world")
+
+			out, err := pt.Start(cmd)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(out)).To(Equal("hello world\n"))
+		})
+	})
+
+	Describe("Priority", func() {
+		It("accepts an empty priority", func() {
+			Expect(process.Priority{}.Validate()).To(Succeed())
+		})
+
+		It("rejects an unknown ionice class", func() {
+			Expect(process.Priority{IONiceClass: "lowest"}.Validate()).To(MatchError("unknown ionice class: lowest"))
+		})
+
+		It("rejects an out of range nice value", func() {
+			Expect(process.Priority{Nice: 20}.Validate()).To(MatchError("nice must be between -20 and 19, got 20"))
+		})
+
+		It("rejects an out of range ionice level", func() {
+			Expect(process.Priority{IONiceClass: "best-effort", IONiceLevel: 8}.Validate()).To(MatchError("ionice level must be between 0 and 7, got 8"))
+		})
+	})
 })
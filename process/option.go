@@ -0,0 +1,90 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package process
+
+import (
+	"code.cloudfoundry.org/lager/v3"
+	"github.com/pivotal-cf/service-backup/config"
+)
+
+type Option func(*Manager)
+
+func WithPriority(p Priority) Option {
+	return func(m *Manager) {
+		m.priority = p
+	}
+}
+
+func WithCgroup(c *Cgroup) Option {
+	return func(m *Manager) {
+		m.cgroup = c
+	}
+}
+
+// WithMaxBufferedBytes caps how much of the output of a process is kept; only
+// the end of it is returned.
+func WithMaxBufferedBytes(n int) Option {
+	return func(m *Manager) {
+		m.maxBufferedBytes = n
+	}
+}
+
+// WithStreamRateLimit caps how many lines of output a second are logged for
+// each process that is streamed. Zero means no limit.
+func WithStreamRateLimit(linesPerSecond int) Option {
+	return func(m *Manager) {
+		m.streamLinesPerSecond = linesPerSecond
+	}
+}
+
+// OutputOptionsFromConfig returns the options for the configured limits on
+// the output of processes. Unset limits keep their defaults.
+func OutputOptionsFromConfig(childOutput *config.ChildOutput) []Option {
+	if childOutput == nil {
+		return nil
+	}
+
+	var options []Option
+	if childOutput.MaxBufferedBytes > 0 {
+		options = append(options, WithMaxBufferedBytes(childOutput.MaxBufferedBytes))
+	}
+	if childOutput.StreamLinesPerSecond > 0 {
+		options = append(options, WithStreamRateLimit(childOutput.StreamLinesPerSecond))
+	}
+	return options
+}
+
+func OptionsFromConfig(priorityConfig *config.ProcessPriority, logger lager.Logger) ([]Option, error) {
+	if priorityConfig == nil {
+		return nil, nil
+	}
+
+	priority := Priority{
+		Nice:        priorityConfig.Nice,
+		IONiceClass: priorityConfig.IONiceClass,
+		IONiceLevel: priorityConfig.IONiceLevel,
+	}
+	if err := priority.Validate(); err != nil {
+		return nil, err
+	}
+	options := []Option{WithPriority(priority)}
+
+	if cgroupConfig := priorityConfig.Cgroup; cgroupConfig != nil {
+		name := cgroupConfig.Name
+		if name == "" {
+			name = "service-backup"
+		}
+		cgroup, err := NewCgroup(name, cgroupConfig.CPUMaxPercent, cgroupConfig.MemoryMaxBytes)
+		if err != nil {
+			logger.Error("cgroup limits will not be applied", err)
+		} else {
+			options = append(options, WithCgroup(cgroup))
+		}
+	}
+
+	return options, nil
+}
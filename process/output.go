@@ -0,0 +1,177 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package process
+
+import (
+	"bytes"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/lager/v3"
+)
+
+const (
+	DefaultMaxBufferedBytes     = 1024 * 1024
+	DefaultStreamLinesPerSecond = 100
+
+	// maxLineBytes is how much of a line without a newline is held before
+	// it is logged anyway.
+	maxLineBytes = 16 * 1024
+)
+
+// Stream says where StartStreaming logs the output of a process. Redact, if
+// set, is applied to each line before it is logged.
+type Stream struct {
+	Logger lager.Logger
+	Redact func(string) string
+}
+
+// tailBuffer keeps the last max bytes written to it, so that a noisy process
+// cannot use unbounded memory. Once full, it is a ring: writes overwrite the
+// oldest bytes in place, from start.
+type tailBuffer struct {
+	lock  sync.Mutex
+	max   int
+	buf   []byte
+	start int
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	n := len(p)
+	switch {
+	case b.max <= 0:
+		b.buf = append(b.buf, p...)
+		return n, nil
+	case n >= b.max:
+		b.buf = append(b.buf[:0], p[n-b.max:]...)
+		b.start = 0
+		return n, nil
+	case len(b.buf) < b.max:
+		room := min(b.max-len(b.buf), n)
+		b.buf = append(b.buf, p[:room]...)
+		p = p[room:]
+	}
+
+	copied := copy(b.buf[b.start:], p)
+	copy(b.buf, p[copied:])
+	b.start = (b.start + len(p)) % b.max
+	return n, nil
+}
+
+func (b *tailBuffer) Bytes() []byte {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return append(append([]byte(nil), b.buf[b.start:]...), b.buf[:b.start]...)
+}
+
+// lineRateLimiter allows a burst of up to perSecond lines, refilled
+// continuously at perSecond lines a second. It is shared by the streams of a
+// process.
+type lineRateLimiter struct {
+	lock      sync.Mutex
+	perSecond float64
+	tokens    float64
+	last      time.Time
+	now       func() time.Time
+}
+
+func newLineRateLimiter(perSecond int, now func() time.Time) *lineRateLimiter {
+	return &lineRateLimiter{
+		perSecond: float64(perSecond),
+		tokens:    float64(perSecond),
+		last:      now(),
+		now:       now,
+	}
+}
+
+func (l *lineRateLimiter) allow() bool {
+	if l.perSecond <= 0 {
+		return true
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := l.now()
+	l.tokens += now.Sub(l.last).Seconds() * l.perSecond
+	if l.tokens > l.perSecond {
+		l.tokens = l.perSecond
+	}
+	l.last = now
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
+
+// lineLogger logs what a process writes to one of its streams a line at a
+// time, dropping lines beyond the rate limit and saying how many it dropped.
+type lineLogger struct {
+	logger  lager.Logger
+	stream  string
+	redact  func(string) string
+	limiter *lineRateLimiter
+	partial []byte
+	dropped int
+}
+
+func (w *lineLogger) Write(p []byte) (int, error) {
+	lines := p
+	if len(w.partial) > 0 {
+		lines = append(w.partial, p...)
+	}
+	for {
+		i := bytes.IndexByte(lines, '\n')
+		if i < 0 {
+			break
+		}
+		w.log(lines[:i])
+		lines = lines[i+1:]
+	}
+	for len(lines) >= maxLineBytes {
+		w.log(lines[:maxLineBytes])
+		lines = lines[maxLineBytes:]
+	}
+	// Only an unfinished line is kept, copied since p belongs to the caller.
+	w.partial = append(w.partial[:0], lines...)
+	return len(p), nil
+}
+
+func (w *lineLogger) log(line []byte) {
+	if !w.limiter.allow() {
+		w.dropped++
+		return
+	}
+	w.reportDropped()
+
+	text := string(bytes.TrimRight(line, "\r"))
+	if w.redact != nil {
+		text = w.redact(text)
+	}
+	w.logger.Info("output", lager.Data{"stream": w.stream, "line": text})
+}
+
+func (w *lineLogger) reportDropped() {
+	if w.dropped == 0 {
+		return
+	}
+	w.logger.Info("output rate limited", lager.Data{"stream": w.stream, "dropped_lines": w.dropped})
+	w.dropped = 0
+}
+
+// flush logs the last line, if the process did not end it with a newline.
+func (w *lineLogger) flush() {
+	if len(w.partial) > 0 {
+		w.log(w.partial)
+		w.partial = nil
+	}
+	w.reportDropped()
+}
@@ -0,0 +1,91 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package process
+
+import (
+	"bytes"
+	"math/rand"
+	"time"
+
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("tailBuffer", func() {
+	It("keeps the last max bytes across writes of every size", func() {
+		random := rand.New(rand.NewSource(GinkgoRandomSeed()))
+		buffer := &tailBuffer{max: 64}
+		var written []byte
+
+		for i := 0; i < 1000; i++ {
+			p := make([]byte, random.Intn(100))
+			random.Read(p)
+			n, err := buffer.Write(p)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(n).To(Equal(len(p)))
+
+			written = append(written, p...)
+			Expect(buffer.Bytes()).To(Equal(written[max(len(written)-64, 0):]))
+		}
+	})
+
+	It("keeps everything when unbounded", func() {
+		buffer := &tailBuffer{}
+		buffer.Write([]byte("hello "))
+		buffer.Write([]byte("world"))
+
+		Expect(buffer.Bytes()).To(Equal([]byte("hello world")))
+	})
+})
+
+var _ = Describe("lineLogger", func() {
+	var (
+		logger *lagertest.TestLogger
+		writer *lineLogger
+	)
+
+	BeforeEach(func() {
+		logger = lagertest.NewTestLogger("process")
+		writer = &lineLogger{logger: logger, stream: "stdout", limiter: newLineRateLimiter(0, time.Now)}
+	})
+
+	linesLogged := func() []string {
+		var lines []string
+		for _, log := range logger.Logs() {
+			if log.Message == "process.output" {
+				lines = append(lines, log.Data["line"].(string))
+			}
+		}
+		return lines
+	}
+
+	It("joins lines split across writes", func() {
+		writer.Write([]byte("dum"))
+		writer.Write([]byte("ping\nwar"))
+		writer.Write([]byte("ning\ndone\n"))
+
+		Expect(linesLogged()).To(Equal([]string{"dumping", "warning", "done"}))
+		Expect(writer.partial).To(BeEmpty())
+	})
+
+	It("does not keep a reference to the caller's buffer", func() {
+		p := []byte("dump")
+		writer.Write(p)
+		copy(p, "XXXX")
+		writer.Write([]byte("ing\n"))
+
+		Expect(linesLogged()).To(Equal([]string{"dumping"}))
+	})
+
+	It("logs lines longer than the maximum in pieces", func() {
+		writer.Write(bytes.Repeat([]byte("x"), maxLineBytes+10))
+		writer.flush()
+
+		Expect(linesLogged()).To(Equal([]string{string(bytes.Repeat([]byte("x"), maxLineBytes)), "xxxxxxxxxx"}))
+	})
+})
@@ -0,0 +1,94 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package process_test
+
+import (
+	"os/exec"
+	"strings"
+
+	"code.cloudfoundry.org/lager/v3"
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-cf/service-backup/process"
+)
+
+var _ = Describe("streaming output", func() {
+	var logger *lagertest.TestLogger
+
+	BeforeEach(func() {
+		logger = lagertest.NewTestLogger("process")
+	})
+
+	linesLogged := func() []lager.LogFormat {
+		var lines []lager.LogFormat
+		for _, log := range logger.Logs() {
+			if log.Message == "process.output" {
+				lines = append(lines, log)
+			}
+		}
+		return lines
+	}
+
+	It("logs each line tagged with its stream, as well as returning the output", func() {
+		pt := process.NewManager()
+		cmd := exec.Command("sh", "-c", "echo dumping; echo warning >&2; printf done")
+
+		out, err := pt.StartStreaming(cmd, process.Stream{Logger: logger.WithData(lager.Data{"phase": "backup"})})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(out)).To(ContainSubstring("dumping\n"))
+
+		lines := linesLogged()
+		Expect(lines).To(HaveLen(3))
+		Expect(lines).To(ContainElement(HaveField("Data", Equal(lager.Data{"phase": "backup", "stream": "stdout", "line": "dumping"}))))
+		Expect(lines).To(ContainElement(HaveField("Data", Equal(lager.Data{"phase": "backup", "stream": "stderr", "line": "warning"}))))
+		Expect(lines).To(ContainElement(HaveField("Data", Equal(lager.Data{"phase": "backup", "stream": "stdout", "line": "done"}))))
+	})
+
+	It("redacts each line before logging it", func() {
+		pt := process.NewManager()
+		cmd := exec.Command("echo", "password is hunter2")
+
+		_, err := pt.StartStreaming(cmd, process.Stream{
+			Logger: logger,
+			Redact: func(line string) string { return strings.ReplaceAll(line, "hunter2", "[REDACTED]") },
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(linesLogged()).To(ConsistOf(HaveField("Data", HaveKeyWithValue("line", "password is [REDACTED]"))))
+	})
+
+	It("drops lines beyond the rate limit and says how many", func() {
+		pt := process.NewManager(process.WithStreamRateLimit(10))
+		cmd := exec.Command("seq", "1", "1000")
+
+		out, err := pt.StartStreaming(cmd, process.Stream{Logger: logger})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(strings.Count(string(out), "\n")).To(Equal(1000))
+
+		logged := len(linesLogged())
+		Expect(logged).To(BeNumerically(">=", 10))
+		Expect(logged).To(BeNumerically("<", 1000))
+
+		dropped := 0
+		for _, log := range logger.Logs() {
+			if log.Message == "process.output rate limited" {
+				dropped += int(log.Data["dropped_lines"].(float64))
+			}
+		}
+		Expect(logged + dropped).To(Equal(1000))
+	})
+
+	It("keeps only the end of the output within the maximum buffered bytes", func() {
+		pt := process.NewManager(process.WithMaxBufferedBytes(10))
+		cmd := exec.Command("seq", "1", "1000")
+
+		out, err := pt.Start(cmd)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(out)).To(Equal("\n999\n1000\n"))
+	})
+})
@@ -0,0 +1,77 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package process
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+)
+
+var ioniceClasses = map[string]string{
+	"realtime":    "1",
+	"best-effort": "2",
+	"idle":        "3",
+}
+
+// Priority describes the CPU and IO scheduling applied to every child process
+// started by the Manager. Zero values leave the inherited priority untouched.
+type Priority struct {
+	Nice        int
+	IONiceClass string
+	IONiceLevel int
+}
+
+func (p Priority) Validate() error {
+	if p.Nice < -20 || p.Nice > 19 {
+		return fmt.Errorf("nice must be between -20 and 19, got %d", p.Nice)
+	}
+	if p.IONiceClass == "" {
+		return nil
+	}
+	if _, ok := ioniceClasses[p.IONiceClass]; !ok {
+		return fmt.Errorf("unknown ionice class: %s", p.IONiceClass)
+	}
+	if p.IONiceLevel < 0 || p.IONiceLevel > 7 {
+		return fmt.Errorf("ionice level must be between 0 and 7, got %d", p.IONiceLevel)
+	}
+	return nil
+}
+
+func (p Priority) prefix() []string {
+	var prefix []string
+	if p.Nice != 0 {
+		prefix = append(prefix, "nice", "-n", strconv.Itoa(p.Nice))
+	}
+	if class, ok := ioniceClasses[p.IONiceClass]; ok {
+		prefix = append(prefix, "ionice", "-c", class)
+		if p.IONiceClass != "idle" {
+			prefix = append(prefix, "-n", strconv.Itoa(p.IONiceLevel))
+		}
+	}
+	return prefix
+}
+
+// wrap rewrites cmd so that it is run through nice and ionice. Wrapping the
+// command rather than adjusting the process after it starts means any
+// processes it forks inherit the same priority.
+func (p Priority) wrap(cmd *exec.Cmd) error {
+	prefix := p.prefix()
+	if len(prefix) == 0 {
+		return nil
+	}
+
+	path, err := exec.LookPath(prefix[0])
+	if err != nil {
+		return err
+	}
+
+	args := append(prefix, cmd.Path)
+	cmd.Args = append(args, cmd.Args[1:]...)
+	cmd.Path = path
+	return nil
+}
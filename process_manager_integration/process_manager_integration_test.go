@@ -16,7 +16,7 @@ import (
 )
 
 var _ = Describe("process manager", func() {
-	performBackup := func(backupMock, uploadMock, evidenceFile, startedFile string) (*gexec.Session, error) {
+	performBackup := func(backupMock, uploadMock, evidenceFile, startedFile string, extraConfig ...string) (*gexec.Session, error) {
 		var err error
 		configFile, err := ioutil.TempFile("", "config.yml")
 		Expect(err).NotTo(HaveOccurred())
@@ -30,7 +30,7 @@ destinations:
 aws_cli_path: %s
 source_executable: %s
 cron_schedule: '* * * * * *'
-`, evidenceFile, startedFile, uploadMock, backupMock)
+%s`, evidenceFile, startedFile, uploadMock, backupMock, strings.Join(extraConfig, "\n"))
 		Expect(err).NotTo(HaveOccurred())
 
 		backupCmd := exec.Command(pathToServiceBackupBinary, configFile.Name())
@@ -128,6 +128,57 @@ cron_schedule: '* * * * * *'
 			backupsStarted := strings.Count(string(session.Out.Contents()), "Perform backup started")
 			Expect(backupsStarted).To(Equal(1))
 		})
+
+		It("lets the backup in progress finish within the drain period", func() {
+			sleepyTime := 2000
+
+			evidenceFile := testhelpers.GetTempFilePath()
+			startedFile := testhelpers.GetTempFilePath()
+			defer os.Remove(startedFile)
+			defer os.Remove(evidenceFile)
+
+			backupScriptMock := fmt.Sprintf("%s %s %s %d", pathToTermTrapperBinary, evidenceFile, startedFile, sleepyTime)
+			session, err := performBackup(backupScriptMock, "not-needed", evidenceFile, startedFile, "shutdown:\n  drain_seconds: 10\n")
+			Expect(err).NotTo(HaveOccurred())
+
+			By("waiting for the backup command to create the started file", func() {
+				Eventually(startedFile, 3).Should(BeAnExistingFile())
+			})
+
+			session.Terminate()
+
+			Eventually(session, 5).Should(gexec.Exit(0))
+			Expect(evidenceFile).NotTo(BeAnExistingFile())
+			Expect(session.Out).To(gbytes.Say("Draining backups in progress"))
+			Expect(session.Out).To(gbytes.Say("All backups drained"))
+
+			backupsStarted := strings.Count(string(session.Out.Contents()), "Perform backup started")
+			Expect(backupsStarted).To(Equal(1))
+		})
+
+		It("kills child backup commands that do not exit after the kill period", func() {
+			sleepyTime := 3000
+			sleepAfterSigterm := 10000
+
+			evidenceFile := testhelpers.GetTempFilePath()
+			startedFile := testhelpers.GetTempFilePath()
+			defer os.Remove(startedFile)
+			defer os.Remove(evidenceFile)
+
+			backupScriptMock := fmt.Sprintf("%s %s %s %d %d", pathToTermTrapperBinary, evidenceFile, startedFile, sleepyTime, sleepAfterSigterm)
+			session, err := performBackup(backupScriptMock, "not-needed", evidenceFile, startedFile, "shutdown:\n  kill_after_seconds: 1\n")
+			Expect(err).NotTo(HaveOccurred())
+
+			By("waiting for the backup command to create the started file", func() {
+				Eventually(startedFile, 3).Should(BeAnExistingFile())
+			})
+
+			session.Terminate()
+
+			Eventually(session, 5).Should(gexec.Exit(3))
+			Expect(evidenceFile).NotTo(BeAnExistingFile())
+			Expect(session.Out).To(gbytes.Say("Backup processes killed after not exiting on SIGTERM"))
+		})
 	})
 
 })
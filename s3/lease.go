@@ -0,0 +1,128 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package s3
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"code.cloudfoundry.org/lager/v3"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"github.com/pivotal-cf/service-backup/lease"
+)
+
+type leaseBackend struct {
+	client *S3CliClient
+	key    string
+	logger lager.Logger
+
+	// clientOptions are applied to the S3 client, to point it at a fake
+	// server in tests.
+	clientOptions []func(*s3.Options)
+}
+
+// LeaseBackend stores a lease as the object key in the bucket this client
+// uploads to. Conditional writes make sure only one node can take it.
+func (c *S3CliClient) LeaseBackend(key string, logger lager.Logger) lease.Backend {
+	return &leaseBackend{client: c, key: key, logger: logger}
+}
+
+func (b *leaseBackend) Acquire(ctx context.Context, l lease.Lease) (lease.Lease, error) {
+	c := b.client
+	client, err := CreateS3Client(b.logger, c.accessKey, c.secretKey, c.endpointURL, c.region, b.clientOptions...)
+	if err != nil {
+		return lease.Lease{}, fmt.Errorf("lease: couldn't create client: %v", err)
+	}
+	bucketName := strings.Split(c.remotePathFn(), "/")[0]
+
+	current, etag, err := b.get(ctx, client, bucketName)
+	if err != nil {
+		return lease.Lease{}, err
+	}
+	if !current.FreeFor(l.Holder, l.AcquiredAt) {
+		return current, nil
+	}
+
+	contents, err := lease.Encode(l)
+	if err != nil {
+		return lease.Lease{}, err
+	}
+	input := &s3.PutObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(b.key),
+		Body:   bytes.NewReader(contents),
+	}
+	if etag == "" {
+		input.IfNoneMatch = aws.String("*")
+	} else {
+		input.IfMatch = aws.String(etag)
+	}
+	if _, err := client.PutObject(ctx, input); err != nil {
+		if !conditionFailed(err) {
+			return lease.Lease{}, fmt.Errorf("lease: failed to put object: %v", err)
+		}
+		// Another node changed the lease since it was read.
+		current, _, err := b.get(ctx, client, bucketName)
+		return current, err
+	}
+	return l, nil
+}
+
+// get returns the stored lease and its ETag, or a free lease if none is
+// stored yet.
+func (b *leaseBackend) get(ctx context.Context, client *s3.Client, bucketName string) (lease.Lease, string, error) {
+	output, err := client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(b.key),
+	})
+	if err != nil {
+		var noSuchKey *types.NoSuchKey
+		if errors.As(err, &noSuchKey) {
+			return lease.Lease{}, "", nil
+		}
+		return lease.Lease{}, "", fmt.Errorf("lease: failed to get object: %v", err)
+	}
+	defer output.Body.Close()
+
+	contents, err := io.ReadAll(output.Body)
+	if err != nil {
+		return lease.Lease{}, "", fmt.Errorf("lease: failed to read object: %v", err)
+	}
+	current, err := lease.Decode(contents)
+	return current, aws.ToString(output.ETag), err
+}
+
+// conditionFailed reports whether a conditional write failed because the
+// object changed since it was read. S3-compatible stores differ in the error
+// codes they return for this, so the status code is checked first.
+func conditionFailed(err error) bool {
+	var responseError interface{ HTTPStatusCode() int }
+	if errors.As(err, &responseError) {
+		switch responseError.HTTPStatusCode() {
+		case http.StatusPreconditionFailed, http.StatusConflict:
+			return true
+		}
+	}
+
+	var apiError smithy.APIError
+	if !errors.As(err, &apiError) {
+		return false
+	}
+	switch apiError.ErrorCode() {
+	case "PreconditionFailed", "ConditionalRequestConflict":
+		return true
+	}
+	return false
+}
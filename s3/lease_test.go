@@ -0,0 +1,145 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package s3
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"code.cloudfoundry.org/lager/v3"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+	"github.com/pivotal-cf/service-backup/lease"
+)
+
+var _ = Describe("LeaseBackend", func() {
+	const key = "/leases/leader.json"
+
+	var (
+		server  *ghttp.Server
+		backend *leaseBackend
+		now     time.Time
+		mine    lease.Lease
+		theirs  lease.Lease
+	)
+
+	BeforeEach(func() {
+		server = ghttp.NewTLSServer()
+		client := New("s3", "", "https://s3.example.com", "eu-west-1", "access-key", "secret-key", "", func() string { return "bucket/path" })
+		backend = client.LeaseBackend("leases/leader.json", lager.NewLogger("lease-test")).(*leaseBackend)
+		// The bucket is addressed by virtual host, so every host is sent to
+		// the fake server.
+		backend.clientOptions = []func(*s3.Options){func(o *s3.Options) {
+			o.HTTPClient = redirectedClient(server)
+			o.RetryMaxAttempts = 1
+		}}
+
+		now = time.Now().UTC().Round(0)
+		mine = lease.Lease{Holder: "node-a", ScheduledAt: now, AcquiredAt: now, ExpiresAt: now.Add(time.Hour)}
+		theirs = lease.Lease{Holder: "node-b", ScheduledAt: now, AcquiredAt: now, ExpiresAt: now.Add(time.Hour)}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	respondWithLease := func(l lease.Lease, etag string) http.HandlerFunc {
+		contents, err := lease.Encode(l)
+		Expect(err).NotTo(HaveOccurred())
+		return ghttp.RespondWith(http.StatusOK, contents, http.Header{"ETag": {etag}})
+	}
+	respondWithError := func(status int, code string) http.HandlerFunc {
+		return ghttp.RespondWith(status, "<Error><Code>"+code+"</Code><Message>"+code+"</Message></Error>")
+	}
+	putLease := func(header string, value string) http.HandlerFunc {
+		return ghttp.CombineHandlers(
+			ghttp.VerifyRequest(http.MethodPut, key),
+			ghttp.VerifyHeaderKV(header, value),
+			func(w http.ResponseWriter, r *http.Request) {
+				body, err := io.ReadAll(r.Body)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(body)).To(ContainSubstring(`"holder":"node-a"`))
+			},
+		)
+	}
+
+	It("creates the lease when there is none", func() {
+		server.AppendHandlers(
+			ghttp.CombineHandlers(ghttp.VerifyRequest(http.MethodGet, key), respondWithError(http.StatusNotFound, "NoSuchKey")),
+			ghttp.CombineHandlers(putLease("If-None-Match", "*"), ghttp.RespondWith(http.StatusOK, nil)),
+		)
+
+		Expect(backend.Acquire(context.Background(), mine)).To(Equal(mine))
+		Expect(server.ReceivedRequests()).To(HaveLen(2))
+	})
+
+	It("replaces an expired lease only if it has not changed since it was read", func() {
+		expired := theirs
+		expired.ExpiresAt = now.Add(-time.Minute)
+		server.AppendHandlers(
+			ghttp.CombineHandlers(ghttp.VerifyRequest(http.MethodGet, key), respondWithLease(expired, `"etag-1"`)),
+			ghttp.CombineHandlers(putLease("If-Match", `"etag-1"`), ghttp.RespondWith(http.StatusOK, nil)),
+		)
+
+		Expect(backend.Acquire(context.Background(), mine)).To(Equal(mine))
+	})
+
+	It("returns the lease held by another node without writing", func() {
+		server.AppendHandlers(
+			ghttp.CombineHandlers(ghttp.VerifyRequest(http.MethodGet, key), respondWithLease(theirs, `"etag-1"`)),
+		)
+
+		Expect(backend.Acquire(context.Background(), mine)).To(Equal(theirs))
+		Expect(server.ReceivedRequests()).To(HaveLen(1))
+	})
+
+	It("returns the winner's lease when another node creates it first", func() {
+		server.AppendHandlers(
+			ghttp.CombineHandlers(ghttp.VerifyRequest(http.MethodGet, key), respondWithError(http.StatusNotFound, "NoSuchKey")),
+			ghttp.CombineHandlers(putLease("If-None-Match", "*"), respondWithError(http.StatusPreconditionFailed, "PreconditionFailed")),
+			ghttp.CombineHandlers(ghttp.VerifyRequest(http.MethodGet, key), respondWithLease(theirs, `"etag-2"`)),
+		)
+
+		Expect(backend.Acquire(context.Background(), mine)).To(Equal(theirs))
+	})
+
+	It("treats a conflict with any error code as a lost race", func() {
+		server.AppendHandlers(
+			ghttp.CombineHandlers(ghttp.VerifyRequest(http.MethodGet, key), respondWithError(http.StatusNotFound, "NoSuchKey")),
+			ghttp.CombineHandlers(putLease("If-None-Match", "*"), respondWithError(http.StatusConflict, "OperationAborted")),
+			ghttp.CombineHandlers(ghttp.VerifyRequest(http.MethodGet, key), respondWithLease(theirs, `"etag-2"`)),
+		)
+
+		Expect(backend.Acquire(context.Background(), mine)).To(Equal(theirs))
+	})
+
+	It("returns other errors writing the lease", func() {
+		server.AppendHandlers(
+			ghttp.CombineHandlers(ghttp.VerifyRequest(http.MethodGet, key), respondWithError(http.StatusNotFound, "NoSuchKey")),
+			ghttp.CombineHandlers(putLease("If-None-Match", "*"), respondWithError(http.StatusForbidden, "AccessDenied")),
+		)
+
+		_, err := backend.Acquire(context.Background(), mine)
+		Expect(err).To(MatchError(ContainSubstring("lease: failed to put object")))
+	})
+})
+
+// redirectedClient sends every request to server, whatever host it is for.
+func redirectedClient(server *ghttp.Server) *http.Client {
+	return &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return new(net.Dialer).DialContext(ctx, network, server.Addr())
+		},
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}}
+}
@@ -25,6 +25,9 @@ import (
 
 	"code.cloudfoundry.org/lager/v3"
 	"github.com/pivotal-cf/service-backup/process"
+	"github.com/pivotal-cf/service-backup/throttle"
+	"github.com/pivotal-cf/service-backup/tracing"
+	"github.com/pivotal-cf/service-backup/workers"
 )
 
 type S3CliClient struct {
@@ -37,6 +40,8 @@ type S3CliClient struct {
 	caCertPath   string
 	remotePathFn func() string
 	ProcessMgr   process.ProcessManager
+	Throttle     *throttle.Throttle
+	Workers      int
 }
 
 func New(name, awsCmdPath, endpointURL, region, accessKey, secretKey, caCertPath string, remotePathFn func() string) *S3CliClient {
@@ -72,9 +77,9 @@ func (c *S3CliClient) S3Cmd(args ...string) *exec.Cmd {
 	return cmd
 }
 
-func (c *S3CliClient) CreateBucketIfNeeded(client *s3.Client, remotePath string, sessionLogger lager.Logger) error {
+func (c *S3CliClient) CreateBucketIfNeeded(ctx context.Context, client *s3.Client, remotePath string, sessionLogger lager.Logger) error {
 	sessionLogger.Info("Checking for remote path", lager.Data{"remotePath": remotePath})
-	remotePathExists, err := c.bucketExists(client, remotePath, sessionLogger)
+	remotePathExists, err := c.bucketExists(ctx, client, remotePath, sessionLogger)
 	if err != nil {
 		return err
 	}
@@ -84,7 +89,7 @@ func (c *S3CliClient) CreateBucketIfNeeded(client *s3.Client, remotePath string,
 	}
 
 	sessionLogger.Info("Checking for remote path - remote path does not exist - making it now")
-	err = c.createBucket(client, remotePath)
+	err = c.createBucket(ctx, client, remotePath)
 	if err != nil {
 		if strings.Contains(err.Error(), "AccessDenied") {
 			sessionLogger.Error("Configured S3 user unable to create buckets", err)
@@ -96,7 +101,7 @@ func (c *S3CliClient) CreateBucketIfNeeded(client *s3.Client, remotePath string,
 	return nil
 }
 
-func (c *S3CliClient) bucketExists(client *s3.Client, fullRemoteFilePath string, sessionLogger lager.Logger) (bool, error) {
+func (c *S3CliClient) bucketExists(ctx context.Context, client *s3.Client, fullRemoteFilePath string, sessionLogger lager.Logger) (bool, error) {
 	remoteFilePathElements := strings.Split(fullRemoteFilePath, "/")
 	bucketName := remoteFilePathElements[0]
 
@@ -104,7 +109,7 @@ func (c *S3CliClient) bucketExists(client *s3.Client, fullRemoteFilePath string,
 		Bucket: &bucketName,
 	}
 
-	_, err := client.HeadBucket(context.TODO(), input)
+	_, err := client.HeadBucket(ctx, input)
 	if err != nil {
 		var apiError smithy.APIError
 
@@ -123,7 +128,7 @@ func (c *S3CliClient) bucketExists(client *s3.Client, fullRemoteFilePath string,
 	return true, nil
 }
 
-func (c *S3CliClient) createBucket(client *s3.Client, remotePath string) error {
+func (c *S3CliClient) createBucket(ctx context.Context, client *s3.Client, remotePath string) error {
 	bucketName := strings.Split(remotePath, "/")[0]
 	input := &s3.CreateBucketInput{
 		Bucket: aws.String(bucketName),
@@ -131,12 +136,12 @@ func (c *S3CliClient) createBucket(client *s3.Client, remotePath string) error {
 			LocationConstraint: types.BucketLocationConstraint(c.region),
 		},
 	}
-	_, err := client.CreateBucket(context.TODO(), input)
+	_, err := client.CreateBucket(ctx, input)
 
 	return err
 }
 
-func CreateS3Client(sessionLogger lager.Logger, accessKey, secretKey, endpointURL, region string) (*s3.Client, error) {
+func CreateS3Client(sessionLogger lager.Logger, accessKey, secretKey, endpointURL, region string, optFns ...func(*s3.Options)) (*s3.Client, error) {
 	if len(region) == 0 {
 		sessionLogger.Info("CreateS3Client: ===warning=== region is empty. therefore using default region us-west-2")
 		region = "us-west-2"
@@ -170,12 +175,12 @@ func CreateS3Client(sessionLogger lager.Logger, accessKey, secretKey, endpointUR
 		return nil, fmt.Errorf("UploadDir: failed to load SDK configuration, %v", err)
 	}
 
-	client := s3.NewFromConfig(cfg)
+	client := s3.NewFromConfig(cfg, optFns...)
 
 	return client, nil
 }
 
-func (c *S3CliClient) Upload(localPath string, sessionLogger lager.Logger, processManager process.ProcessManager) error {
+func (c *S3CliClient) Upload(ctx context.Context, localPath string, sessionLogger lager.Logger, processManager process.ProcessManager) error {
 	defer sessionLogger.Info("s3 completed")
 
 	c.ProcessMgr = processManager
@@ -183,25 +188,27 @@ func (c *S3CliClient) Upload(localPath string, sessionLogger lager.Logger, proce
 	remotePath := c.remotePathFn()
 
 	sessionLogger.Info(fmt.Sprintf("about to upload %s to S3 remote path %s", localPath, remotePath))
+	c.Throttle.Log(sessionLogger)
+	workers.Log(sessionLogger, c.Workers)
 
 	client, err := CreateS3Client(sessionLogger, c.accessKey, c.secretKey, c.endpointURL, c.region)
 	if err != nil {
 		return fmt.Errorf("upload: couldn't create client: %v", err)
 	}
 
-	err = c.CreateBucketIfNeeded(client, remotePath, sessionLogger)
+	err = c.CreateBucketIfNeeded(ctx, client, remotePath, sessionLogger)
 	if err != nil {
 		return err
 	}
 
-	return c.UploadDir(client, sessionLogger, localPath, remotePath)
+	return c.UploadDir(ctx, client, sessionLogger, localPath, remotePath)
 }
 
 func (c *S3CliClient) Name() string {
 	return c.name
 }
 
-func (c *S3CliClient) UploadFile(logger lager.Logger, client *s3.Client, localFilePath, fullRemoteFilePath string) error {
+func (c *S3CliClient) UploadFile(ctx context.Context, logger lager.Logger, client *s3.Client, localFilePath, fullRemoteFilePath string) error {
 	remoteFilePathElements := strings.Split(fullRemoteFilePath, "/")
 	bucketName := remoteFilePathElements[0]
 	remotePath := strings.Join(remoteFilePathElements[1:], "/")
@@ -214,10 +221,10 @@ func (c *S3CliClient) UploadFile(logger lager.Logger, client *s3.Client, localFi
 	}
 	fileReader := bytes.NewReader(readFile)
 	uploader := manager.NewUploader(client)
-	_, err = uploader.Upload(context.TODO(), &s3.PutObjectInput{
+	_, err = uploader.Upload(ctx, &s3.PutObjectInput{
 		Bucket: &bucketName,
 		Key:    &remotePath,
-		Body:   fileReader,
+		Body:   c.Throttle.Reader(fileReader, logger),
 	})
 	if err != nil {
 		return fmt.Errorf("UploadFile: failed to put object: %v", err)
@@ -226,16 +233,15 @@ func (c *S3CliClient) UploadFile(logger lager.Logger, client *s3.Client, localFi
 	return nil
 }
 
-func (c *S3CliClient) UploadDir(client *s3.Client, logger lager.Logger, localDir string, remotePath string) error {
-	err := filepath.Walk(localDir, func(filePath string, d os.FileInfo, err error) error {
-		if d.IsDir() {
-			return nil
-		}
-
+func (c *S3CliClient) UploadDir(ctx context.Context, client *s3.Client, logger lager.Logger, localDir string, remotePath string) error {
+	err := workers.ForEachFile(ctx, localDir, c.Workers, func(ctx context.Context, filePath string, d os.FileInfo) error {
 		relativeFilePath := strings.Replace(filePath, localDir, "", -1)
 		remoteFilePath := filepath.Join(remotePath, relativeFilePath)
 
-		return c.UploadFile(logger, client, filePath, remoteFilePath)
+		fileCtx, span := tracing.StartFileSpan(ctx, filePath, d.Size())
+		err := c.UploadFile(fileCtx, logger, client, filePath, remoteFilePath)
+		tracing.End(span, err)
+		return err
 	})
 	if err != nil {
 		return fmt.Errorf("UploadDir: failed to walk dir, %v", err)
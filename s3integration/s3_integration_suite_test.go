@@ -7,6 +7,7 @@
 package s3integration_test
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/pivotal-cf/service-backup/s3"
@@ -109,8 +110,8 @@ func beforeSuiteFirstNode() []byte {
 	logger := lager.NewLogger("before-suite")
 	s3TestClient = s3testclient.New("", awsAccessKeyID, awsSecretAccessKey, existingBucketInDefaultRegion, region)
 	s3Client, err := s3.CreateS3Client(logger, awsAccessKeyID, awsSecretAccessKey, "", region)
-	Expect(s3TestClient.CreateBucketIfNeeded(s3Client, existingBucketInDefaultRegion, logger)).To(Succeed())
-	Expect(s3TestClient.CreateBucketIfNeeded(s3Client, existingBucketInNonDefaultRegion, logger)).To(Succeed())
+	Expect(s3TestClient.CreateBucketIfNeeded(context.Background(), s3Client, existingBucketInDefaultRegion, logger)).To(Succeed())
+	Expect(s3TestClient.CreateBucketIfNeeded(context.Background(), s3Client, existingBucketInNonDefaultRegion, logger)).To(Succeed())
 
 	return data
 }
@@ -0,0 +1,125 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package scheduler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pivotal-cf/service-backup/notify"
+)
+
+type alertKey struct {
+	serviceInstanceID string
+	failureClass      string
+}
+
+type alertRecord struct {
+	firstFailure time.Time
+	lastAlerted  time.Time
+	lastErr      error
+	failures     int
+	suppressed   int
+}
+
+// alertLimiter keeps track of the failures of each service instance, by
+// failure class, so that a failure that keeps repeating is alerted once per
+// renotify interval rather than on every run.
+type alertLimiter struct {
+	lock             sync.Mutex
+	renotifyInterval time.Duration
+	records          map[alertKey]*alertRecord
+}
+
+func newAlertLimiter() *alertLimiter {
+	return &alertLimiter{records: map[alertKey]*alertRecord{}}
+}
+
+// failed records a failure, reporting whether it should be alerted. With no
+// renotify interval, every failure is.
+func (l *alertLimiter) failed(key alertKey, now time.Time, err error) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	record, ok := l.records[key]
+	if !ok {
+		record = &alertRecord{firstFailure: now}
+		l.records[key] = record
+	}
+	record.failures++
+	record.lastErr = err
+
+	if ok && l.renotifyInterval > 0 && now.Sub(record.lastAlerted) < l.renotifyInterval {
+		record.suppressed++
+		return false
+	}
+	record.lastAlerted = now
+	return true
+}
+
+// recovered forgets the failed runs of a service instance, returning how many
+// there were and when the first happened. Cleanup failures happen on
+// successful runs, so they are kept until the cleanup succeeds.
+func (l *alertLimiter) recovered(serviceInstanceID string) (int, time.Time) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	var (
+		failures int
+		since    time.Time
+	)
+	for key, record := range l.records {
+		if key.serviceInstanceID != serviceInstanceID || key.failureClass == notify.FailureClassCleanup {
+			continue
+		}
+		failures += record.failures
+		if since.IsZero() || record.firstFailure.Before(since) {
+			since = record.firstFailure
+		}
+		delete(l.records, key)
+	}
+	return failures, since
+}
+
+// clear forgets the failures of one failure class.
+func (l *alertLimiter) clear(key alertKey) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	delete(l.records, key)
+}
+
+// digest describes the failures that were not alerted since the last digest,
+// one line per service instance and failure class, and resets their count.
+func (l *alertLimiter) digest() []string {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	var lines []string
+	for key, record := range l.records {
+		if record.suppressed == 0 {
+			continue
+		}
+		instance := key.serviceInstanceID
+		if instance == "" {
+			instance = "backup"
+		}
+		lines = append(lines, fmt.Sprintf(
+			"%s (%s): %d failures not alerted, failing since %s, last error: %s",
+			instance, key.failureClass, record.suppressed, record.firstFailure.UTC().Format(time.RFC3339), record.lastErr,
+		))
+		record.suppressed = 0
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+func digestMessage(lines []string) string {
+	return "Repeated backup failures were not alerted individually:\n" + strings.Join(lines, "\n")
+}
@@ -0,0 +1,154 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/pivotal-cf/service-backup/config"
+	cron "github.com/robfig/cron/v3"
+)
+
+// BlackoutAction says what happens to a scheduled run that is due during a
+// blackout window.
+type BlackoutAction string
+
+const (
+	BlackoutSkip  BlackoutAction = "skip"
+	BlackoutDefer BlackoutAction = "defer"
+)
+
+// maxChainedBlackouts bounds the search for the end of overlapping windows.
+const maxChainedBlackouts = 100
+
+// BlackoutWindow is a period in which scheduled backups must not start. It
+// either recurs, starting on a cron schedule and lasting a fixed duration, or
+// runs once between two absolute times.
+type BlackoutWindow struct {
+	Name   string
+	Action BlackoutAction
+
+	start    cron.Schedule
+	duration time.Duration
+
+	from, to time.Time
+}
+
+// BlackoutWindowsFromConfig builds the configured blackout windows, with
+// recurring windows starting by the wall clock of timezone.
+func BlackoutWindowsFromConfig(windows []config.BlackoutWindow, timezone string) ([]BlackoutWindow, error) {
+	var blackoutWindows []BlackoutWindow
+	for i, conf := range windows {
+		window, err := newBlackoutWindow(conf, timezone)
+		if window.Name == "" {
+			window.Name = fmt.Sprintf("blackout_windows[%d]", i)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid blackout window %s: %s", window.Name, err)
+		}
+		blackoutWindows = append(blackoutWindows, window)
+	}
+	return blackoutWindows, nil
+}
+
+func newBlackoutWindow(conf config.BlackoutWindow, timezone string) (BlackoutWindow, error) {
+	window := BlackoutWindow{Name: conf.Name, Action: BlackoutAction(conf.Action)}
+	switch window.Action {
+	case "":
+		window.Action = BlackoutSkip
+	case BlackoutSkip, BlackoutDefer:
+	default:
+		return window, fmt.Errorf("unknown action: %s", conf.Action)
+	}
+
+	switch {
+	case conf.Schedule != "" && (conf.From != "" || conf.To != ""):
+		return window, errors.New("set either schedule or from and to, not both")
+	case conf.Schedule != "":
+		if conf.DurationSeconds <= 0 {
+			return window, errors.New("duration_seconds must be positive")
+		}
+		start, err := ParseSchedule(conf.Schedule, timezone)
+		if err != nil {
+			return window, err
+		}
+		window.start = start
+		window.duration = time.Duration(conf.DurationSeconds) * time.Second
+	default:
+		from, err := time.Parse(time.RFC3339, conf.From)
+		if err != nil {
+			return window, fmt.Errorf("from must be an RFC 3339 time: %s", err)
+		}
+		to, err := time.Parse(time.RFC3339, conf.To)
+		if err != nil {
+			return window, fmt.Errorf("to must be an RFC 3339 time: %s", err)
+		}
+		if !to.After(from) {
+			return window, errors.New("to must be after from")
+		}
+		window.from, window.to = from, to
+	}
+	return window, nil
+}
+
+// end returns when the occurrence of the window that t falls in ends, if t
+// falls in one.
+func (w BlackoutWindow) end(t time.Time) (time.Time, bool) {
+	if w.start == nil {
+		if !t.Before(w.from) && t.Before(w.to) {
+			return w.to, true
+		}
+		return time.Time{}, false
+	}
+
+	// The latest occurrence that could still be running started after
+	// t - duration.
+	start := w.start.Next(t.Add(-w.duration))
+	if start.IsZero() || start.After(t) {
+		return time.Time{}, false
+	}
+	return start.Add(w.duration), true
+}
+
+// Blackout returns the name of the first of windows that at falls in, if
+// any, and the first time from at on that falls in none of them.
+func Blackout(windows []BlackoutWindow, at time.Time) (string, time.Time, bool) {
+	window, end, in := blackouts(windows).at(at)
+	return window.Name, end, in
+}
+
+type blackouts []BlackoutWindow
+
+// at returns the first window that t falls in, and the first time from t on
+// that falls in no window at all.
+func (b blackouts) at(t time.Time) (BlackoutWindow, time.Time, bool) {
+	window, ok := b.window(t)
+	if !ok {
+		return BlackoutWindow{}, time.Time{}, false
+	}
+
+	clear := t
+	for i := 0; i < maxChainedBlackouts; i++ {
+		w, ok := b.window(clear)
+		if !ok {
+			break
+		}
+		clear, _ = w.end(clear)
+	}
+	return window, clear, true
+}
+
+func (b blackouts) window(t time.Time) (BlackoutWindow, bool) {
+	for _, w := range b {
+		if _, ok := w.end(t); ok {
+			return w, true
+		}
+	}
+	return BlackoutWindow{}, false
+}
@@ -0,0 +1,152 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package scheduler
+
+import (
+	"time"
+
+	"code.cloudfoundry.org/lager/v3"
+	"github.com/pivotal-cf/service-backup/config"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("Blackout windows", func() {
+	var (
+		// Monday
+		monday = time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC)
+		peak   = config.BlackoutWindow{Name: "peak", Schedule: "0 0 18 * * 1-5", DurationSeconds: 4 * 60 * 60, Action: "defer"}
+		freeze = config.BlackoutWindow{Name: "freeze", From: "2026-12-24T00:00:00Z", To: "2026-12-27T00:00:00Z"}
+	)
+
+	windowsFromConfig := func(windows ...config.BlackoutWindow) []BlackoutWindow {
+		blackoutWindows, err := BlackoutWindowsFromConfig(windows, "UTC")
+		Expect(err).NotTo(HaveOccurred())
+		return blackoutWindows
+	}
+
+	Describe("BlackoutWindowsFromConfig", func() {
+		It("skips runs by default and names windows by their position", func() {
+			windows := windowsFromConfig(peak, config.BlackoutWindow{From: freeze.From, To: freeze.To})
+
+			Expect(windows[0].Name).To(Equal("peak"))
+			Expect(windows[0].Action).To(Equal(BlackoutDefer))
+			Expect(windows[1].Name).To(Equal("blackout_windows[1]"))
+			Expect(windows[1].Action).To(Equal(BlackoutSkip))
+		})
+
+		DescribeTable("rejects invalid windows",
+			func(window config.BlackoutWindow, message string) {
+				window.Name = "bad"
+				_, err := BlackoutWindowsFromConfig([]config.BlackoutWindow{window}, "UTC")
+				Expect(err).To(MatchError(ContainSubstring("invalid blackout window bad: " + message)))
+			},
+			Entry("unknown action", config.BlackoutWindow{From: freeze.From, To: freeze.To, Action: "pause"}, "unknown action: pause"),
+			Entry("both kinds", config.BlackoutWindow{Schedule: peak.Schedule, DurationSeconds: 60, From: freeze.From}, "set either schedule or from and to, not both"),
+			Entry("no duration", config.BlackoutWindow{Schedule: peak.Schedule}, "duration_seconds must be positive"),
+			Entry("bad schedule", config.BlackoutWindow{Schedule: "whenever", DurationSeconds: 60}, "expected 5 to 6 fields"),
+			Entry("bad from", config.BlackoutWindow{From: "Christmas", To: freeze.To}, "from must be an RFC 3339 time"),
+			Entry("to before from", config.BlackoutWindow{From: freeze.To, To: freeze.From}, "to must be after from"),
+		)
+	})
+
+	Describe("Blackout", func() {
+		It("finds recurring windows", func() {
+			windows := windowsFromConfig(peak)
+
+			window, end, in := Blackout(windows, monday.Add(19*time.Hour))
+			Expect(in).To(BeTrue())
+			Expect(window).To(Equal("peak"))
+			Expect(end).To(Equal(monday.Add(22 * time.Hour)))
+
+			_, _, in = Blackout(windows, monday.Add(18*time.Hour))
+			Expect(in).To(BeTrue())
+			_, _, in = Blackout(windows, monday.Add(22*time.Hour))
+			Expect(in).To(BeFalse())
+			_, _, in = Blackout(windows, monday.Add(17*time.Hour))
+			Expect(in).To(BeFalse())
+			_, _, in = Blackout(windows, monday.AddDate(0, 0, 5).Add(19*time.Hour))
+			Expect(in).To(BeFalse(), "no window on Saturdays")
+		})
+
+		It("finds absolute windows", func() {
+			windows := windowsFromConfig(freeze)
+
+			_, end, in := Blackout(windows, time.Date(2026, time.December, 25, 12, 0, 0, 0, time.UTC))
+			Expect(in).To(BeTrue())
+			Expect(end).To(Equal(time.Date(2026, time.December, 27, 0, 0, 0, 0, time.UTC)))
+
+			_, _, in = Blackout(windows, time.Date(2026, time.December, 27, 0, 0, 0, 0, time.UTC))
+			Expect(in).To(BeFalse())
+		})
+
+		It("runs on to the end of overlapping windows", func() {
+			late := config.BlackoutWindow{Name: "late", Schedule: "0 0 21 * * *", DurationSeconds: 3 * 60 * 60}
+			windows := windowsFromConfig(peak, late)
+
+			window, end, in := Blackout(windows, monday.Add(19*time.Hour))
+			Expect(in).To(BeTrue())
+			Expect(window).To(Equal("peak"))
+			Expect(end).To(Equal(monday.Add(24 * time.Hour)))
+		})
+	})
+
+	Describe("deciding whether a scheduled run starts", func() {
+		var (
+			log    *gbytes.Buffer
+			logger lager.Logger
+		)
+
+		BeforeEach(func() {
+			log = gbytes.NewBuffer()
+			logger = lager.NewLogger("scheduler-test")
+			logger.RegisterSink(lager.NewWriterSink(log, lager.DEBUG))
+		})
+
+		newScheduler := func(windows ...config.BlackoutWindow) Scheduler {
+			return NewScheduler(new(fakeExecutor), config.BackupConfig{CronSchedule: "@monthly"}, nil, logger,
+				WithBlackoutWindows(windowsFromConfig(windows...)...))
+		}
+
+		absolute := func(action string, from, to time.Time) config.BlackoutWindow {
+			return config.BlackoutWindow{Name: "now", From: from.Format(time.RFC3339Nano), To: to.Format(time.RFC3339Nano), Action: action}
+		}
+
+		It("starts runs outside every window", func() {
+			Expect(newScheduler(peak).waitForBlackouts(monday.Add(12 * time.Hour))).To(BeTrue())
+			Expect(log).To(gbytes.Say("No blackout window, starting scheduled backup"))
+		})
+
+		It("skips runs in a skipping window", func() {
+			Expect(newScheduler(freeze).waitForBlackouts(time.Date(2026, time.December, 25, 0, 0, 0, 0, time.UTC))).To(BeFalse())
+			Expect(log).To(gbytes.Say("Skipping scheduled backup during blackout window"))
+		})
+
+		It("defers runs in a deferring window to its end", func() {
+			now := time.Now()
+			s := newScheduler(absolute("defer", now.Add(-time.Minute), now.Add(20*time.Millisecond)))
+
+			Expect(s.waitForBlackouts(now)).To(BeTrue())
+			Expect(time.Now()).To(BeTemporally(">=", now.Add(20*time.Millisecond)))
+			Expect(log).To(gbytes.Say("Deferring scheduled backup to the end of blackout window"))
+			Expect(log).To(gbytes.Say("Blackout window ended, starting deferred backup"))
+		})
+
+		It("defers only one run at a time", func() {
+			now := time.Now()
+			s := newScheduler(absolute("defer", now.Add(-time.Minute), now.Add(time.Hour)))
+			go s.waitForBlackouts(now)
+			Eventually(s.deferred.Load).Should(BeTrue())
+
+			Expect(s.waitForBlackouts(now)).To(BeFalse())
+			Expect(log).To(gbytes.Say("another is already deferred"))
+			s.Stop()
+		})
+	})
+})
@@ -0,0 +1,129 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package scheduler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	cron "github.com/robfig/cron/v3"
+)
+
+// CatchUpPolicy says what to do on startup about a scheduled run that was
+// missed while the daemon was not running.
+type CatchUpPolicy string
+
+const (
+	CatchUpImmediate CatchUpPolicy = "immediate"
+	CatchUpDelayed   CatchUpPolicy = "delayed"
+	CatchUpSkip      CatchUpPolicy = "skip"
+
+	DefaultCatchUpMinInterval = time.Hour
+)
+
+// ParseCatchUpPolicy parses a catch-up policy, which defaults to
+// CatchUpImmediate.
+func ParseCatchUpPolicy(policy string) (CatchUpPolicy, error) {
+	switch CatchUpPolicy(policy) {
+	case "":
+		return CatchUpImmediate, nil
+	case CatchUpImmediate, CatchUpDelayed, CatchUpSkip:
+		return CatchUpPolicy(policy), nil
+	default:
+		return "", fmt.Errorf("unknown catch-up policy: %s", policy)
+	}
+}
+
+// runState is persisted between restarts to find missed runs, and so that
+// the age of the last successful backup survives a restart.
+type runState struct {
+	LastScheduledRun time.Time `json:"last_scheduled_run"`
+	LastCatchUp      time.Time `json:"last_catch_up,omitempty"`
+	LastSuccess      time.Time `json:"last_success,omitempty"`
+}
+
+// stateFile holds the runState persisted between restarts.
+type stateFile struct {
+	path string
+	lock sync.Mutex
+}
+
+// catchUp records scheduled runs in a state file, so that a run missed while
+// the daemon was down can be made up on startup.
+type catchUp struct {
+	*stateFile
+	policy CatchUpPolicy
+	delay  time.Duration
+	// minInterval guards against a crash loop: a missed run is not caught up
+	// again within minInterval of the last catch-up, which may be what
+	// crashed.
+	minInterval time.Duration
+}
+
+func (c *stateFile) load() (runState, error) {
+	var state runState
+	contents, err := os.ReadFile(c.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return state, err
+	}
+	if err := json.Unmarshal(contents, &state); err != nil {
+		return state, fmt.Errorf("error parsing %s: %s", c.path, err)
+	}
+	return state, nil
+}
+
+// update changes the persisted state, replacing the state file atomically so
+// that a crash cannot leave it half written.
+func (c *stateFile) update(change func(*runState)) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	state, err := c.load()
+	if err != nil {
+		return err
+	}
+	change(&state)
+
+	contents, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	temp, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+	if _, err := temp.Write(contents); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), c.path)
+}
+
+// missedRun returns the first run due after the last one recorded, if it was
+// due before now.
+func missedRun(state runState, schedule cron.Schedule, now time.Time) (time.Time, bool) {
+	if state.LastScheduledRun.IsZero() {
+		return time.Time{}, false
+	}
+	missed := schedule.Next(state.LastScheduledRun)
+	if missed.IsZero() || !missed.Before(now) {
+		return time.Time{}, false
+	}
+	return missed, true
+}
@@ -0,0 +1,124 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pivotal-cf/service-backup/config"
+	"github.com/pivotal-cf/service-backup/executor"
+	cron "github.com/robfig/cron/v3"
+)
+
+// dueTolerance is how late a run can start and still count as due for the
+// destinations scheduled when it was triggered.
+const dueTolerance = time.Minute
+
+// destinationRunner is implemented by executors that can upload a backup to
+// only some of their destinations.
+type destinationRunner interface {
+	RunDestinations(destinations []string) executor.RunReport
+}
+
+// destinationSchedules tracks which destinations are due when destinations
+// have schedules of their own.
+type destinationSchedules struct {
+	lock         sync.Mutex
+	destinations []*destinationSchedule
+}
+
+type destinationSchedule struct {
+	name     string
+	schedule cron.Schedule
+	// lastDue is the last scheduled time the destination was due, so that no
+	// scheduled time is uploaded twice.
+	lastDue time.Time
+}
+
+// newDestinationSchedules parses the schedules of destinations, which use
+// the global schedule if they have none of their own. It returns nil if no
+// destination has a schedule of its own.
+func newDestinationSchedules(destinations []config.Destination, global cron.Schedule, timezone string) (*destinationSchedules, error) {
+	own := false
+	for _, destination := range destinations {
+		own = own || destination.CronSchedule != ""
+	}
+	if !own {
+		return nil, nil
+	}
+
+	schedules := &destinationSchedules{}
+	names := map[string]bool{}
+	for _, destination := range destinations {
+		if destination.Name == "" || names[destination.Name] {
+			return nil, errors.New("destinations must have unique names when any has its own cron_schedule")
+		}
+		names[destination.Name] = true
+
+		schedule := global
+		if destination.CronSchedule != "" {
+			var err error
+			schedule, err = ParseSchedule(destination.CronSchedule, timezone)
+			if err != nil {
+				return nil, fmt.Errorf("invalid cron_schedule for destination %s: %s", destination.Name, err)
+			}
+		}
+		schedules.destinations = append(schedules.destinations, &destinationSchedule{name: destination.Name, schedule: schedule})
+	}
+	return schedules, nil
+}
+
+// due returns the destinations scheduled at some time up to at, within
+// dueTolerance, that they have not already been due for.
+func (d *destinationSchedules) due(at time.Time) []string {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	var due []string
+	for _, destination := range d.destinations {
+		from := at.Add(-dueTolerance)
+		if destination.lastDue.After(from) {
+			from = destination.lastDue
+		}
+		isDue := false
+		for next := destination.schedule.Next(from); !next.IsZero() && !next.After(at); next = destination.schedule.Next(next) {
+			destination.lastDue = next
+			isDue = true
+		}
+		if !isDue {
+			continue
+		}
+		due = append(due, destination.name)
+	}
+	return due
+}
+
+// schedules returns every schedule that a destination is due on.
+func (d *destinationSchedules) schedules() []cron.Schedule {
+	schedules := make([]cron.Schedule, len(d.destinations))
+	for i, destination := range d.destinations {
+		schedules[i] = destination.schedule
+	}
+	return schedules
+}
+
+// unionSchedule runs whenever any of its schedules does.
+type unionSchedule []cron.Schedule
+
+func (u unionSchedule) Next(t time.Time) time.Time {
+	var next time.Time
+	for _, schedule := range u {
+		n := schedule.Next(t)
+		if !n.IsZero() && (next.IsZero() || n.Before(next)) {
+			next = n
+		}
+	}
+	return next
+}
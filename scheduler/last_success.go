@@ -0,0 +1,45 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package scheduler
+
+import (
+	"sync"
+	"time"
+)
+
+// lastSuccess tracks when a backup last succeeded, and whether the scheduler
+// has alerted that the next one is overdue since then.
+type lastSuccess struct {
+	lock    sync.Mutex
+	at      time.Time
+	overdue bool
+}
+
+// succeeded records a successful backup, reporting whether it had been
+// alerted as overdue.
+func (l *lastSuccess) succeeded(at time.Time) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	wasOverdue := l.overdue
+	l.at = at
+	l.overdue = false
+	return wasOverdue
+}
+
+// checkOverdue reports the time of the last success, and whether it became
+// older than maxAge since the previous check.
+func (l *lastSuccess) checkOverdue(now time.Time, maxAge time.Duration) (time.Time, bool) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.overdue || now.Sub(l.at) <= maxAge {
+		return l.at, false
+	}
+	l.overdue = true
+	return l.at, true
+}
@@ -0,0 +1,68 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	"code.cloudfoundry.org/lager/v3"
+	"github.com/pivotal-cf/service-backup/lease"
+)
+
+// leaseTimeout bounds how long taking the lease may hold up a scheduled run.
+const leaseTimeout = time.Minute
+
+// leaderElection makes nodes backing up the same data take turns, so that
+// only the node holding the lease runs each scheduled backup.
+type leaderElection struct {
+	backend lease.Backend
+	holder  string
+	ttl     time.Duration
+}
+
+// elected reports whether this node should run the backup scheduled at
+// scheduledAt, taking the lease if leader election is configured and logging
+// who holds it and until when. A node that cannot reach the lease backs up
+// anyway, as a duplicate backup is better than none.
+func (s Scheduler) elected(scheduledAt time.Time) bool {
+	if s.leader == nil {
+		return true
+	}
+
+	now := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), leaseTimeout)
+	defer cancel()
+	held, err := s.leader.backend.Acquire(ctx, lease.Lease{
+		Holder:      s.leader.holder,
+		ScheduledAt: scheduledAt,
+		AcquiredAt:  now,
+		ExpiresAt:   now.Add(s.leader.ttl),
+	})
+	if err != nil {
+		s.logger.Error("Error taking the backup lease, backing up anyway", err, lager.Data{
+			"holder":       s.leader.holder,
+			"scheduled_at": scheduledAt.UTC(),
+		})
+		return true
+	}
+
+	data := lager.Data{
+		"holder":           held.Holder,
+		"lease_expires_at": held.ExpiresAt.UTC(),
+		"scheduled_at":     scheduledAt.UTC(),
+	}
+	if held.HeldBy(s.leader.holder, now) {
+		s.logger.Info("Holding the backup lease, backing up as leader", data)
+		return true
+	}
+	s.logger.Info("Another node holds the backup lease, skipping scheduled backup", data)
+	// The leader alerts if its backup fails, so a follower must not alert
+	// that backups are overdue.
+	s.lastSuccess.succeeded(now)
+	return false
+}
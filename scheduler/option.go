@@ -0,0 +1,133 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package scheduler
+
+import (
+	"time"
+
+	"github.com/pivotal-cf/service-backup/lease"
+	"github.com/pivotal-cf/service-backup/notify"
+)
+
+type Option func(*Scheduler)
+
+// WithNextRunFunc registers a function that is told when the next backup is
+// due, once the scheduler starts and again after every run.
+func WithNextRunFunc(fn func(time.Time)) Option {
+	return func(s *Scheduler) {
+		s.nextRunFuncs = append(s.nextRunFuncs, fn)
+	}
+}
+
+// WithStartFunc registers a function that is told, before every scheduled
+// run, when it was due and when it actually started.
+func WithStartFunc(fn func(scheduled, started time.Time)) Option {
+	return func(s *Scheduler) {
+		s.startFuncs = append(s.startFuncs, fn)
+	}
+}
+
+// WithSplay delays every scheduled run by a fixed offset below maxOffset,
+// derived from instanceKey, plus a random delay below maxRandom, so that
+// instances sharing a schedule spread their load. The total should be kept
+// well below the interval between scheduled runs.
+func WithSplay(maxRandom, maxOffset time.Duration, instanceKey string) Option {
+	return func(s *Scheduler) {
+		s.splay = newSplay(maxRandom, maxOffset, instanceKey)
+	}
+}
+
+// WithCatchUp makes the scheduler record each scheduled run in the file at
+// statePath, and on startup deal with a run missed while it was not running
+// according to policy: run it immediately, run it after delay, or skip it.
+// A missed run is not caught up again within minInterval of the last catch-up,
+// or within DefaultCatchUpMinInterval if minInterval is zero.
+func WithCatchUp(statePath string, policy CatchUpPolicy, delay, minInterval time.Duration) Option {
+	return func(s *Scheduler) {
+		if minInterval <= 0 {
+			minInterval = DefaultCatchUpMinInterval
+		}
+		s.catchUp = &catchUp{
+			stateFile:   &stateFile{path: statePath},
+			policy:      policy,
+			delay:       delay,
+			minInterval: minInterval,
+		}
+	}
+}
+
+// WithBlackoutWindows stops scheduled runs, and catch-up runs, starting
+// during any of windows: each is skipped or deferred to the end of the window
+// as the first window it falls in says.
+func WithBlackoutWindows(windows ...BlackoutWindow) Option {
+	return func(s *Scheduler) {
+		s.blackouts = append(s.blackouts, windows...)
+	}
+}
+
+// WithLeaderElection makes the scheduler take the lease in backend as holder
+// before every scheduled run, and catch-up run, and skip the run if another
+// node holds it. A lease taken is held for ttl, or lease.DefaultTTL if ttl is
+// zero, which should outlast any splay between the nodes.
+func WithLeaderElection(backend lease.Backend, holder string, ttl time.Duration) Option {
+	return func(s *Scheduler) {
+		if ttl <= 0 {
+			ttl = lease.DefaultTTL
+		}
+		s.leader = &leaderElection{backend: backend, holder: holder, ttl: ttl}
+	}
+}
+
+// WithMaxBackupAge makes the scheduler alert through every channel when no
+// backup has succeeded for longer than maxAge, checking every checkInterval,
+// or every minute if checkInterval is zero. The age is counted from when the
+// scheduler was created until the first success, unless WithLastSuccessState
+// or WithCatchUp is also given.
+func WithMaxBackupAge(maxAge, checkInterval time.Duration) Option {
+	return func(s *Scheduler) {
+		if checkInterval <= 0 {
+			checkInterval = time.Minute
+		}
+		s.maxBackupAge = maxAge
+		s.overdueCheckInterval = checkInterval
+	}
+}
+
+// WithLastSuccessState makes the scheduler record each successful backup in
+// the file at statePath, and count the maximum backup age from the last one
+// recorded there when it starts, so that restarts do not reset the age.
+// Without it the state file of WithCatchUp, if given, is used.
+func WithLastSuccessState(statePath string) Option {
+	return func(s *Scheduler) {
+		s.successState = &stateFile{path: statePath}
+	}
+}
+
+// WithRenotifyInterval stops the scheduler alerting every failed run: a
+// failure of the same service instance, with the same failure class as one
+// already alerted, is alerted again only once interval has passed.
+func WithRenotifyInterval(interval time.Duration) Option {
+	return func(s *Scheduler) {
+		s.alertLimiter.renotifyInterval = interval
+	}
+}
+
+// WithDailyDigest makes the scheduler alert a summary of the failures it did
+// not alert on, every day at timeOfDay past midnight UTC.
+func WithDailyDigest(timeOfDay time.Duration) Option {
+	return func(s *Scheduler) {
+		s.digestAt = timeOfDay
+	}
+}
+
+// WithAlertChannel adds a channel that failed runs are alerted through, in
+// addition to the service alerts client if one was given.
+func WithAlertChannel(channel notify.Notifier) Option {
+	return func(s *Scheduler) {
+		s.alertChannels = append(s.alertChannels, channel)
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package scheduler
+
+import (
+	"fmt"
+	"time"
+
+	cron "github.com/robfig/cron/v3"
+)
+
+var cronParser = cron.NewParser(
+	cron.SecondOptional | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor,
+)
+
+// maxWallClockSteps bounds the search for a run time that exists, and has not
+// already passed, around a daylight saving transition.
+const maxWallClockSteps = 2 * 60 * 60
+
+// ParseSchedule parses a cron schedule to run in timezone, or in the local
+// timezone if timezone is empty. A CRON_TZ= or TZ= prefix on spec overrides
+// timezone.
+func ParseSchedule(spec, timezone string) (cron.Schedule, error) {
+	location, err := loadTimezone(timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	schedule, err := cronParser.Parse(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	specSchedule, ok := schedule.(*cron.SpecSchedule)
+	if !ok {
+		// Intervals such as @every are not tied to the wall clock.
+		return schedule, nil
+	}
+	if specSchedule.Location != time.Local {
+		location = specSchedule.Location
+	}
+	wallClock := *specSchedule
+	wallClock.Location = time.UTC
+	return wallClockSchedule{wallClock: &wallClock, location: location}, nil
+}
+
+// wallClockSchedule runs a cron schedule by the wall clock of a timezone.
+// Unlike a plain cron.SpecSchedule it runs each wall-clock time at most once:
+// a time skipped when clocks go forward runs as if the clocks had not
+// changed, that is an hour late by the new clock, and a time repeated when
+// clocks go back runs only the first time round.
+type wallClockSchedule struct {
+	// wallClock is the schedule in UTC, which has no transitions, applied to
+	// wall-clock times.
+	wallClock *cron.SpecSchedule
+	location  *time.Location
+}
+
+func (s wallClockSchedule) Next(t time.Time) time.Time {
+	local := t.In(s.location)
+	wall := time.Date(local.Year(), local.Month(), local.Day(), local.Hour(), local.Minute(), local.Second(), 0, time.UTC)
+	for i := 0; i < maxWallClockSteps; i++ {
+		wall = s.wallClock.Next(wall)
+		if wall.IsZero() {
+			return wall
+		}
+		next := firstInstant(wall, s.location)
+		if next.After(t) {
+			return next
+		}
+	}
+	return time.Time{}
+}
+
+// firstInstant returns the first instant at which the clock in location
+// shows the wall-clock time wall, given in UTC. If the clock skips wall, it
+// returns the instant that wall would be had the clock not changed.
+func firstInstant(wall time.Time, location *time.Location) time.Time {
+	first := time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), wall.Second(), 0, location)
+	for _, probe := range []time.Duration{-12 * time.Hour, 12 * time.Hour} {
+		_, offset := first.Add(probe).Zone()
+		candidate := wall.Add(-time.Duration(offset) * time.Second).In(location)
+		if candidate.Before(first) && sameWallClock(candidate, wall) {
+			first = candidate
+		}
+	}
+	return first
+}
+
+func sameWallClock(t, wall time.Time) bool {
+	return t.Year() == wall.Year() && t.YearDay() == wall.YearDay() &&
+		t.Hour() == wall.Hour() && t.Minute() == wall.Minute() && t.Second() == wall.Second()
+}
+
+func loadTimezone(timezone string) (*time.Location, error) {
+	if timezone == "" {
+		return time.Local, nil
+	}
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cron_timezone %q: %s", timezone, err)
+	}
+	return location, nil
+}
+
+// ScheduleLocation returns the timezone that spec runs in, read as
+// ParseSchedule reads it, for other times of day configured alongside the
+// schedule. It is timezone, or the local timezone, for an empty spec or one
+// that runs at fixed intervals.
+func ScheduleLocation(spec, timezone string) (*time.Location, error) {
+	if spec == "" {
+		return loadTimezone(timezone)
+	}
+	schedule, err := ParseSchedule(spec, timezone)
+	if err != nil {
+		return nil, err
+	}
+	if location := Location(schedule); location != nil {
+		return location, nil
+	}
+	return loadTimezone(timezone)
+}
+
+// Location returns the timezone that the schedule runs in, or nil if it runs
+// at fixed intervals.
+func Location(schedule cron.Schedule) *time.Location {
+	switch s := schedule.(type) {
+	case wallClockSchedule:
+		return s.location
+	case unionSchedule:
+		for _, schedule := range s {
+			if location := Location(schedule); location != nil {
+				return location
+			}
+		}
+	}
+	return nil
+}
+
+// NextRuns returns the next n times that schedule runs after from.
+func NextRuns(schedule cron.Schedule, from time.Time, n int) []time.Time {
+	var runs []time.Time
+	for len(runs) < n {
+		from = schedule.Next(from)
+		if from.IsZero() {
+			break
+		}
+		runs = append(runs, from)
+	}
+	return runs
+}
@@ -0,0 +1,149 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package scheduler
+
+import (
+	"time"
+
+	"code.cloudfoundry.org/lager/v3"
+	"github.com/pivotal-cf/service-backup/config"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("ParseSchedule", func() {
+	var berlin *time.Location
+
+	BeforeEach(func() {
+		var err error
+		berlin, err = time.LoadLocation("Europe/Berlin")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	utc := func(year int, month time.Month, day, hour, minute int) time.Time {
+		return time.Date(year, month, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	nextRuns := func(spec, timezone string, from time.Time, n int) []time.Time {
+		schedule, err := ParseSchedule(spec, timezone)
+		Expect(err).NotTo(HaveOccurred())
+		runs := NextRuns(schedule, from, n)
+		for i := range runs {
+			runs[i] = runs[i].UTC()
+		}
+		return runs
+	}
+
+	It("runs by the wall clock of the configured timezone", func() {
+		schedule, err := ParseSchedule("0 0 2 * * *", "Europe/Berlin")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(Location(schedule)).To(Equal(berlin))
+		Expect(nextRuns("0 0 2 * * *", "Europe/Berlin", utc(2026, time.January, 10, 12, 0), 2)).To(Equal([]time.Time{
+			utc(2026, time.January, 11, 1, 0),
+			utc(2026, time.January, 12, 1, 0),
+		}))
+	})
+
+	It("honours a CRON_TZ prefix over the configured timezone", func() {
+		schedule, err := ParseSchedule("CRON_TZ=America/New_York 0 0 2 * * *", "Europe/Berlin")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(Location(schedule).String()).To(Equal("America/New_York"))
+		Expect(NextRuns(schedule, utc(2026, time.January, 10, 12, 0), 1)[0].UTC()).To(Equal(utc(2026, time.January, 11, 7, 0)))
+	})
+
+	It("runs a time skipped when the clocks go forward an hour late, once", func() {
+		Expect(nextRuns("0 30 2 * * *", "Europe/Berlin", utc(2026, time.March, 28, 12, 0), 3)).To(Equal([]time.Time{
+			utc(2026, time.March, 29, 1, 30),
+			utc(2026, time.March, 30, 0, 30),
+			utc(2026, time.March, 31, 0, 30),
+		}))
+	})
+
+	It("does not run the skipped hour twice when the hour after it is also scheduled", func() {
+		Expect(nextRuns("0 30 2,3 * * *", "Europe/Berlin", utc(2026, time.March, 28, 12, 0), 2)).To(Equal([]time.Time{
+			utc(2026, time.March, 29, 1, 30),
+			utc(2026, time.March, 30, 0, 30),
+		}))
+	})
+
+	It("runs a time repeated when the clocks go back only once", func() {
+		Expect(nextRuns("0 30 2 * * *", "Europe/Berlin", utc(2026, time.October, 24, 12, 0), 2)).To(Equal([]time.Time{
+			utc(2026, time.October, 25, 0, 30),
+			utc(2026, time.October, 26, 1, 30),
+		}))
+		Expect(nextRuns("0 */30 * * * *", "Europe/Berlin", utc(2026, time.October, 25, 0, 0), 3)).To(Equal([]time.Time{
+			utc(2026, time.October, 25, 0, 30),
+			utc(2026, time.October, 25, 2, 0),
+			utc(2026, time.October, 25, 2, 30),
+		}))
+	})
+
+	Describe("ScheduleLocation", func() {
+		It("is the timezone of a CRON_TZ prefix, as the schedule runs in", func() {
+			location, err := ScheduleLocation("CRON_TZ=Europe/Berlin 0 2 * * *", "")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(location).To(Equal(berlin))
+		})
+
+		It("is the configured timezone without a prefix", func() {
+			location, err := ScheduleLocation("0 2 * * *", "Europe/Berlin")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(location).To(Equal(berlin))
+		})
+
+		It("is the configured timezone for intervals and an empty schedule", func() {
+			location, err := ScheduleLocation("@every 1h", "Europe/Berlin")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(location).To(Equal(berlin))
+
+			location, err = ScheduleLocation("", "")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(location).To(Equal(time.Local))
+		})
+
+		It("returns an error for an invalid timezone", func() {
+			_, err := ScheduleLocation("0 2 * * *", "Mars/Olympus_Mons")
+			Expect(err).To(MatchError(ContainSubstring(`invalid cron_timezone "Mars/Olympus_Mons"`)))
+		})
+	})
+
+	It("does not tie intervals to a timezone", func() {
+		schedule, err := ParseSchedule("@every 1h", "Europe/Berlin")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(Location(schedule)).To(BeNil())
+		Expect(nextRuns("@every 1h", "Europe/Berlin", utc(2026, time.October, 25, 0, 0), 3)).To(Equal([]time.Time{
+			utc(2026, time.October, 25, 1, 0),
+			utc(2026, time.October, 25, 2, 0),
+			utc(2026, time.October, 25, 3, 0),
+		}))
+	})
+
+	It("rejects unknown timezones", func() {
+		_, err := ParseSchedule("@daily", "Mars/Olympus_Mons")
+		Expect(err).To(MatchError(ContainSubstring(`invalid cron_timezone "Mars/Olympus_Mons"`)))
+
+		_, err = ParseSchedule("CRON_TZ=Mars/Olympus_Mons @daily", "")
+		Expect(err).To(MatchError(ContainSubstring("provided bad location Mars/Olympus_Mons")))
+	})
+
+	It("logs the next runs in the configured timezone and in UTC", func() {
+		log := gbytes.NewBuffer()
+		logger := lager.NewLogger("scheduler-test")
+		logger.RegisterSink(lager.NewWriterSink(log, lager.DEBUG))
+
+		NewScheduler(new(fakeExecutor), config.BackupConfig{CronSchedule: "0 0 2 * * *", CronTimezone: "Europe/Berlin"}, nil, logger)
+
+		Expect(log).To(gbytes.Say(`"message":"scheduler-test.Scheduled backups"`))
+		Expect(log).To(gbytes.Say(`"local":"\d{4}-\d\d-\d\dT02:00:00 CES?T"`))
+		Expect(log).To(gbytes.Say(`"timezone":"Europe/Berlin"`))
+	})
+})
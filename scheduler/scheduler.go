@@ -9,52 +9,590 @@ package scheduler
 import (
 	"fmt"
 	"os"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"code.cloudfoundry.org/lager/v3"
 	alerts "github.com/pivotal-cf/service-alerts-client/client"
 	"github.com/pivotal-cf/service-backup/config"
 	"github.com/pivotal-cf/service-backup/executor"
+	"github.com/pivotal-cf/service-backup/notify"
 	cron "github.com/robfig/cron/v3"
 	"github.com/tedsuo/ifrit"
 )
 
 type Scheduler struct {
-	cronSchedule *cron.Cron
-	logger       lager.Logger
-}
-
-func NewScheduler(e executor.Executor, backupConfig config.BackupConfig, alertsClient *alerts.ServiceAlertsClient, logger lager.Logger) Scheduler {
-	scheduler := cron.New(cron.WithParser(cron.NewParser(
-		cron.SecondOptional | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor,
-	)))
-
-	_, err := scheduler.AddFunc(backupConfig.CronSchedule, func() {
-		backupErr := e.Execute()
-		if backupErr != nil {
-			if alertsClient == nil {
-				logger.Info("Alerts not configured.", lager.Data{})
-			} else {
-				logger.Info("Sending alert.", lager.Data{})
-				content := fmt.Sprintf("A backup run has failed with the following error: %s", backupErr)
-				if err := alertsClient.SendServiceAlert(backupConfig.Alerts.ProductName, "Service Backup Failed", backupErr.(executor.ServiceInstanceError).ServiceInstanceID, content); err != nil {
-					logger.Error("error sending service alert", err, lager.Data{})
-					return
-				}
-				logger.Info("Sent alert.", lager.Data{})
-			}
+	cronSchedule  *cron.Cron
+	entryID       cron.EntryID
+	schedule      cron.Schedule
+	destinations  *destinationSchedules
+	executor      executor.Executor
+	backupConfig  config.BackupConfig
+	alertChannels []notify.Notifier
+	logger        lager.Logger
+	nextRunFuncs  []func(time.Time)
+	startFuncs    []func(scheduled, started time.Time)
+	splay         splay
+	paused        *atomic.Bool
+	blackouts     blackouts
+	deferred      *atomic.Bool
+
+	maxBackupAge         time.Duration
+	overdueCheckInterval time.Duration
+	lastSuccess          *lastSuccess
+	alertLimiter         *alertLimiter
+	digestAt             time.Duration
+	catchUp              *catchUp
+	successState         *stateFile
+	leader               *leaderElection
+	stop                 chan struct{}
+	stopOnce             *sync.Once
+}
+
+func NewScheduler(e executor.Executor, backupConfig config.BackupConfig, alertsClient *alerts.ServiceAlertsClient, logger lager.Logger, options ...Option) Scheduler {
+	scheduler := cron.New()
+
+	s := Scheduler{
+		cronSchedule: scheduler,
+		executor:     e,
+		backupConfig: backupConfig,
+		logger:       logger,
+		paused:       new(atomic.Bool),
+		deferred:     new(atomic.Bool),
+		lastSuccess:  &lastSuccess{at: time.Now()},
+		alertLimiter: newAlertLimiter(),
+		digestAt:     -1,
+		stop:         make(chan struct{}),
+		stopOnce:     new(sync.Once),
+	}
+	if alertsClient != nil {
+		severities, err := notify.NewSeverities(backupConfig.AlertSeverities)
+		if err != nil {
+			logger.Error("Error configuring alerts", err)
+			os.Exit(2)
 		}
-	})
+		serviceAlerts, err := notify.NewServiceAlerts(alertsClient, *backupConfig.Alerts, backupConfig.DeploymentName, severities)
+		if err != nil {
+			logger.Error("Error configuring alerts", err)
+			os.Exit(2)
+		}
+		s.alertChannels = append(s.alertChannels, serviceAlerts)
+	}
+	for _, opt := range options {
+		opt(&s)
+	}
+	if s.catchUp != nil && (s.successState == nil || s.successState.path == s.catchUp.path) {
+		s.successState = s.catchUp.stateFile
+	}
+	if s.successState != nil {
+		s.restoreLastSuccess(time.Now())
+	}
+
+	schedule, err := ParseSchedule(backupConfig.CronSchedule, backupConfig.CronTimezone)
+	if err != nil {
+		logger.Error("Error scheduling job", err)
+		os.Exit(2)
+	}
+	destinations, err := newDestinationSchedules(backupConfig.Destinations, schedule, backupConfig.CronTimezone)
 	if err != nil {
 		logger.Error("Error scheduling job", err)
 		os.Exit(2)
 	}
+	if destinations != nil {
+		s.destinations = destinations
+		schedule = unionSchedule(destinations.schedules())
+	}
+	s.schedule = schedule
+	s.entryID = scheduler.Schedule(schedule, cron.FuncJob(func() {
+		defer s.reportNextRun()
+		s.scheduledRun(time.Now())
+	}))
+	logNextRuns(logger, backupConfig.CronSchedule, schedule, time.Now())
+
+	return s
+}
+
+const loggedNextRuns = 3
+
+// logNextRuns logs when the schedule will next run, both in its timezone and
+// in UTC, so that operators can check it means what they intended.
+func logNextRuns(logger lager.Logger, spec string, schedule cron.Schedule, now time.Time) {
+	data := lager.Data{"cron_schedule": spec}
+	location := Location(schedule)
+	if location != nil {
+		data["timezone"] = location.String()
+	}
+
+	var nextRuns []map[string]string
+	for _, next := range NextRuns(schedule, now, loggedNextRuns) {
+		run := map[string]string{"utc": next.UTC().Format(time.RFC3339)}
+		if location != nil {
+			run["local"] = next.In(location).Format("2006-01-02T15:04:05 MST")
+		}
+		nextRuns = append(nextRuns, run)
+	}
+	data["next_runs"] = nextRuns
+	logger.Info("Scheduled backups", data)
+}
+
+// scheduledRun handles a run of the schedule due at scheduledAt. The run is
+// recorded before anything can skip it, so that only runs that never fired
+// count as missed on the next start.
+func (s Scheduler) scheduledRun(scheduledAt time.Time) {
+	s.recordScheduledRun(scheduledAt)
+	if !s.waitForSplay(scheduledAt) {
+		return
+	}
+	if !s.waitForBlackouts(time.Now()) {
+		return
+	}
+	if s.paused.Load() {
+		s.logger.Info("Schedule paused, skipping backup")
+		return
+	}
+	if !s.elected(scheduledAt) {
+		return
+	}
+	s.reportStart(scheduledAt, time.Now())
+	s.runScheduled(scheduledAt)
+}
+
+// waitForSplay waits out the splay delay of a run scheduled at scheduledAt,
+// returning false if the scheduler was stopped meanwhile.
+func (s Scheduler) waitForSplay(scheduledAt time.Time) bool {
+	delay := s.splay.delay()
+	if delay <= 0 {
+		return true
+	}
+
+	s.logger.Info("Delaying scheduled backup", lager.Data{
+		"scheduled_at":   scheduledAt.UTC(),
+		"delay_seconds":  delay.Seconds(),
+		"offset_seconds": s.splay.offset.Seconds(),
+	})
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-s.stop:
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// waitForBlackouts decides whether a run due at now may start, logging the
+// decision if any blackout windows are configured. A run due during a window
+// is skipped, or deferred to the end of the window; only one run at a time is
+// deferred. It returns false if the run must not start, or the scheduler was
+// stopped while it was deferred.
+func (s Scheduler) waitForBlackouts(now time.Time) bool {
+	if len(s.blackouts) == 0 {
+		return true
+	}
+	window, end, in := s.blackouts.at(now)
+	if !in {
+		s.logger.Info("No blackout window, starting scheduled backup")
+		return true
+	}
+
+	data := lager.Data{"window": window.Name, "window_end": end.UTC(), "action": window.Action}
+	if window.Action == BlackoutSkip {
+		s.logger.Info("Skipping scheduled backup during blackout window", data)
+		return false
+	}
+	if !s.deferred.CompareAndSwap(false, true) {
+		s.logger.Info("Skipping scheduled backup during blackout window, another is already deferred", data)
+		return false
+	}
+	defer s.deferred.Store(false)
+
+	s.logger.Info("Deferring scheduled backup to the end of blackout window", data)
+	timer := time.NewTimer(end.Sub(now))
+	defer timer.Stop()
+	select {
+	case <-s.stop:
+		return false
+	case <-timer.C:
+		s.logger.Info("Blackout window ended, starting deferred backup", data)
+		return true
+	}
+}
+
+// Blackout returns the blackout window that at falls in, if any, and the
+// first time from at on that falls in no window.
+func (s Scheduler) Blackout(at time.Time) (string, time.Time, bool) {
+	return Blackout(s.blackouts, at)
+}
+
+func (s Scheduler) reportStart(scheduledAt, startedAt time.Time) {
+	s.logger.Info("Starting scheduled backup", lager.Data{
+		"scheduled_at":  scheduledAt.UTC(),
+		"started_at":    startedAt.UTC(),
+		"delay_seconds": startedAt.Sub(scheduledAt).Seconds(),
+	})
+	for _, fn := range s.startFuncs {
+		fn(scheduledAt, startedAt)
+	}
+}
+
+// recordScheduledRun persists when a scheduled run was due, if catch-up runs
+// are configured.
+func (s Scheduler) recordScheduledRun(at time.Time) {
+	if s.catchUp == nil {
+		return
+	}
+	if err := s.catchUp.update(func(state *runState) { state.LastScheduledRun = at }); err != nil {
+		s.logger.Error("Error recording scheduled run", err, lager.Data{"state_path": s.catchUp.path})
+	}
+}
+
+// restoreLastSuccess counts the age of the last successful backup from the
+// one recorded in the state file, so that a daemon restarting more often than
+// the maximum backup age is still alerted as overdue. When none has been
+// recorded it records now, counting from the first start instead.
+func (s Scheduler) restoreLastSuccess(now time.Time) {
+	err := s.successState.update(func(state *runState) {
+		if state.LastSuccess.IsZero() {
+			state.LastSuccess = now
+		}
+		s.lastSuccess.at = state.LastSuccess
+	})
+	if err != nil {
+		s.logger.Error("Error restoring last successful backup", err, lager.Data{"state_path": s.successState.path})
+	}
+}
+
+func (s Scheduler) recordSuccess(at time.Time) {
+	if s.successState == nil {
+		return
+	}
+	if err := s.successState.update(func(state *runState) { state.LastSuccess = at }); err != nil {
+		s.logger.Error("Error recording successful backup", err, lager.Data{"state_path": s.successState.path})
+	}
+}
+
+// catchUpMissedRun runs a backup if one was scheduled while the scheduler was
+// not running, as the catch-up policy says.
+func (s Scheduler) catchUpMissedRun(now time.Time) {
+	state, err := s.catchUp.load()
+	if err != nil {
+		s.logger.Error("Error reading scheduled run state", err, lager.Data{"state_path": s.catchUp.path})
+		return
+	}
+	missed, ok := missedRun(state, s.schedule, now)
+	if !ok {
+		return
+	}
+
+	data := lager.Data{
+		"missed_run":         missed.UTC(),
+		"last_scheduled_run": state.LastScheduledRun.UTC(),
+		"policy":             s.catchUp.policy,
+	}
+	if s.catchUp.policy == CatchUpSkip {
+		s.logger.Info("Skipping missed scheduled backup", data)
+		return
+	}
+	if !state.LastCatchUp.IsZero() && now.Sub(state.LastCatchUp) < s.catchUp.minInterval {
+		data["last_catch_up"] = state.LastCatchUp.UTC()
+		data["min_interval_seconds"] = s.catchUp.minInterval.Seconds()
+		s.logger.Info("Not catching up missed scheduled backup, the last catch-up was too recent", data)
+		return
+	}
+	// The missed run is handled from here on, even if it ends up skipped.
+	err = s.catchUp.update(func(state *runState) {
+		state.LastScheduledRun = now
+		state.LastCatchUp = now
+	})
+	if err != nil {
+		// Without a record of this catch-up, a crash during it could repeat.
+		s.logger.Error("Error recording catch-up, not catching up missed scheduled backup", err, data)
+		return
+	}
+
+	if s.catchUp.policy == CatchUpDelayed && s.catchUp.delay > 0 {
+		data["delay_seconds"] = s.catchUp.delay.Seconds()
+		if next := s.NextRun(); !next.IsZero() && next.Before(now.Add(s.catchUp.delay)) {
+			s.logger.Info("Skipping missed scheduled backup, the next one is due first", data)
+			return
+		}
+		s.logger.Info("Delaying catch-up of missed scheduled backup", data)
+		timer := time.NewTimer(s.catchUp.delay)
+		defer timer.Stop()
+		select {
+		case <-s.stop:
+			return
+		case <-timer.C:
+		}
+	}
 
-	return Scheduler{cronSchedule: scheduler, logger: logger}
+	if !s.waitForBlackouts(time.Now()) {
+		return
+	}
+	if s.paused.Load() {
+		s.logger.Info("Schedule paused, skipping catch-up of missed scheduled backup", data)
+		return
+	}
+	if !s.elected(missed) {
+		return
+	}
+	s.logger.Info("Catching up missed scheduled backup", data)
+	s.RunNow()
+}
+
+// RunNow runs a backup immediately, to every destination, alerting on
+// failure exactly as a scheduled run would.
+func (s Scheduler) RunNow() {
+	s.handleReport(s.executor.Run())
+}
+
+// starter is implemented by executors that can claim a run before performing
+// it, so that a refused run is known about at once.
+type starter interface {
+	Start() (func() executor.RunReport, error)
+}
+
+// Trigger starts a backup to every destination in the background, as RunNow
+// does, returning an error at once if the executor refuses to start it.
+func (s Scheduler) Trigger() error {
+	starter, ok := s.executor.(starter)
+	if !ok {
+		go s.RunNow()
+		return nil
+	}
+	run, err := starter.Start()
+	if err != nil {
+		return err
+	}
+	go func() { s.handleReport(run()) }()
+	return nil
+}
+
+// runScheduled runs the backup scheduled at scheduledAt. When destinations
+// have schedules of their own, the backup is taken once and uploaded only to
+// the destinations due.
+func (s Scheduler) runScheduled(scheduledAt time.Time) {
+	if s.destinations == nil {
+		s.RunNow()
+		return
+	}
+
+	due := s.destinations.due(scheduledAt)
+	if len(due) == 0 {
+		s.logger.Info("No destination due, skipping scheduled backup", lager.Data{"scheduled_at": scheduledAt.UTC()})
+		return
+	}
+	runner, ok := s.executor.(destinationRunner)
+	if !ok {
+		s.RunNow()
+		return
+	}
+	s.logger.Info("Backing up to the destinations due", lager.Data{"destinations": due})
+	s.handleReport(runner.RunDestinations(due))
+}
+
+func (s Scheduler) handleReport(report executor.RunReport) {
+	if report.Skipped {
+		return
+	}
+	if report.Succeeded() {
+		s.recordSuccess(report.FinishedAt)
+		if s.lastSuccess.succeeded(report.FinishedAt) {
+			s.logger.Info("Backup no longer overdue", lager.Data{})
+			s.resolve(notify.NewEvent(notify.EventBackupOverdue, report))
+		}
+		s.resolve(notify.OutcomeEvent(report))
+		s.notifyRecovery(report)
+		s.checkCleanup(report)
+		return
+	}
+
+	s.alertFailure(notify.OutcomeEvent(report), report.Err)
+}
+
+// checkCleanup alerts, at its own severity, when the cleanup of a successful
+// run failed, and otherwise resolves any earlier cleanup alert.
+func (s Scheduler) checkCleanup(report executor.RunReport) {
+	event := notify.NewEvent(notify.EventCleanupFailed, report)
+	if report.CleanupErr == nil {
+		s.alertLimiter.clear(alertKey{serviceInstanceID: report.ServiceInstanceID, failureClass: notify.FailureClassCleanup})
+		s.resolve(event)
+		return
+	}
+	s.alertFailure(event, report.CleanupErr)
+}
+
+// alertFailure alerts a failure, unless it repeats one already alerted within
+// the renotify interval.
+func (s Scheduler) alertFailure(event notify.Event, err error) {
+	key := alertKey{serviceInstanceID: event.Report.ServiceInstanceID, failureClass: event.FailureClass()}
+	if !s.alertLimiter.failed(key, time.Now(), err) {
+		s.logger.Info("Not alerting repeated failure", lager.Data{
+			"service_instance_id":       key.serviceInstanceID,
+			"failure_class":             key.failureClass,
+			"renotify_interval_seconds": s.alertLimiter.renotifyInterval.Seconds(),
+		})
+		return
+	}
+	s.alert(event)
+}
+
+// notifyRecovery alerts that the service instance of a successful run is
+// backed up again, if its previous runs failed.
+func (s Scheduler) notifyRecovery(report executor.RunReport) {
+	failures, since := s.alertLimiter.recovered(report.ServiceInstanceID)
+	if failures == 0 {
+		return
+	}
+
+	subject := "Backups"
+	if report.ServiceInstanceID != "" {
+		subject = "Backups of " + report.ServiceInstanceID
+	}
+	event := notify.NewEvent(notify.EventRunRecovered, report)
+	event.Message = fmt.Sprintf("%s are succeeding again after %d failed runs since %s", subject, failures, since.UTC().Format(time.RFC3339))
+	s.alert(event)
+}
+
+// sendDigests alerts a summary of the failures that were not alerted, once a
+// day at the digest time, until the scheduler is stopped.
+func (s Scheduler) sendDigests() {
+	for {
+		timer := time.NewTimer(time.Until(nextDigest(time.Now(), s.digestAt)))
+		select {
+		case <-s.stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+			s.sendDigest()
+		}
+	}
+}
+
+func (s Scheduler) sendDigest() {
+	lines := s.alertLimiter.digest()
+	if len(lines) == 0 {
+		return
+	}
+	event := notify.NewEvent(notify.EventAlertDigest, executor.RunReport{})
+	event.Message = digestMessage(lines)
+	s.alert(event)
+}
+
+// nextDigest returns the first time after now that is digestAt past midnight
+// UTC.
+func nextDigest(now time.Time, digestAt time.Duration) time.Time {
+	now = now.UTC()
+	next := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC).Add(digestAt)
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// resolve tells the alert channels that can close alerts that the failure
+// behind them has cleared.
+func (s Scheduler) resolve(event notify.Event) {
+	for _, channel := range s.alertChannels {
+		resolver, ok := channel.(notify.Resolver)
+		if !ok {
+			continue
+		}
+		if err := resolver.Resolve(event); err != nil {
+			s.logger.Error("error resolving alert", err, lager.Data{"channel": channel.Name()})
+		}
+	}
+}
+
+func (s Scheduler) alert(event notify.Event) {
+	if len(s.alertChannels) == 0 {
+		s.logger.Info("Alerts not configured.", lager.Data{})
+		return
+	}
+
+	event.NextRun = s.NextRun()
+	for _, channel := range s.alertChannels {
+		channelData := lager.Data{"channel": channel.Name()}
+		s.logger.Info("Sending alert.", channelData)
+		if err := channel.Notify(event); err != nil {
+			s.logger.Error("error sending service alert", err, channelData)
+			continue
+		}
+		s.logger.Info("Sent alert.", channelData)
+	}
+}
+
+// watchLastSuccess alerts when no backup has succeeded for longer than the
+// maximum backup age, until the scheduler is stopped.
+func (s Scheduler) watchLastSuccess() {
+	ticker := time.NewTicker(s.overdueCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case now := <-ticker.C:
+			s.checkLastSuccess(now)
+		}
+	}
+}
+
+func (s Scheduler) checkLastSuccess(now time.Time) {
+	lastSuccessAt, overdue := s.lastSuccess.checkOverdue(now, s.maxBackupAge)
+	if !overdue {
+		return
+	}
+
+	s.logger.Info("No backup has succeeded within the maximum backup age", lager.Data{
+		"last_success":           lastSuccessAt.UTC(),
+		"max_backup_age_seconds": s.maxBackupAge.Seconds(),
+	})
+	report := executor.RunReport{
+		Err: fmt.Errorf("no backup has succeeded since %s, more than %s ago", lastSuccessAt.UTC().Format(time.RFC3339), s.maxBackupAge),
+	}
+	s.alert(notify.NewEvent(notify.EventBackupOverdue, report))
+}
+
+func (s Scheduler) Pause() {
+	s.paused.Store(true)
+	s.logger.Info("Schedule paused")
+}
+
+func (s Scheduler) Resume() {
+	s.paused.Store(false)
+	s.logger.Info("Schedule resumed")
+}
+
+func (s Scheduler) Paused() bool {
+	return s.paused.Load()
+}
+
+func (s Scheduler) NextRun() time.Time {
+	return s.cronSchedule.Entry(s.entryID).Next
+}
+
+func (s Scheduler) reportNextRun() {
+	next := s.NextRun()
+	for _, fn := range s.nextRunFuncs {
+		fn(next)
+	}
 }
 
 func (s Scheduler) Run() {
 	runner := ifrit.RunFunc(func(signals <-chan os.Signal, ready chan<- struct{}) error {
 		s.cronSchedule.Start()
+		s.reportNextRun()
+		if s.maxBackupAge > 0 {
+			go s.watchLastSuccess()
+		}
+		if s.digestAt >= 0 {
+			go s.sendDigests()
+		}
+		if s.catchUp != nil {
+			go s.catchUpMissedRun(time.Now())
+		}
 		close(ready)
 
 		// ifrit does not call Notify on this channel
@@ -75,4 +613,5 @@ func (s Scheduler) Run() {
 
 func (s Scheduler) Stop() {
 	s.cronSchedule.Stop()
+	s.stopOnce.Do(func() { close(s.stop) })
 }
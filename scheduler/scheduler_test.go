@@ -0,0 +1,829 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"code.cloudfoundry.org/lager/v3"
+	"github.com/pivotal-cf/service-backup/config"
+	"github.com/pivotal-cf/service-backup/executor"
+	"github.com/pivotal-cf/service-backup/lease"
+	"github.com/pivotal-cf/service-backup/notify"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+type fakeExecutor struct {
+	reports      []executor.RunReport
+	runs         int
+	destinations [][]string
+}
+
+func (e *fakeExecutor) Execute() error {
+	return e.Run().Err
+}
+
+func (e *fakeExecutor) Run() executor.RunReport {
+	report := e.reports[e.runs]
+	e.runs++
+	return report
+}
+
+func (e *fakeExecutor) RunDestinations(destinations []string) executor.RunReport {
+	e.destinations = append(e.destinations, destinations)
+	return e.Run()
+}
+
+func (e *fakeExecutor) Cancel() bool {
+	return false
+}
+
+// startingExecutor claims runs before they are performed, refusing with
+// startErr if it is set.
+type startingExecutor struct {
+	*fakeExecutor
+	startErr error
+	finished chan struct{}
+}
+
+func (e *startingExecutor) Start() (func() executor.RunReport, error) {
+	if e.startErr != nil {
+		return nil, e.startErr
+	}
+	return func() executor.RunReport {
+		defer close(e.finished)
+		return e.Run()
+	}, nil
+}
+
+type failingLeaseBackend struct {
+	err error
+}
+
+func (b failingLeaseBackend) Acquire(context.Context, lease.Lease) (lease.Lease, error) {
+	return lease.Lease{}, b.err
+}
+
+type fakeChannel struct {
+	alerted   []notify.Event
+	resolved  []notify.Event
+	notifyErr error
+}
+
+func (c *fakeChannel) Name() string {
+	return "fake"
+}
+
+func (c *fakeChannel) Notify(event notify.Event) error {
+	c.alerted = append(c.alerted, event)
+	return c.notifyErr
+}
+
+func (c *fakeChannel) Resolve(event notify.Event) error {
+	c.resolved = append(c.resolved, event)
+	return nil
+}
+
+func eventTypes(events []notify.Event) []notify.EventType {
+	var types []notify.EventType
+	for _, e := range events {
+		types = append(types, e.Type)
+	}
+	return types
+}
+
+var _ = Describe("Scheduler", func() {
+	var (
+		backupExecutor *fakeExecutor
+		channel        *fakeChannel
+		logger         lager.Logger
+		log            *gbytes.Buffer
+		options        []Option
+
+		failed    = executor.RunReport{ServiceInstanceID: "instance", FailedPhase: executor.PhaseBackup, Err: errors.New("boom")}
+		succeeded = executor.RunReport{ServiceInstanceID: "instance", FinishedAt: time.Now()}
+	)
+
+	BeforeEach(func() {
+		backupExecutor = new(fakeExecutor)
+		channel = new(fakeChannel)
+		log = gbytes.NewBuffer()
+		logger = lager.NewLogger("scheduler-test")
+		logger.RegisterSink(lager.NewWriterSink(log, lager.DEBUG))
+		options = []Option{WithAlertChannel(channel)}
+	})
+
+	newScheduler := func() Scheduler {
+		return NewScheduler(backupExecutor, config.BackupConfig{CronSchedule: "@monthly"}, nil, logger, options...)
+	}
+
+	Describe("RunNow", func() {
+		It("alerts every channel when the run fails", func() {
+			backupExecutor.reports = []executor.RunReport{failed}
+
+			newScheduler().RunNow()
+
+			Expect(channel.alerted).To(HaveLen(1))
+			Expect(channel.alerted[0].Type).To(Equal(notify.EventRunFailed))
+			Expect(channel.alerted[0].Report).To(Equal(failed))
+			Expect(log).To(gbytes.Say("Sending alert."))
+			Expect(log).To(gbytes.Say("Sent alert."))
+		})
+
+		It("logs alerts that could not be sent", func() {
+			backupExecutor.reports = []executor.RunReport{failed}
+			channel.notifyErr = errors.New("unreachable")
+
+			newScheduler().RunNow()
+
+			Expect(log).To(gbytes.Say("error sending service alert"))
+		})
+
+		It("logs that alerts are not configured when there are no channels", func() {
+			options = nil
+			backupExecutor.reports = []executor.RunReport{failed}
+
+			newScheduler().RunNow()
+
+			Expect(log).To(gbytes.Say("Alerts not configured."))
+		})
+
+		It("resolves alerts when the run succeeds", func() {
+			backupExecutor.reports = []executor.RunReport{succeeded}
+
+			newScheduler().RunNow()
+
+			Expect(channel.alerted).To(BeEmpty())
+			Expect(channel.resolved).To(HaveLen(2))
+			Expect(channel.resolved[0].Type).To(Equal(notify.EventRunSucceeded))
+			Expect(channel.resolved[1].Type).To(Equal(notify.EventCleanupFailed))
+		})
+
+		It("alerts when the cleanup of a successful run fails", func() {
+			cleanupFailed := succeeded
+			cleanupFailed.CleanupErr = errors.New("rm failed")
+			backupExecutor.reports = []executor.RunReport{cleanupFailed}
+
+			newScheduler().RunNow()
+
+			Expect(channel.alerted).To(HaveLen(1))
+			Expect(channel.alerted[0].Type).To(Equal(notify.EventCleanupFailed))
+			Expect(channel.alerted[0].FailureClass()).To(Equal(notify.FailureClassCleanup))
+			Expect(channel.resolved).To(HaveLen(1))
+			Expect(channel.resolved[0].Type).To(Equal(notify.EventRunSucceeded))
+		})
+
+		It("does nothing when the run was skipped", func() {
+			backupExecutor.reports = []executor.RunReport{{Skipped: true}}
+
+			newScheduler().RunNow()
+
+			Expect(channel.alerted).To(BeEmpty())
+			Expect(channel.resolved).To(BeEmpty())
+		})
+	})
+
+	Describe("Trigger", func() {
+		var starting *startingExecutor
+
+		BeforeEach(func() {
+			starting = &startingExecutor{fakeExecutor: backupExecutor, finished: make(chan struct{})}
+		})
+
+		newTriggerScheduler := func() Scheduler {
+			return NewScheduler(starting, config.BackupConfig{CronSchedule: "@monthly"}, nil, logger, options...)
+		}
+
+		It("runs the backup in the background and alerts as RunNow does", func() {
+			backupExecutor.reports = []executor.RunReport{failed}
+
+			Expect(newTriggerScheduler().Trigger()).To(Succeed())
+
+			Eventually(starting.finished).Should(BeClosed())
+			Eventually(log).Should(gbytes.Say("Sent alert."))
+			Expect(eventTypes(channel.alerted)).To(Equal([]notify.EventType{notify.EventRunFailed}))
+		})
+
+		It("returns the executor's refusal without running a backup", func() {
+			starting.startErr = executor.ErrBackupInProgress
+
+			Expect(newTriggerScheduler().Trigger()).To(MatchError(executor.ErrBackupInProgress))
+			Expect(backupExecutor.runs).To(BeZero())
+		})
+	})
+
+	Describe("the maximum backup age", func() {
+		BeforeEach(func() {
+			options = append(options, WithMaxBackupAge(time.Hour, 0))
+		})
+
+		It("checks once a minute by default", func() {
+			Expect(newScheduler().overdueCheckInterval).To(Equal(time.Minute))
+		})
+
+		It("alerts once when no backup has succeeded within the maximum age", func() {
+			s := newScheduler()
+
+			s.checkLastSuccess(time.Now().Add(30 * time.Minute))
+			Expect(channel.alerted).To(BeEmpty())
+
+			s.checkLastSuccess(time.Now().Add(61 * time.Minute))
+			s.checkLastSuccess(time.Now().Add(62 * time.Minute))
+			Expect(channel.alerted).To(HaveLen(1))
+			Expect(channel.alerted[0].Type).To(Equal(notify.EventBackupOverdue))
+			Expect(channel.alerted[0].Report.Err).To(MatchError(ContainSubstring("no backup has succeeded since")))
+			Expect(log).To(gbytes.Say("No backup has succeeded within the maximum backup age"))
+		})
+
+		It("resolves the overdue alert and starts counting again after a success", func() {
+			backupExecutor.reports = []executor.RunReport{succeeded}
+			s := newScheduler()
+			s.checkLastSuccess(time.Now().Add(2 * time.Hour))
+
+			s.RunNow()
+
+			Expect(channel.resolved).To(HaveLen(3))
+			Expect(channel.resolved[0].Type).To(Equal(notify.EventBackupOverdue))
+			Expect(channel.resolved[1].Type).To(Equal(notify.EventRunSucceeded))
+			Expect(channel.resolved[2].Type).To(Equal(notify.EventCleanupFailed))
+
+			s.checkLastSuccess(succeeded.FinishedAt.Add(30 * time.Minute))
+			Expect(channel.alerted).To(HaveLen(1))
+		})
+
+		Context("with a last success state file", func() {
+			var statePath string
+
+			BeforeEach(func() {
+				statePath = filepath.Join(GinkgoT().TempDir(), "last_success.json")
+				options = append(options, WithLastSuccessState(statePath))
+			})
+
+			It("counts the age from the last success before a restart", func() {
+				backupExecutor.reports = []executor.RunReport{succeeded}
+				newScheduler().RunNow()
+
+				restarted := newScheduler()
+				restarted.checkLastSuccess(succeeded.FinishedAt.Add(30 * time.Minute))
+				Expect(channel.alerted).To(BeEmpty())
+				restarted.checkLastSuccess(succeeded.FinishedAt.Add(61 * time.Minute))
+				Expect(channel.alerted).To(HaveLen(1))
+				Expect(channel.alerted[0].Type).To(Equal(notify.EventBackupOverdue))
+			})
+
+			It("alerts a daemon restarting more often than the maximum age", func() {
+				firstStart := time.Now()
+				newScheduler()
+
+				for i := 0; i < 3; i++ {
+					newScheduler().checkLastSuccess(firstStart.Add(time.Duration(i+1) * 30 * time.Minute))
+				}
+
+				Expect(channel.alerted).To(HaveLen(1))
+			})
+
+			It("logs a state file it cannot read and counts from the start", func() {
+				Expect(os.WriteFile(statePath, []byte("{"), 0644)).To(Succeed())
+
+				s := newScheduler()
+
+				Expect(log).To(gbytes.Say("Error restoring last successful backup"))
+				s.checkLastSuccess(time.Now().Add(30 * time.Minute))
+				Expect(channel.alerted).To(BeEmpty())
+			})
+		})
+
+		It("does not count skipped runs as successes", func() {
+			backupExecutor.reports = []executor.RunReport{{Skipped: true, FinishedAt: time.Now().Add(2 * time.Hour)}}
+			s := newScheduler()
+
+			s.RunNow()
+			s.checkLastSuccess(time.Now().Add(2 * time.Hour))
+
+			Expect(channel.alerted).To(HaveLen(1))
+		})
+	})
+
+	Describe("alert rate limiting", func() {
+		var timedOut = executor.RunReport{ServiceInstanceID: "instance", FailedPhase: executor.PhaseStart, Err: errors.New("in progress")}
+
+		It("alerts every failure when there is no renotify interval", func() {
+			backupExecutor.reports = []executor.RunReport{failed, failed}
+			s := newScheduler()
+
+			s.RunNow()
+			s.RunNow()
+
+			Expect(channel.alerted).To(HaveLen(2))
+		})
+
+		Context("with a renotify interval", func() {
+			BeforeEach(func() {
+				options = append(options, WithRenotifyInterval(time.Hour))
+			})
+
+			It("does not alert a repeated failure within the interval", func() {
+				backupExecutor.reports = []executor.RunReport{failed, failed, failed}
+				s := newScheduler()
+
+				s.RunNow()
+				s.RunNow()
+				s.RunNow()
+
+				Expect(channel.alerted).To(HaveLen(1))
+				Expect(log).To(gbytes.Say("Not alerting repeated failure"))
+			})
+
+			It("alerts a failure of a different class", func() {
+				backupExecutor.reports = []executor.RunReport{failed, timedOut}
+				s := newScheduler()
+
+				s.RunNow()
+				s.RunNow()
+
+				Expect(channel.alerted).To(HaveLen(2))
+			})
+
+			It("sends a recovery notice after failures", func() {
+				backupExecutor.reports = []executor.RunReport{failed, failed, succeeded, succeeded}
+				s := newScheduler()
+
+				s.RunNow()
+				s.RunNow()
+				s.RunNow()
+				s.RunNow()
+
+				Expect(channel.alerted).To(HaveLen(2))
+				Expect(channel.alerted[1].Type).To(Equal(notify.EventRunRecovered))
+				Expect(channel.alerted[1].Summary()).To(HavePrefix("Backups of instance are succeeding again after 2 failed runs since "))
+			})
+
+			It("keeps counting repeated cleanup failures across successful runs", func() {
+				cleanupFailed := succeeded
+				cleanupFailed.CleanupErr = errors.New("rm failed")
+				backupExecutor.reports = []executor.RunReport{cleanupFailed, cleanupFailed, succeeded, cleanupFailed}
+				s := newScheduler()
+
+				s.RunNow()
+				s.RunNow()
+				Expect(channel.alerted).To(HaveLen(1))
+
+				s.RunNow()
+				s.RunNow()
+				Expect(channel.alerted).To(HaveLen(2))
+				Expect(eventTypes(channel.alerted)).To(Equal([]notify.EventType{notify.EventCleanupFailed, notify.EventCleanupFailed}))
+			})
+
+			It("sends a digest of the failures it did not alert", func() {
+				backupExecutor.reports = []executor.RunReport{failed, failed, failed}
+				s := newScheduler()
+				s.RunNow()
+				s.RunNow()
+				s.RunNow()
+
+				s.sendDigest()
+				Expect(channel.alerted).To(HaveLen(2))
+				Expect(channel.alerted[1].Type).To(Equal(notify.EventAlertDigest))
+				Expect(channel.alerted[1].Summary()).To(ContainSubstring("instance (backup): 2 failures not alerted"))
+				Expect(channel.alerted[1].Summary()).To(ContainSubstring("last error: boom"))
+
+				s.sendDigest()
+				Expect(channel.alerted).To(HaveLen(2))
+			})
+		})
+
+		It("alerts a repeated failure again once the renotify interval has passed", func() {
+			limiter := newAlertLimiter()
+			limiter.renotifyInterval = time.Hour
+			key := alertKey{serviceInstanceID: "instance", failureClass: "backup"}
+			now := time.Now()
+
+			Expect(limiter.failed(key, now, failed.Err)).To(BeTrue())
+			Expect(limiter.failed(key, now.Add(59*time.Minute), failed.Err)).To(BeFalse())
+			Expect(limiter.failed(key, now.Add(61*time.Minute), failed.Err)).To(BeTrue())
+		})
+
+		It("schedules the digest for the next occurrence of its time of day in UTC", func() {
+			now := time.Date(2024, 3, 10, 10, 0, 0, 0, time.UTC)
+
+			Expect(nextDigest(now, 9*time.Hour)).To(Equal(time.Date(2024, 3, 11, 9, 0, 0, 0, time.UTC)))
+			Expect(nextDigest(now, 11*time.Hour)).To(Equal(time.Date(2024, 3, 10, 11, 0, 0, 0, time.UTC)))
+		})
+	})
+
+	Describe("splay", func() {
+		It("gives each instance a stable offset below the maximum", func() {
+			offset := instanceOffset("redis/instance-a", 10*time.Minute)
+
+			Expect(offset).To(BeNumerically("<", 10*time.Minute))
+			Expect(instanceOffset("redis/instance-a", 10*time.Minute)).To(Equal(offset))
+			Expect(instanceOffset("redis/instance-b", 10*time.Minute)).NotTo(Equal(offset))
+			Expect(instanceOffset("redis/instance-a", 0)).To(BeZero())
+		})
+
+		It("adds a random delay to the offset", func() {
+			p := newSplay(time.Minute, time.Hour, "redis/instance-a")
+			p.random = func(n int64) int64 {
+				Expect(n).To(Equal(int64(time.Minute)))
+				return int64(5 * time.Second)
+			}
+
+			Expect(p.delay()).To(Equal(p.offset + 5*time.Second))
+		})
+
+		It("logs the delay before a scheduled run and its actual start", func() {
+			var scheduled, started time.Time
+			options = append(options,
+				WithSplay(0, time.Millisecond, "redis/instance-a"),
+				WithStartFunc(func(sched, start time.Time) { scheduled, started = sched, start }),
+			)
+			s := newScheduler()
+			s.splay.offset = 20 * time.Millisecond
+			scheduledAt := time.Now()
+
+			Expect(s.waitForSplay(scheduledAt)).To(BeTrue())
+			s.reportStart(scheduledAt, time.Now())
+
+			Expect(log).To(gbytes.Say("Delaying scheduled backup"))
+			Expect(log).To(gbytes.Say("Starting scheduled backup"))
+			Expect(scheduled).To(Equal(scheduledAt))
+			Expect(started.Sub(scheduled)).To(BeNumerically(">=", 20*time.Millisecond))
+		})
+
+		It("abandons the run when the scheduler is stopped during the delay", func() {
+			options = append(options, WithSplay(time.Hour, 0, ""))
+			s := newScheduler()
+			s.Stop()
+
+			Expect(s.waitForSplay(time.Now())).To(BeFalse())
+		})
+	})
+
+	Describe("catching up missed runs", func() {
+		var (
+			statePath string
+			policy    CatchUpPolicy
+			delay     time.Duration
+			now       time.Time
+		)
+
+		BeforeEach(func() {
+			statePath = filepath.Join(GinkgoT().TempDir(), "state.json")
+			policy = CatchUpImmediate
+			delay = 0
+			now = time.Now()
+			backupExecutor.reports = []executor.RunReport{succeeded}
+		})
+
+		JustBeforeEach(func() {
+			options = append(options, WithCatchUp(statePath, policy, delay, time.Hour))
+		})
+
+		writeState := func(state runState) {
+			contents, err := json.Marshal(state)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(os.WriteFile(statePath, contents, 0644)).To(Succeed())
+		}
+
+		readState := func() runState {
+			var state runState
+			contents, err := os.ReadFile(statePath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(json.Unmarshal(contents, &state)).To(Succeed())
+			return state
+		}
+
+		It("does nothing when no scheduled run has been recorded", func() {
+			newScheduler().catchUpMissedRun(now)
+
+			Expect(backupExecutor.runs).To(BeZero())
+		})
+
+		It("does nothing when no run has been missed", func() {
+			writeState(runState{LastScheduledRun: now.Add(-time.Hour)})
+
+			newScheduler().catchUpMissedRun(now)
+
+			Expect(backupExecutor.runs).To(BeZero())
+		})
+
+		It("runs a missed backup once and records it", func() {
+			writeState(runState{LastScheduledRun: now.AddDate(0, -2, 0)})
+
+			newScheduler().catchUpMissedRun(now)
+
+			Expect(backupExecutor.runs).To(Equal(1))
+			Expect(log).To(gbytes.Say("Catching up missed scheduled backup"))
+			state := readState()
+			Expect(state.LastCatchUp).To(BeTemporally("==", now))
+			Expect(state.LastScheduledRun).To(BeTemporally(">=", now))
+		})
+
+		It("records scheduled runs", func() {
+			newScheduler().recordScheduledRun(now)
+
+			Expect(readState().LastScheduledRun).To(BeTemporally("==", now))
+		})
+
+		It("does not catch up a run skipped in a blackout window after a restart", func() {
+			writeState(runState{LastScheduledRun: now.AddDate(0, -2, 0)})
+			options = append(options, WithBlackoutWindows(BlackoutWindow{
+				Name:   "freeze",
+				Action: BlackoutSkip,
+				from:   now.Add(-time.Hour),
+				to:     now.Add(time.Hour),
+			}))
+			newScheduler().scheduledRun(now)
+			Expect(log).To(gbytes.Say("Skipping scheduled backup during blackout window"))
+
+			options = options[:len(options)-1]
+			newScheduler().catchUpMissedRun(now.Add(time.Minute))
+
+			Expect(backupExecutor.runs).To(BeZero())
+			Expect(readState().LastScheduledRun).To(BeTemporally("==", now))
+		})
+
+		It("does not catch up a run skipped while paused after a restart", func() {
+			writeState(runState{LastScheduledRun: now.AddDate(0, -2, 0)})
+			s := newScheduler()
+			s.Pause()
+			s.scheduledRun(now)
+
+			newScheduler().catchUpMissedRun(now.Add(time.Minute))
+
+			Expect(backupExecutor.runs).To(BeZero())
+		})
+
+		Context("with a maximum backup age", func() {
+			JustBeforeEach(func() {
+				options = append(options, WithMaxBackupAge(time.Hour, 0))
+			})
+
+			It("counts the age from the last success recorded before a restart", func() {
+				writeState(runState{LastSuccess: now.Add(-50 * time.Minute)})
+
+				newScheduler().checkLastSuccess(now.Add(11 * time.Minute))
+
+				Expect(channel.alerted).To(HaveLen(1))
+				Expect(channel.alerted[0].Type).To(Equal(notify.EventBackupOverdue))
+			})
+
+			It("counts the age from the first start when restarted before any success", func() {
+				newScheduler()
+				restarted := newScheduler()
+
+				Expect(readState().LastSuccess).To(BeTemporally("~", now, time.Second))
+				restarted.checkLastSuccess(readState().LastSuccess.Add(61 * time.Minute))
+				Expect(channel.alerted).To(HaveLen(1))
+			})
+
+			It("records each success", func() {
+				writeState(runState{LastSuccess: now.Add(-50 * time.Minute)})
+
+				newScheduler().RunNow()
+
+				Expect(readState().LastSuccess).To(BeTemporally("==", succeeded.FinishedAt))
+				newScheduler().checkLastSuccess(succeeded.FinishedAt.Add(30 * time.Minute))
+				Expect(channel.alerted).To(BeEmpty())
+			})
+		})
+
+		It("does not catch up again soon after the last catch-up, in case it crashed", func() {
+			writeState(runState{LastScheduledRun: now.AddDate(0, -2, 0), LastCatchUp: now.Add(-10 * time.Minute)})
+
+			newScheduler().catchUpMissedRun(now)
+
+			Expect(backupExecutor.runs).To(BeZero())
+			Expect(log).To(gbytes.Say("Not catching up missed scheduled backup, the last catch-up was too recent"))
+		})
+
+		It("logs an unreadable state file and does not catch up", func() {
+			Expect(os.WriteFile(statePath, []byte("{"), 0644)).To(Succeed())
+
+			newScheduler().catchUpMissedRun(now)
+
+			Expect(backupExecutor.runs).To(BeZero())
+			Expect(log).To(gbytes.Say("Error reading scheduled run state"))
+		})
+
+		Context("when the policy is to skip", func() {
+			BeforeEach(func() {
+				policy = CatchUpSkip
+			})
+
+			It("logs the missed run without running it", func() {
+				writeState(runState{LastScheduledRun: now.AddDate(0, -2, 0)})
+
+				newScheduler().catchUpMissedRun(now)
+
+				Expect(backupExecutor.runs).To(BeZero())
+				Expect(log).To(gbytes.Say("Skipping missed scheduled backup"))
+			})
+		})
+
+		Context("when the policy is to delay", func() {
+			BeforeEach(func() {
+				policy = CatchUpDelayed
+				delay = 20 * time.Millisecond
+			})
+
+			It("runs the missed backup after the delay", func() {
+				writeState(runState{LastScheduledRun: now.AddDate(0, -2, 0)})
+
+				newScheduler().catchUpMissedRun(now)
+
+				Expect(backupExecutor.runs).To(Equal(1))
+				Expect(log).To(gbytes.Say("Delaying catch-up of missed scheduled backup"))
+			})
+
+			It("does not run the missed backup when stopped during the delay", func() {
+				writeState(runState{LastScheduledRun: now.AddDate(0, -2, 0)})
+				s := newScheduler()
+				s.Stop()
+
+				s.catchUpMissedRun(now)
+
+				Expect(backupExecutor.runs).To(BeZero())
+			})
+		})
+	})
+
+	It("parses catch-up policies", func() {
+		Expect(ParseCatchUpPolicy("")).To(Equal(CatchUpImmediate))
+		Expect(ParseCatchUpPolicy("delayed")).To(Equal(CatchUpDelayed))
+		_, err := ParseCatchUpPolicy("later")
+		Expect(err).To(MatchError("unknown catch-up policy: later"))
+	})
+
+	Describe("leader election", func() {
+		var (
+			backend     lease.Backend
+			scheduledAt time.Time
+		)
+
+		BeforeEach(func() {
+			backend = lease.NewFileBackend(filepath.Join(GinkgoT().TempDir(), "leader.json"))
+			scheduledAt = time.Now()
+		})
+
+		schedulerFor := func(holder string) Scheduler {
+			return NewScheduler(backupExecutor, config.BackupConfig{CronSchedule: "@monthly"}, nil, logger,
+				append(options, WithLeaderElection(backend, holder, time.Hour))...)
+		}
+
+		It("runs every backup when leader election is not configured", func() {
+			Expect(newScheduler().elected(scheduledAt)).To(BeTrue())
+		})
+
+		It("holds leases for an hour by default", func() {
+			options = append(options, WithLeaderElection(backend, "node-a", 0))
+			Expect(newScheduler().leader.ttl).To(Equal(lease.DefaultTTL))
+		})
+
+		It("lets only the node holding the lease back up, logging the holder and expiry", func() {
+			Expect(schedulerFor("node-a").elected(scheduledAt)).To(BeTrue())
+			Expect(log).To(gbytes.Say(`Holding the backup lease, backing up as leader","log_level":1,"data":{"holder":"node-a","lease_expires_at":"[^"]+"`))
+
+			Expect(schedulerFor("node-b").elected(scheduledAt)).To(BeFalse())
+			Expect(log).To(gbytes.Say(`Another node holds the backup lease, skipping scheduled backup","log_level":1,"data":{"holder":"node-a","lease_expires_at":"[^"]+"`))
+
+			Expect(schedulerFor("node-a").elected(scheduledAt.Add(time.Minute))).To(BeTrue())
+		})
+
+		It("does not alert on followers that backups are overdue", func() {
+			options = append(options, WithMaxBackupAge(time.Hour, 0))
+			Expect(schedulerFor("node-a").elected(scheduledAt)).To(BeTrue())
+
+			follower := schedulerFor("node-b")
+			follower.lastSuccess.at = time.Now().Add(-2 * time.Hour)
+			Expect(follower.elected(scheduledAt)).To(BeFalse())
+			follower.checkLastSuccess(time.Now())
+
+			Expect(channel.alerted).To(BeEmpty())
+		})
+
+		It("backs up anyway when the lease cannot be taken", func() {
+			backend = failingLeaseBackend{err: errors.New("bucket unreachable")}
+
+			Expect(schedulerFor("node-a").elected(scheduledAt)).To(BeTrue())
+			Expect(log).To(gbytes.Say("Error taking the backup lease, backing up anyway"))
+		})
+
+		It("does not catch up a missed run on followers", func() {
+			statePath := filepath.Join(GinkgoT().TempDir(), "state.json")
+			contents, err := json.Marshal(runState{LastScheduledRun: scheduledAt.AddDate(0, -2, 0)})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(os.WriteFile(statePath, contents, 0644)).To(Succeed())
+			options = append(options, WithCatchUp(statePath, CatchUpImmediate, 0, time.Hour))
+			Expect(schedulerFor("node-a").elected(scheduledAt)).To(BeTrue())
+
+			schedulerFor("node-b").catchUpMissedRun(scheduledAt)
+
+			Expect(backupExecutor.runs).To(BeZero())
+			Expect(log).To(gbytes.Say("Another node holds the backup lease"))
+		})
+
+		It("does not catch up a run another node was elected for after a restart", func() {
+			statePath := filepath.Join(GinkgoT().TempDir(), "state.json")
+			contents, err := json.Marshal(runState{LastScheduledRun: scheduledAt.AddDate(0, -2, 0)})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(os.WriteFile(statePath, contents, 0644)).To(Succeed())
+			options = append(options, WithCatchUp(statePath, CatchUpImmediate, 0, time.Hour))
+			Expect(schedulerFor("node-a").elected(scheduledAt)).To(BeTrue())
+
+			schedulerFor("node-b").scheduledRun(scheduledAt)
+			Expect(log).To(gbytes.Say("Another node holds the backup lease"))
+			schedulerFor("node-b").catchUpMissedRun(scheduledAt.Add(time.Minute))
+
+			Expect(backupExecutor.runs).To(BeZero())
+			Expect(log).NotTo(gbytes.Say("Another node holds the backup lease"))
+		})
+	})
+
+	Describe("per-destination schedules", func() {
+		var (
+			destinations []config.Destination
+			global       = "0 0 2 * * *"
+			monday       = time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC)
+		)
+
+		BeforeEach(func() {
+			destinations = []config.Destination{
+				{Name: "scp", CronSchedule: "0 0 * * * *"},
+				{Name: "s3"},
+			}
+		})
+
+		parse := func() (*destinationSchedules, error) {
+			schedule, err := ParseSchedule(global, "UTC")
+			Expect(err).NotTo(HaveOccurred())
+			return newDestinationSchedules(destinations, schedule, "UTC")
+		}
+
+		It("is not used when no destination has a schedule of its own", func() {
+			destinations = []config.Destination{{Name: "scp"}, {Name: "s3"}}
+
+			Expect(parse()).To(BeNil())
+		})
+
+		It("requires destinations to have unique names", func() {
+			destinations = append(destinations, config.Destination{Name: "s3"})
+
+			_, err := parse()
+			Expect(err).To(MatchError("destinations must have unique names when any has its own cron_schedule"))
+		})
+
+		It("rejects invalid schedules", func() {
+			destinations[0].CronSchedule = "hourly"
+
+			_, err := parse()
+			Expect(err).To(MatchError(ContainSubstring("invalid cron_schedule for destination scp")))
+		})
+
+		It("triggers whenever any destination is due", func() {
+			schedules, err := parse()
+			Expect(err).NotTo(HaveOccurred())
+
+			union := unionSchedule(schedules.schedules())
+			Expect(NextRuns(union, monday.Add(90*time.Minute), 2)).To(Equal([]time.Time{
+				monday.Add(2 * time.Hour),
+				monday.Add(3 * time.Hour),
+			}))
+		})
+
+		It("finds the destinations due on each trigger, once", func() {
+			schedules, err := parse()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(schedules.due(monday.Add(time.Hour + 300*time.Millisecond))).To(Equal([]string{"scp"}))
+			Expect(schedules.due(monday.Add(2*time.Hour + 200*time.Millisecond))).To(Equal([]string{"scp", "s3"}))
+			Expect(schedules.due(monday.Add(2*time.Hour + 30*time.Second))).To(BeEmpty())
+			Expect(schedules.due(monday.Add(3*time.Hour+5*time.Minute))).To(BeEmpty(), "too late to count as due")
+		})
+
+		It("backs up once and uploads only to the destinations due", func() {
+			backupExecutor.reports = []executor.RunReport{succeeded, succeeded}
+			s := NewScheduler(backupExecutor, config.BackupConfig{CronSchedule: global, Destinations: destinations}, nil, logger, options...)
+
+			s.runScheduled(time.Date(2026, time.October, 19, 1, 0, 0, 0, time.Local))
+			s.runScheduled(time.Date(2026, time.October, 19, 1, 30, 0, 0, time.Local))
+
+			Expect(backupExecutor.runs).To(Equal(1))
+			Expect(backupExecutor.destinations).To(Equal([][]string{{"scp"}}))
+			Expect(log).To(gbytes.Say("Backing up to the destinations due"))
+			Expect(log).To(gbytes.Say("No destination due, skipping scheduled backup"))
+		})
+	})
+})
@@ -0,0 +1,48 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package scheduler
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"time"
+)
+
+// splay delays each scheduled run so that many instances sharing a schedule
+// do not all start at once. The offset is fixed for an instance, so its runs
+// stay evenly spaced; the random part is drawn afresh for every run.
+type splay struct {
+	offset    time.Duration
+	maxRandom time.Duration
+	random    func(n int64) int64
+}
+
+func newSplay(maxRandom, maxOffset time.Duration, instanceKey string) splay {
+	return splay{
+		offset:    instanceOffset(instanceKey, maxOffset),
+		maxRandom: maxRandom,
+		random:    rand.Int63n,
+	}
+}
+
+// instanceOffset derives an offset below max from a hash of instanceKey.
+func instanceOffset(instanceKey string, max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	hash := fnv.New64a()
+	hash.Write([]byte(instanceKey))
+	return time.Duration(hash.Sum64() % uint64(max))
+}
+
+func (p splay) delay() time.Duration {
+	delay := p.offset
+	if p.maxRandom > 0 {
+		delay += time.Duration(p.random(int64(p.maxRandom)))
+	}
+	return delay
+}
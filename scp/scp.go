@@ -7,14 +7,17 @@
 package scp
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"os/exec"
 	"strconv"
+	"syscall"
 
 	"code.cloudfoundry.org/lager/v3"
 	"github.com/pivotal-cf/service-backup/process"
+	"github.com/pivotal-cf/service-backup/throttle"
 )
 
 type SCPClient struct {
@@ -27,6 +30,7 @@ type SCPClient struct {
 	remotePathFn func() string
 	SCPCommand   string
 	SSHCommand   string
+	Throttle     *throttle.Throttle
 }
 
 func New(name, host string, port int, username, privateKeyPath, fingerPrint string, remotePathFn func() string) *SCPClient {
@@ -79,7 +83,7 @@ func (client *SCPClient) generateKnownHosts(sessionLogger lager.Logger) (string,
 	return knownHostsFile.Name(), nil
 }
 
-func (client *SCPClient) Upload(localPath string, sessionLogger lager.Logger, processManager process.ProcessManager) error {
+func (client *SCPClient) Upload(ctx context.Context, localPath string, sessionLogger lager.Logger, processManager process.ProcessManager) error {
 	privateKeyFileName, err := client.generateBackupKey()
 	if err != nil {
 		return err
@@ -104,8 +108,16 @@ func (client *SCPClient) Upload(localPath string, sessionLogger lager.Logger, pr
 		return err
 	}
 
+	client.Throttle.Log(sessionLogger)
+
 	for _, f := range files {
-		cmd := exec.Command(client.SCPCommand, "-oStrictHostKeyChecking=yes", "-i", privateKeyFileName, "-oUserKnownHostsFile="+knownHostsFileName, "-P", strconv.Itoa(client.port), "-r", f.Name(), scpDest)
+		args := []string{"-oStrictHostKeyChecking=yes", "-i", privateKeyFileName, "-oUserKnownHostsFile=" + knownHostsFileName, "-P", strconv.Itoa(client.port)}
+		if limit := client.bandwidthLimitKbits(); limit > 0 {
+			args = append(args, "-l", strconv.FormatInt(limit, 10))
+		}
+		args = append(args, "-r", f.Name(), scpDest)
+		cmd := exec.CommandContext(ctx, client.SCPCommand, args...)
+		cmd.Cancel = func() error { return cmd.Process.Signal(syscall.SIGTERM) }
 		cmd.Dir = localPath
 
 		scpCommandOutput, err := processManager.Start(cmd)
@@ -135,6 +147,21 @@ func (client *SCPClient) ensureRemoteDirectoryExists(remotePath, privateKeyFileN
 	return nil
 }
 
+// bandwidthLimitKbits converts the current throttle rate into the Kbit/s
+// expected by scp's -l flag. scp cannot share a limit with other
+// destinations, so it is evaluated once per file.
+func (client *SCPClient) bandwidthLimitKbits() int64 {
+	bytesPerSecond := client.Throttle.Rate()
+	if bytesPerSecond == 0 {
+		return 0
+	}
+	kbits := bytesPerSecond * 8 / 1000
+	if kbits == 0 {
+		kbits = 1
+	}
+	return kbits
+}
+
 func (c *SCPClient) Name() string {
 	return c.name
 }
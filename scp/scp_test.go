@@ -1,6 +1,7 @@
 package scp_test
 
 import (
+	"context"
 	"os"
 	"time"
 
@@ -34,7 +35,7 @@ var _ = Describe("scp", func() {
 		go func() {
 			defer GinkgoRecover()
 
-			err := scpClient.Upload("/tmp", lager.NewLogger("foo"), processManager)
+			err := scpClient.Upload(context.Background(), "/tmp", lager.NewLogger("foo"), processManager)
 			Expect(err).To(MatchError(ContainSubstring("SIGTERM propagated to child process")))
 		}()
 
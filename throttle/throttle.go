@@ -0,0 +1,168 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package throttle
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/lager/v3"
+	"golang.org/x/time/rate"
+)
+
+// maxChunk bounds the size of a single throttled read so that the limiter
+// never has to grant more than its burst in one go.
+const maxChunk = 32 * 1024
+
+// Window overrides the default rate between two times of day. Start and End
+// are offsets from midnight; a window whose End is before its Start wraps
+// around midnight.
+type Window struct {
+	Start          time.Duration
+	End            time.Duration
+	BytesPerSecond int64
+}
+
+func (w Window) contains(offset time.Duration) bool {
+	if w.Start <= w.End {
+		return offset >= w.Start && offset < w.End
+	}
+	return offset >= w.Start || offset < w.End
+}
+
+// Schedule is a bandwidth limit in bytes per second, optionally varying by
+// time of day in Location, or in the local timezone if Location is nil. A
+// rate of 0 means unlimited.
+type Schedule struct {
+	BytesPerSecond int64
+	Windows        []Window
+	Location       *time.Location
+}
+
+func (s Schedule) RateAt(t time.Time) int64 {
+	if s.Location != nil {
+		t = t.In(s.Location)
+	}
+	offset := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+	for _, w := range s.Windows {
+		if w.contains(offset) {
+			return w.BytesPerSecond
+		}
+	}
+	return s.BytesPerSecond
+}
+
+// ParseTimeOfDay parses a "HH:MM" string into an offset from midnight.
+func ParseTimeOfDay(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, expected HH:MM", value)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Throttle limits the rate at which readers it wraps can be consumed. A
+// Throttle with a parent is additionally bound by the parent's limit, which
+// lets a global limit be shared between destinations that each have their
+// own. A nil *Throttle does not limit anything.
+type Throttle struct {
+	schedule Schedule
+	parent   *Throttle
+	limiter  *rate.Limiter
+	lock     sync.Mutex
+	now      func() time.Time
+}
+
+func New(schedule Schedule, parent *Throttle) *Throttle {
+	return &Throttle{
+		schedule: schedule,
+		parent:   parent,
+		limiter:  rate.NewLimiter(rate.Inf, maxChunk),
+		now:      time.Now,
+	}
+}
+
+// Rate returns the effective limit in bytes per second, taking parents into
+// account. 0 means unlimited.
+func (t *Throttle) Rate() int64 {
+	if t == nil {
+		return 0
+	}
+	own := t.schedule.RateAt(t.now())
+	inherited := t.parent.Rate()
+	if own == 0 || (inherited != 0 && inherited < own) {
+		return inherited
+	}
+	return own
+}
+
+func (t *Throttle) Log(logger lager.Logger) {
+	if bytesPerSecond := t.Rate(); bytesPerSecond != 0 {
+		logger.Info("Upload bandwidth limited", lager.Data{"bytes_per_second": bytesPerSecond})
+	} else {
+		logger.Info("Upload bandwidth unlimited")
+	}
+}
+
+func (t *Throttle) Reader(r io.Reader, logger lager.Logger) io.Reader {
+	if t == nil {
+		return r
+	}
+	return &reader{reader: r, throttle: t, logger: logger, lastRate: t.Rate()}
+}
+
+func (t *Throttle) wait(n int) error {
+	for th := t; th != nil; th = th.parent {
+		if err := th.waitOwn(n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *Throttle) waitOwn(n int) error {
+	bytesPerSecond := t.schedule.RateAt(t.now())
+
+	t.lock.Lock()
+	if bytesPerSecond == 0 {
+		t.limiter.SetLimit(rate.Inf)
+	} else {
+		t.limiter.SetLimit(rate.Limit(bytesPerSecond))
+	}
+	t.lock.Unlock()
+
+	return t.limiter.WaitN(context.Background(), n)
+}
+
+type reader struct {
+	reader   io.Reader
+	throttle *Throttle
+	logger   lager.Logger
+	lastRate int64
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	if len(p) > maxChunk {
+		p = p[:maxChunk]
+	}
+
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		if waitErr := r.throttle.wait(n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+
+	if current := r.throttle.Rate(); current != r.lastRate {
+		r.lastRate = current
+		r.logger.Info("Upload bandwidth limit changed", lager.Data{"bytes_per_second": current})
+	}
+	return n, err
+}
@@ -0,0 +1,124 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package throttle_test
+
+import (
+	"bytes"
+	"io"
+	"time"
+
+	"code.cloudfoundry.org/lager/v3"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/pivotal-cf/service-backup/throttle"
+)
+
+var _ = Describe("Throttle", func() {
+	Describe("Schedule", func() {
+		var schedule throttle.Schedule
+
+		BeforeEach(func() {
+			schedule = throttle.Schedule{
+				BytesPerSecond: 20 * 1024 * 1024,
+				Windows: []throttle.Window{
+					{Start: 0, End: 6 * time.Hour, BytesPerSecond: 0},
+					{Start: 22 * time.Hour, End: 1 * time.Hour, BytesPerSecond: 1024},
+				},
+			}
+		})
+
+		DescribeTable("returns the rate for the time of day",
+			func(hour, minute int, expected int64) {
+				t := time.Date(2024, 1, 1, hour, minute, 0, 0, time.UTC)
+				Expect(schedule.RateAt(t)).To(Equal(expected))
+			},
+			Entry("inside the first window", 3, 0, int64(0)),
+			Entry("at the start of a window", 0, 0, int64(0)),
+			Entry("at the end of a window", 6, 0, int64(20*1024*1024)),
+			Entry("outside any window", 12, 30, int64(20*1024*1024)),
+			Entry("in a window wrapping midnight", 23, 15, int64(1024)),
+		)
+
+		It("reads the time of day in the schedule's location", func() {
+			newYork, err := time.LoadLocation("America/New_York")
+			Expect(err).NotTo(HaveOccurred())
+			schedule.Location = newYork
+
+			// 08:00 UTC is 03:00 in New York, inside the first window.
+			Expect(schedule.RateAt(time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC))).To(BeZero())
+			// 03:00 UTC is 22:00 the day before in New York.
+			Expect(schedule.RateAt(time.Date(2024, 1, 1, 3, 0, 0, 0, time.UTC))).To(Equal(int64(1024)))
+		})
+	})
+
+	Describe("ParseTimeOfDay", func() {
+		It("parses HH:MM", func() {
+			offset, err := throttle.ParseTimeOfDay("20:30")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(offset).To(Equal(20*time.Hour + 30*time.Minute))
+		})
+
+		It("rejects anything else", func() {
+			_, err := throttle.ParseTimeOfDay("8pm")
+			Expect(err).To(MatchError(`invalid time of day "8pm", expected HH:MM`))
+		})
+	})
+
+	Describe("Rate", func() {
+		It("is unlimited for a nil throttle", func() {
+			var t *throttle.Throttle
+			Expect(t.Rate()).To(BeZero())
+		})
+
+		It("is the lower of its own and its parent's rate", func() {
+			global := throttle.New(throttle.Schedule{BytesPerSecond: 1000}, nil)
+			Expect(throttle.New(throttle.Schedule{BytesPerSecond: 500}, global).Rate()).To(Equal(int64(500)))
+			Expect(throttle.New(throttle.Schedule{BytesPerSecond: 5000}, global).Rate()).To(Equal(int64(1000)))
+			Expect(throttle.New(throttle.Schedule{}, global).Rate()).To(Equal(int64(1000)))
+		})
+	})
+
+	Describe("Reader", func() {
+		var (
+			logger lager.Logger
+			log    *gbytes.Buffer
+		)
+
+		BeforeEach(func() {
+			log = gbytes.NewBuffer()
+			logger = lager.NewLogger("throttle")
+			logger.RegisterSink(lager.NewWriterSink(log, lager.DEBUG))
+		})
+
+		It("passes reads through a nil throttle", func() {
+			var t *throttle.Throttle
+			source := bytes.NewReader([]byte("content"))
+			Expect(t.Reader(source, logger)).To(BeIdenticalTo(source))
+		})
+
+		It("limits the rate at which the content can be read", func() {
+			t := throttle.New(throttle.Schedule{BytesPerSecond: 32 * 1024}, nil)
+			content := bytes.Repeat([]byte("x"), 64*1024)
+
+			start := time.Now()
+			read, err := io.ReadAll(t.Reader(bytes.NewReader(content), logger))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(read).To(Equal(content))
+			Expect(time.Since(start)).To(BeNumerically(">=", 900*time.Millisecond))
+		})
+
+		It("logs the effective rate", func() {
+			t := throttle.New(throttle.Schedule{BytesPerSecond: 1024}, nil)
+			t.Log(logger)
+			Expect(log).To(gbytes.Say(`Upload bandwidth limited.*"bytes_per_second":1024`))
+
+			throttle.New(throttle.Schedule{}, nil).Log(logger)
+			Expect(log).To(gbytes.Say("Upload bandwidth unlimited"))
+		})
+	})
+})
@@ -0,0 +1,116 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package tracing
+
+import (
+	"context"
+	"errors"
+	"os"
+
+	"code.cloudfoundry.org/lager/v3"
+	"github.com/pivotal-cf/service-backup/config"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+const InstrumentationName = "github.com/pivotal-cf/service-backup"
+
+// Span attribute keys shared by the executor, the uploaders and the backends.
+const (
+	BackupGUIDKey        = attribute.Key("backup.guid")
+	ServiceInstanceIDKey = attribute.Key("backup.service_instance_id")
+	SizeInBytesKey       = attribute.Key("backup.size_in_bytes")
+	DestinationKey       = attribute.Key("backup.destination")
+	FilePathKey          = attribute.Key("backup.file.path")
+	FileSizeInBytesKey   = attribute.Key("backup.file.size_in_bytes")
+)
+
+// ShutdownFunc flushes any spans that have not been exported yet and releases
+// the exporters.
+type ShutdownFunc func(context.Context) error
+
+// NewTracerProvider returns a tracer provider that exports spans as configured.
+// With no tracing config, the provider is a no-op and spans cost nothing.
+func NewTracerProvider(conf *config.Tracing, deploymentName string, logger lager.Logger) (trace.TracerProvider, ShutdownFunc, error) {
+	noShutdown := func(context.Context) error { return nil }
+	if conf == nil || (conf.OTLPEndpoint == "" && conf.FilePath == "") {
+		return noop.NewTracerProvider(), noShutdown, nil
+	}
+
+	res := resource.NewSchemaless(
+		attribute.String("service.name", "service-backup"),
+		attribute.String("deployment.name", deploymentName),
+	)
+	options := []sdktrace.TracerProviderOption{sdktrace.WithResource(res)}
+	var closers []func() error
+
+	if conf.OTLPEndpoint != "" {
+		exporter, err := otlptracehttp.New(context.Background(),
+			otlptracehttp.WithEndpointURL(conf.OTLPEndpoint),
+			otlptracehttp.WithHeaders(conf.Headers),
+		)
+		if err != nil {
+			return nil, noShutdown, err
+		}
+		options = append(options, sdktrace.WithBatcher(exporter))
+		logger.Info("exporting traces over OTLP", lager.Data{"endpoint": conf.OTLPEndpoint})
+	}
+
+	if conf.FilePath != "" {
+		file, err := os.OpenFile(conf.FilePath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0640)
+		if err != nil {
+			return nil, noShutdown, err
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(file))
+		if err != nil {
+			file.Close()
+			return nil, noShutdown, err
+		}
+		closers = append(closers, file.Close)
+		options = append(options, sdktrace.WithBatcher(exporter))
+		logger.Info("exporting traces to file", lager.Data{"path": conf.FilePath})
+	}
+
+	provider := sdktrace.NewTracerProvider(options...)
+	shutdown := func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		for _, closer := range closers {
+			err = errors.Join(err, closer())
+		}
+		return err
+	}
+	return provider, shutdown, nil
+}
+
+// Tracer returns the tracer of the span in ctx, so that code further down the
+// call chain creates child spans without being handed a tracer provider.
+func Tracer(ctx context.Context) trace.Tracer {
+	return trace.SpanFromContext(ctx).TracerProvider().Tracer(InstrumentationName)
+}
+
+// StartFileSpan starts a span covering the upload of a single file.
+func StartFileSpan(ctx context.Context, path string, sizeInBytes int64) (context.Context, trace.Span) {
+	return Tracer(ctx).Start(ctx, "upload-file", trace.WithAttributes(
+		FilePathKey.String(path),
+		FileSizeInBytesKey.Int64(sizeInBytes),
+	))
+}
+
+// End records err, if any, on span and ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,83 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package tracing_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"code.cloudfoundry.org/lager/v3"
+	"github.com/pivotal-cf/service-backup/config"
+	"github.com/pivotal-cf/service-backup/tracing"
+	"go.opentelemetry.io/otel/trace"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("NewTracerProvider", func() {
+	var (
+		logger lager.Logger
+		dir    string
+	)
+
+	BeforeEach(func() {
+		logger = lager.NewLogger("tracing-test")
+
+		var err error
+		dir, err = os.MkdirTemp("", "tracing-test")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	It("returns a provider that records nothing when tracing is not configured", func() {
+		provider, shutdown, err := tracing.NewTracerProvider(nil, "deployment", logger)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, span := provider.Tracer(tracing.InstrumentationName).Start(context.Background(), "run")
+		Expect(span.IsRecording()).To(BeFalse())
+		Expect(shutdown(context.Background())).To(Succeed())
+	})
+
+	It("writes spans to the configured file when shut down", func() {
+		path := filepath.Join(dir, "traces.json")
+		provider, shutdown, err := tracing.NewTracerProvider(&config.Tracing{FilePath: path}, "deployment", logger)
+		Expect(err).NotTo(HaveOccurred())
+
+		ctx, span := provider.Tracer(tracing.InstrumentationName).Start(context.Background(), "backup-run")
+		_, fileSpan := tracing.StartFileSpan(ctx, "/var/vcap/store/dump.rdb", 1024)
+		tracing.End(fileSpan, errors.New("upload failed"))
+		span.End()
+		Expect(shutdown(context.Background())).To(Succeed())
+
+		contents, err := os.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(contents)).To(ContainSubstring(`"Name":"backup-run"`))
+		Expect(string(contents)).To(ContainSubstring(`"Name":"upload-file"`))
+		Expect(string(contents)).To(ContainSubstring("/var/vcap/store/dump.rdb"))
+		Expect(string(contents)).To(ContainSubstring("upload failed"))
+		Expect(string(contents)).To(ContainSubstring("deployment"))
+	})
+
+	It("returns an error when the trace file cannot be opened", func() {
+		_, _, err := tracing.NewTracerProvider(&config.Tracing{FilePath: filepath.Join(dir, "missing", "traces.json")}, "deployment", logger)
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("Tracer", func() {
+	It("returns a no-op tracer when there is no span in the context", func() {
+		_, span := tracing.Tracer(context.Background()).Start(context.Background(), "orphan")
+		Expect(span.IsRecording()).To(BeFalse())
+		Expect(span.SpanContext()).To(Equal(trace.SpanContext{}))
+	})
+})
@@ -26,7 +26,7 @@ var _ = Describe("Locator", func() {
 					return nil, nil
 				}
 
-				return nil, &os.PathError{"stat", name, errors.New("file not found")}
+				return nil, &os.PathError{Op: "stat", Path: name, Err: errors.New("file not found")}
 			}
 
 			found, err := CACertPath()
@@ -0,0 +1,58 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package upload
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+)
+
+const (
+	ErrorClassTimeout  = "timeout"
+	ErrorClassNetwork  = "network"
+	ErrorClassAuth     = "auth"
+	ErrorClassNotFound = "not_found"
+	ErrorClassUnknown  = "unknown"
+)
+
+var authErrorMarkers = []string{"AccessDenied", "Forbidden", "InvalidAccessKeyId", "SignatureDoesNotMatch", "AuthenticationFailed", "Permission denied", "403"}
+
+var notFoundErrorMarkers = []string{"NoSuchBucket", "ContainerNotFound", "No such file or directory", "404"}
+
+// ErrorClass buckets an upload error into a coarse class that is stable
+// enough to be used as a metric label or for alert grouping.
+func ErrorClass(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return ErrorClassTimeout
+	}
+	if errors.As(err, &netErr) {
+		return ErrorClassNetwork
+	}
+
+	message := err.Error()
+	for _, marker := range authErrorMarkers {
+		if strings.Contains(message, marker) {
+			return ErrorClassAuth
+		}
+	}
+	for _, marker := range notFoundErrorMarkers {
+		if strings.Contains(message, marker) {
+			return ErrorClassNotFound
+		}
+	}
+	if strings.Contains(message, "connection refused") || strings.Contains(message, "no such host") {
+		return ErrorClassNetwork
+	}
+	return ErrorClassUnknown
+}
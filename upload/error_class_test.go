@@ -0,0 +1,34 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package upload_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-cf/service-backup/upload"
+)
+
+var _ = Describe("ErrorClass", func() {
+	DescribeTable("classifies upload errors",
+		func(err error, expected string) {
+			Expect(upload.ErrorClass(err)).To(Equal(expected))
+		},
+		Entry("no error", nil, ""),
+		Entry("deadline exceeded", fmt.Errorf("upload: %w", context.DeadlineExceeded), upload.ErrorClassTimeout),
+		Entry("network error", &net.OpError{Op: "dial", Err: errors.New("unreachable")}, upload.ErrorClassNetwork),
+		Entry("connection refused", errors.New("dial tcp: connection refused"), upload.ErrorClassNetwork),
+		Entry("s3 access denied", errors.New("api error AccessDenied: Access Denied"), upload.ErrorClassAuth),
+		Entry("scp permission denied", errors.New("Permission denied (publickey)"), upload.ErrorClassAuth),
+		Entry("missing bucket", errors.New("api error NoSuchBucket"), upload.ErrorClassNotFound),
+		Entry("anything else", errors.New("boom"), upload.ErrorClassUnknown),
+	)
+})
@@ -7,6 +7,7 @@
 package upload
 
 import (
+	"context"
 	"fmt"
 
 	"code.cloudfoundry.org/lager/v3"
@@ -20,7 +21,7 @@ import (
 )
 
 type Uploader interface {
-	Upload(localPath string, sessionLogger lager.Logger, processManager process.ProcessManager) error
+	Upload(ctx context.Context, localPath string, sessionLogger lager.Logger, processManager process.ProcessManager) error
 	Name() string
 }
 
@@ -44,20 +45,54 @@ func Initialize(conf *config.BackupConfig, logger lager.Logger, options ...Optio
 
 	uploaders := make([]Uploader, len(conf.Destinations))
 
+	globalThrottle, err := newThrottle(conf.BandwidthLimit, opts.location, nil)
+	if err != nil {
+		logger.Error("error parsing bandwidth limit", err)
+		return nil, err
+	}
+
 	for i, dest := range conf.Destinations {
+		destinationThrottle, err := newThrottle(dest.BandwidthLimit, opts.location, globalThrottle)
+		if err != nil {
+			logger.Error("error parsing bandwidth limit", err, lager.Data{"destination_name": dest.Name})
+			return nil, err
+		}
+
+		if dest.UploadWorkers < 0 {
+			err := fmt.Errorf("upload_workers must not be negative, got %d", dest.UploadWorkers)
+			logger.Error("error parsing upload workers", err, lager.Data{"destination_name": dest.Name})
+			return nil, err
+		}
+
 		switch dest.Type {
 		case "s3":
 			caCert, err := opts.caCertLocator()
 			if err != nil {
 				return nil, err
 			}
-			uploaders[i] = opts.factory.S3(dest, caCert)
+			client := opts.factory.S3(dest, caCert)
+			client.Throttle = destinationThrottle
+			client.Workers = dest.UploadWorkers
+			uploaders[i] = client
 		case "scp":
-			uploaders[i] = opts.factory.SCP(dest)
+			if dest.UploadWorkers > 1 {
+				err := fmt.Errorf("upload_workers is not supported by scp destinations")
+				logger.Error("error parsing upload workers", err, lager.Data{"destination_name": dest.Name})
+				return nil, err
+			}
+			client := opts.factory.SCP(dest)
+			client.Throttle = destinationThrottle
+			uploaders[i] = client
 		case "azure":
-			uploaders[i] = opts.factory.Azure(dest)
+			client := opts.factory.Azure(dest)
+			client.Throttle = destinationThrottle
+			client.Workers = dest.UploadWorkers
+			uploaders[i] = client
 		case "gcs":
-			uploaders[i] = opts.factory.GCS(dest)
+			client := opts.factory.GCS(dest)
+			client.Throttle = destinationThrottle
+			client.Workers = dest.UploadWorkers
+			uploaders[i] = client
 		default:
 			err := fmt.Errorf("unknown destination type: %s", dest.Type)
 			logger.Error("error parsing destinations", err)
@@ -65,5 +100,11 @@ func Initialize(conf *config.BackupConfig, logger lager.Logger, options ...Optio
 		}
 	}
 
-	return &multiUploader{uploaders}, nil
+	if conf.UploadParallelism < 0 {
+		err := fmt.Errorf("upload_parallelism must not be negative, got %d", conf.UploadParallelism)
+		logger.Error("error parsing upload parallelism", err)
+		return nil, err
+	}
+
+	return &multiUploader{uploaders: uploaders, parallelism: conf.UploadParallelism}, nil
 }
@@ -8,6 +8,8 @@ package upload_test
 
 import (
 	"errors"
+	"fmt"
+	"time"
 
 	"code.cloudfoundry.org/lager/v3"
 	. "github.com/onsi/ginkgo/v2"
@@ -113,6 +115,121 @@ var _ = Describe("Initialize", func() {
 		})
 	})
 
+	Context("when a bandwidth limit is configured", func() {
+		It("attaches a throttle bound by the global limit to each uploader", func() {
+			backupConfig := backupConfig("scp")
+			backupConfig.BandwidthLimit = &config.BandwidthLimit{BytesPerSecond: 1000}
+			backupConfig.Destinations[0].BandwidthLimit = &config.BandwidthLimit{BytesPerSecond: 5000}
+			client := scp.New("scp", "", 0, "", "", "", nil)
+			factory.SCPReturns(client)
+
+			_, err := upload.Initialize(backupConfig, logger, upload.WithUploaderFactory(factory), upload.WithCACertLocator(noopCACertLocator))
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(client.Throttle).NotTo(BeNil())
+			Expect(client.Throttle.Rate()).To(Equal(int64(1000)))
+		})
+
+		It("returns an error when the schedule is invalid", func() {
+			backupConfig := backupConfig("scp")
+			backupConfig.Destinations[0].BandwidthLimit = &config.BandwidthLimit{
+				Schedule: []config.BandwidthWindow{{Start: "midnight", End: "06:00"}},
+			}
+
+			_, err := upload.Initialize(backupConfig, logger, upload.WithUploaderFactory(factory), upload.WithCACertLocator(noopCACertLocator))
+
+			Expect(err).To(MatchError(`invalid time of day "midnight", expected HH:MM`))
+		})
+
+		It("returns an error when the rate is negative", func() {
+			backupConfig := backupConfig("scp")
+			backupConfig.BandwidthLimit = &config.BandwidthLimit{BytesPerSecond: -1}
+
+			_, err := upload.Initialize(backupConfig, logger, upload.WithUploaderFactory(factory), upload.WithCACertLocator(noopCACertLocator))
+
+			Expect(err).To(MatchError("bandwidth_limit.bytes_per_second must not be negative, got -1"))
+		})
+
+		It("returns an error when the rate of a window is negative", func() {
+			backupConfig := backupConfig("scp")
+			backupConfig.Destinations[0].BandwidthLimit = &config.BandwidthLimit{
+				Schedule: []config.BandwidthWindow{{Start: "00:00", End: "06:00", BytesPerSecond: -1024}},
+			}
+
+			_, err := upload.Initialize(backupConfig, logger, upload.WithUploaderFactory(factory), upload.WithCACertLocator(noopCACertLocator))
+
+			Expect(err).To(MatchError("bandwidth_limit.schedule bytes_per_second must not be negative, got -1024 for 00:00-06:00"))
+		})
+
+		It("reads the times of day in the schedule in the given location", func() {
+			// A zone twelve hours from local time, where the current hour is
+			// inside a window that it is not inside locally.
+			_, localOffset := time.Now().Zone()
+			location := time.FixedZone("opposite", localOffset+12*60*60)
+			hour := time.Now().In(location).Hour()
+			backupConfig := backupConfig("scp")
+			backupConfig.BandwidthLimit = &config.BandwidthLimit{
+				BytesPerSecond: 1000,
+				Schedule: []config.BandwidthWindow{{
+					Start:          fmt.Sprintf("%02d:00", (hour+23)%24),
+					End:            fmt.Sprintf("%02d:00", (hour+2)%24),
+					BytesPerSecond: 50,
+				}},
+			}
+			client := scp.New("scp", "", 0, "", "", "", nil)
+			factory.SCPReturns(client)
+
+			_, err := upload.Initialize(backupConfig, logger, upload.WithUploaderFactory(factory), upload.WithCACertLocator(noopCACertLocator), upload.WithLocation(location))
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(client.Throttle.Rate()).To(Equal(int64(50)))
+		})
+	})
+
+	Context("when upload parallelism is configured", func() {
+		It("returns an error when it is negative", func() {
+			backupConfig := backupConfig("scp")
+			backupConfig.UploadParallelism = -1
+			factory.SCPReturns(scp.New("scp", "", 0, "", "", "", nil))
+
+			_, err := upload.Initialize(backupConfig, logger, upload.WithUploaderFactory(factory), upload.WithCACertLocator(noopCACertLocator))
+
+			Expect(err).To(MatchError("upload_parallelism must not be negative, got -1"))
+		})
+	})
+
+	Context("when upload workers are configured", func() {
+		It("sets the number of workers on the destination's client", func() {
+			backupConfig := backupConfig("gcs")
+			backupConfig.Destinations[0].UploadWorkers = 8
+			client := gcs.New("gcs", "", "", "", nil)
+			factory.GCSReturns(client)
+
+			_, err := upload.Initialize(backupConfig, logger, upload.WithUploaderFactory(factory), upload.WithCACertLocator(noopCACertLocator))
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(client.Workers).To(Equal(8))
+		})
+
+		It("returns an error when the number is negative", func() {
+			backupConfig := backupConfig("azure")
+			backupConfig.Destinations[0].UploadWorkers = -1
+
+			_, err := upload.Initialize(backupConfig, logger, upload.WithUploaderFactory(factory), upload.WithCACertLocator(noopCACertLocator))
+
+			Expect(err).To(MatchError("upload_workers must not be negative, got -1"))
+		})
+
+		It("returns an error for scp destinations", func() {
+			backupConfig := backupConfig("scp")
+			backupConfig.Destinations[0].UploadWorkers = 4
+
+			_, err := upload.Initialize(backupConfig, logger, upload.WithUploaderFactory(factory), upload.WithCACertLocator(noopCACertLocator))
+
+			Expect(err).To(MatchError("upload_workers is not supported by scp destinations"))
+		})
+	})
+
 	Context("when an unknown destination type is configured", func() {
 		It("returns an error", func() {
 			backupConfig := backupConfig("unknown-type")
@@ -7,49 +7,152 @@
 package upload
 
 import (
+	"context"
 	"fmt"
+	"slices"
 	"strings"
+	"sync"
 
 	"code.cloudfoundry.org/lager/v3"
+	"github.com/pivotal-cf/service-backup/lease"
 	"github.com/pivotal-cf/service-backup/process"
+	"github.com/pivotal-cf/service-backup/tracing"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type multiUploader struct {
 	uploaders []Uploader
+	// parallelism is how many destinations are uploaded to at once. Zero or
+	// one uploads to them one after another.
+	parallelism int
 }
 
-func (m *multiUploader) Upload(localPath string, logger lager.Logger, processManager process.ProcessManager) error {
-	var errors []error
-	for _, u := range m.uploaders {
+// DestinationError is the error returned by the uploader for a single
+// destination.
+type DestinationError struct {
+	Destination string
+	Err         error
+}
+
+func (e DestinationError) Error() string {
+	return e.Err.Error()
+}
+
+func (e DestinationError) Unwrap() error {
+	return e.Err
+}
+
+// DestinationErrors is returned by a multi-uploader when one or more of its
+// destinations failed.
+type DestinationErrors []DestinationError
+
+func (e DestinationErrors) Error() string {
+	errorMessages := []string{}
+	for _, err := range e {
+		errorMessages = append(errorMessages, err.Error())
+	}
+	return strings.Join(errorMessages, "; ")
+}
+
+type destinationsKey struct{}
+
+// WithDestinations returns a context that limits a multi-uploader to the
+// named destinations, for uploads that only some destinations are due.
+func WithDestinations(ctx context.Context, names []string) context.Context {
+	return context.WithValue(ctx, destinationsKey{}, names)
+}
+
+// Due reports whether an upload with ctx is due to the named destination.
+func Due(ctx context.Context, name string) bool {
+	names, ok := ctx.Value(destinationsKey{}).([]string)
+	return !ok || slices.Contains(names, name)
+}
+
+// Upload uploads to every destination due, up to the configured number at
+// once, and returns the errors of those that failed in destination order.
+func (m *multiUploader) Upload(ctx context.Context, localPath string, logger lager.Logger, processManager process.ProcessManager) error {
+	parallelism := max(m.parallelism, 1)
+	if parallelism > 1 {
+		logger.Info("Uploading to destinations in parallel", lager.Data{"parallelism": parallelism})
+	}
+
+	var (
+		errs    = make([]error, len(m.uploaders))
+		slots   = make(chan struct{}, parallelism)
+		waiting sync.WaitGroup
+	)
+	for i, u := range m.uploaders {
 		sessionLogger := logger
 		if u.Name() != "" {
 			sessionLogger = logger.WithData(lager.Data{"destination_name": u.Name()})
 		}
-		err := u.Upload(localPath, sessionLogger, processManager)
+		if !Due(ctx, u.Name()) {
+			sessionLogger.Info("Destination not due, skipping upload")
+			continue
+		}
+
+		slots <- struct{}{}
+		waiting.Add(1)
+		go func() {
+			defer waiting.Done()
+			defer func() { <-slots }()
+
+			destinationCtx, span := tracing.Tracer(ctx).Start(ctx, "upload-destination", trace.WithAttributes(
+				tracing.DestinationKey.String(u.Name()),
+			))
+			errs[i] = u.Upload(destinationCtx, localPath, sessionLogger, processManager)
+			tracing.End(span, errs[i])
+		}()
+	}
+	waiting.Wait()
+
+	var errors DestinationErrors
+	for i, err := range errs {
 		if err != nil {
-			errors = append(errors, err)
+			errors = append(errors, DestinationError{Destination: m.uploaders[i].Name(), Err: err})
 		}
 	}
-	return formattedError(errors)
+	if len(errors) == 0 {
+		return nil
+	}
+	return errors
 }
 
-func (m *multiUploader) Name() string {
+// Destinations returns the names of the destinations uploaded to, in order.
+func (m *multiUploader) Destinations() []string {
 	names := make([]string, len(m.uploaders))
 	for i, u := range m.uploaders {
 		names[i] = u.Name()
 	}
+	return names
+}
 
-	return fmt.Sprintf("multi-uploader: %s", strings.Join(names, ", "))
+type leaseStore interface {
+	LeaseBackend(key string, logger lager.Logger) lease.Backend
 }
 
-func formattedError(errors []error) error {
-	if len(errors) == 0 {
-		return nil
+// LeaseBackend returns a backend storing a lease as key at the named
+// destination, for nodes backing up the same data to elect which of them
+// runs each backup.
+func (m *multiUploader) LeaseBackend(destination, key string, logger lager.Logger) (lease.Backend, error) {
+	for _, u := range m.uploaders {
+		if u.Name() != destination {
+			continue
+		}
+		store, ok := u.(leaseStore)
+		if !ok {
+			return nil, fmt.Errorf("destination %s cannot store a lease", destination)
+		}
+		return store.LeaseBackend(key, logger), nil
 	}
+	return nil, fmt.Errorf("unknown destination: %s", destination)
+}
 
-	errorMessages := []string{}
-	for _, e := range errors {
-		errorMessages = append(errorMessages, e.Error())
+func (m *multiUploader) Name() string {
+	names := make([]string, len(m.uploaders))
+	for i, u := range m.uploaders {
+		names[i] = u.Name()
 	}
-	return fmt.Errorf("%s", strings.Join(errorMessages, "; "))
+
+	return fmt.Sprintf("multi-uploader: %s", strings.Join(names, ", "))
 }
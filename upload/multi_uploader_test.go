@@ -7,12 +7,21 @@
 package upload
 
 import (
+	"context"
 	"errors"
+	"sync"
+	"time"
 
 	"code.cloudfoundry.org/lager/v3"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/pivotal-cf/service-backup/azure"
 	"github.com/pivotal-cf/service-backup/process"
+	"github.com/pivotal-cf/service-backup/tracing"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 var _ = Describe("multiUploader", func() {
@@ -31,13 +40,13 @@ var _ = Describe("multiUploader", func() {
 		BeforeEach(func() {
 			uploaderA = new(fakeUploader)
 			uploaderB = new(fakeUploader)
-			uploader = &multiUploader{[]Uploader{uploaderA, uploaderB}}
+			uploader = &multiUploader{uploaders: []Uploader{uploaderA, uploaderB}}
 			processManager = process.NewManager()
 		})
 
 		Context("when all uploads succeed", func() {
 			It("calls upload on each uploader", func() {
-				err := uploader.Upload(localPath, logger, processManager)
+				err := uploader.Upload(context.Background(), localPath, logger, processManager)
 
 				Expect(err).NotTo(HaveOccurred())
 
@@ -61,12 +70,12 @@ var _ = Describe("multiUploader", func() {
 			})
 
 			It("returns the error from the first uploader", func() {
-				err := uploader.Upload(localPath, logger, processManager)
+				err := uploader.Upload(context.Background(), localPath, logger, processManager)
 				Expect(err).To(MatchError(ContainSubstring("first backup failed")))
 			})
 
 			It("calls upload on all the uploaders", func() {
-				uploader.Upload(localPath, logger, processManager)
+				uploader.Upload(context.Background(), localPath, logger, processManager)
 				Expect(len(uploaderA.uploadArgs)).To(Equal(1))
 				Expect(len(uploaderB.uploadArgs)).To(Equal(1))
 			})
@@ -79,22 +88,193 @@ var _ = Describe("multiUploader", func() {
 			})
 
 			It("returns the errors from both uploaders", func() {
-				err := uploader.Upload(localPath, logger, processManager)
+				err := uploader.Upload(context.Background(), localPath, logger, processManager)
 				Expect(err).To(MatchError(ContainSubstring("first backup failed")))
 				Expect(err).To(MatchError(ContainSubstring("second backup failed")))
 			})
 
+			It("reports which destination each error came from", func() {
+				uploaderA.name = "a"
+				uploaderB.name = "b"
+
+				err := uploader.Upload(context.Background(), localPath, logger, processManager)
+				Expect(err).To(Equal(DestinationErrors{
+					{Destination: "a", Err: uploaderA.uploadErr},
+					{Destination: "b", Err: uploaderB.uploadErr},
+				}))
+				Expect(err).To(MatchError("first backup failed; second backup failed"))
+			})
+
 			It("calls upload on all the uploaders", func() {
-				uploader.Upload(localPath, logger, processManager)
+				uploader.Upload(context.Background(), localPath, logger, processManager)
 				Expect(len(uploaderA.uploadArgs)).To(Equal(1))
 				Expect(len(uploaderB.uploadArgs)).To(Equal(1))
 			})
 		})
+
+		Context("when the context carries a span", func() {
+			It("records a child span for each destination", func() {
+				uploaderA.name = "a"
+				uploaderB.name = "b"
+				uploaderB.uploadErr = errors.New("second backup failed")
+
+				recorder := tracetest.NewSpanRecorder()
+				provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+				ctx, parent := provider.Tracer("test").Start(context.Background(), "upload")
+
+				uploader.Upload(ctx, localPath, logger, processManager)
+
+				spans := recorder.Ended()
+				Expect(spans).To(HaveLen(2))
+				for i, name := range []string{"a", "b"} {
+					Expect(spans[i].Name()).To(Equal("upload-destination"))
+					Expect(spans[i].Parent().SpanID()).To(Equal(parent.SpanContext().SpanID()))
+					Expect(spans[i].Attributes()).To(ContainElement(tracing.DestinationKey.String(name)))
+				}
+				Expect(spans[0].Status().Code).To(Equal(codes.Unset))
+				Expect(spans[1].Status().Code).To(Equal(codes.Error))
+			})
+		})
+
+		Context("when the context limits the destinations", func() {
+			It("uploads only to the destinations due", func() {
+				uploaderA.name = "a"
+				uploaderB.name = "b"
+				uploaderB.uploadErr = errors.New("not due")
+
+				ctx := WithDestinations(context.Background(), []string{"a"})
+				Expect(uploader.Upload(ctx, localPath, logger, processManager)).To(Succeed())
+
+				Expect(uploaderA.uploadArgs).To(HaveLen(1))
+				Expect(uploaderB.uploadArgs).To(BeEmpty())
+			})
+		})
+	})
+
+	Describe("parallelism", func() {
+		var (
+			lock             sync.Mutex
+			running, maxSeen int
+			uploaders        []Uploader
+			logger           = lager.NewLogger("multi-logger")
+		)
+
+		BeforeEach(func() {
+			running, maxSeen = 0, 0
+			uploaders = nil
+			for _, name := range []string{"a", "b", "c"} {
+				uploaders = append(uploaders, &funcUploader{name: name, upload: func(context.Context) error {
+					lock.Lock()
+					running++
+					maxSeen = max(maxSeen, running)
+					lock.Unlock()
+
+					time.Sleep(20 * time.Millisecond)
+
+					lock.Lock()
+					running--
+					lock.Unlock()
+					return nil
+				}})
+			}
+		})
+
+		It("uploads to one destination at a time by default", func() {
+			uploader := &multiUploader{uploaders: uploaders}
+
+			Expect(uploader.Upload(context.Background(), "local/path", logger, process.NewManager())).To(Succeed())
+			Expect(maxSeen).To(Equal(1))
+		})
+
+		It("uploads to up to the configured number of destinations at once", func() {
+			uploader := &multiUploader{uploaders: uploaders, parallelism: 2}
+
+			Expect(uploader.Upload(context.Background(), "local/path", logger, process.NewManager())).To(Succeed())
+			Expect(maxSeen).To(Equal(2))
+		})
+
+		It("returns errors in destination order, whichever finishes first", func() {
+			firstErr := errors.New("first backup failed")
+			thirdErr := errors.New("third backup failed")
+			uploader := &multiUploader{parallelism: 3, uploaders: []Uploader{
+				&funcUploader{name: "a", upload: func(context.Context) error {
+					time.Sleep(20 * time.Millisecond)
+					return firstErr
+				}},
+				&funcUploader{name: "b", upload: func(context.Context) error { return nil }},
+				&funcUploader{name: "c", upload: func(context.Context) error { return thirdErr }},
+			}}
+
+			err := uploader.Upload(context.Background(), "local/path", logger, process.NewManager())
+			Expect(err).To(Equal(DestinationErrors{
+				{Destination: "a", Err: firstErr},
+				{Destination: "c", Err: thirdErr},
+			}))
+		})
+
+		It("logs each upload with its own destination", func() {
+			log := gbytes.NewBuffer()
+			logger := lager.NewLogger("multi-logger")
+			logger.RegisterSink(lager.NewWriterSink(log, lager.DEBUG))
+			uploader := &multiUploader{parallelism: 2, uploaders: []Uploader{
+				&funcUploader{name: "a", upload: func(context.Context) error { return nil }, logger: true},
+				&funcUploader{name: "b", upload: func(context.Context) error { return nil }, logger: true},
+			}}
+
+			Expect(uploader.Upload(context.Background(), "local/path", logger, process.NewManager())).To(Succeed())
+			Expect(log).To(gbytes.Say(`Uploading to destinations in parallel","log_level":1,"data":{"parallelism":2}`))
+			Expect(log.Contents()).To(ContainSubstring(`"destination_name":"a","uploader":"a"`))
+			Expect(log.Contents()).To(ContainSubstring(`"destination_name":"b","uploader":"b"`))
+		})
+	})
+
+	Describe("Due", func() {
+		It("is due to every destination unless the context limits them", func() {
+			Expect(Due(context.Background(), "a")).To(BeTrue())
+
+			ctx := WithDestinations(context.Background(), []string{"a"})
+			Expect(Due(ctx, "a")).To(BeTrue())
+			Expect(Due(ctx, "b")).To(BeFalse())
+		})
+	})
+
+	Describe("Destinations", func() {
+		It("returns the names of the uploaders it wraps", func() {
+			multi := &multiUploader{uploaders: []Uploader{&fakeUploader{name: "a"}, &fakeUploader{name: "b"}}}
+			Expect(multi.Destinations()).To(Equal([]string{"a", "b"}))
+		})
+	})
+
+	Describe("LeaseBackend", func() {
+		It("returns the lease backend of the named destination", func() {
+			multi := &multiUploader{uploaders: []Uploader{
+				&fakeUploader{name: "a"},
+				azure.New("b", "key", "account", "container", "", RemotePathFunc("path", "")),
+			}}
+
+			backend, err := multi.LeaseBackend("b", "leader.json", lager.NewLogger("multi-logger"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(backend).NotTo(BeNil())
+		})
+
+		It("returns an error for a destination that cannot store a lease", func() {
+			multi := &multiUploader{uploaders: []Uploader{&fakeUploader{name: "a"}}}
+
+			_, err := multi.LeaseBackend("a", "leader.json", lager.NewLogger("multi-logger"))
+			Expect(err).To(MatchError("destination a cannot store a lease"))
+		})
+
+		It("returns an error for an unknown destination", func() {
+			multi := &multiUploader{uploaders: []Uploader{&fakeUploader{name: "a"}}}
+
+			_, err := multi.LeaseBackend("b", "leader.json", lager.NewLogger("multi-logger"))
+			Expect(err).To(MatchError("unknown destination: b"))
+		})
 	})
 
 	Describe("Name", func() {
 		It("returns the names of uploaders it warps", func() {
-			multi := &multiUploader{[]Uploader{&fakeUploader{name: "a"}, &fakeUploader{name: "b"}}}
+			multi := &multiUploader{uploaders: []Uploader{&fakeUploader{name: "a"}, &fakeUploader{name: "b"}}}
 			Expect(multi.Name()).To(Equal("multi-uploader: a, b"))
 		})
 	})
@@ -109,7 +289,7 @@ type fakeUploader struct {
 	name string
 }
 
-func (f *fakeUploader) Upload(name string, logger lager.Logger, _ process.ProcessManager) error {
+func (f *fakeUploader) Upload(_ context.Context, name string, logger lager.Logger, _ process.ProcessManager) error {
 	f.uploadArgs = append(f.uploadArgs, struct {
 		string
 		lager.Logger
@@ -120,3 +300,20 @@ func (f *fakeUploader) Upload(name string, logger lager.Logger, _ process.Proces
 func (f *fakeUploader) Name() string {
 	return f.name
 }
+
+type funcUploader struct {
+	name   string
+	upload func(context.Context) error
+	logger bool
+}
+
+func (f *funcUploader) Upload(ctx context.Context, _ string, logger lager.Logger, _ process.ProcessManager) error {
+	if f.logger {
+		logger.Info("uploading", lager.Data{"uploader": f.name})
+	}
+	return f.upload(ctx)
+}
+
+func (f *funcUploader) Name() string {
+	return f.name
+}
@@ -6,11 +6,14 @@
 
 package upload
 
+import "time"
+
 type CACertLocator func() (string, error)
 
 type opts struct {
 	factory       UploaderFactory
 	caCertLocator CACertLocator
+	location      *time.Location
 }
 
 type Option func(*opts)
@@ -26,3 +29,12 @@ func WithCACertLocator(l CACertLocator) Option {
 		o.caCertLocator = l
 	}
 }
+
+// WithLocation sets the timezone that the times of day in bandwidth limit
+// schedules are read in, which should be the one backups are scheduled in.
+// Without it they are read in the local timezone.
+func WithLocation(location *time.Location) Option {
+	return func(o *opts) {
+		o.location = location
+	}
+}
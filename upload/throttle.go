@@ -0,0 +1,48 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package upload
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pivotal-cf/service-backup/config"
+	"github.com/pivotal-cf/service-backup/throttle"
+)
+
+// newThrottle builds the throttle for limit, whose windows are times of day
+// in location.
+func newThrottle(limit *config.BandwidthLimit, location *time.Location, parent *throttle.Throttle) (*throttle.Throttle, error) {
+	if limit == nil {
+		return parent, nil
+	}
+	if limit.BytesPerSecond < 0 {
+		return nil, fmt.Errorf("bandwidth_limit.bytes_per_second must not be negative, got %d", limit.BytesPerSecond)
+	}
+
+	schedule := throttle.Schedule{BytesPerSecond: limit.BytesPerSecond, Location: location}
+	for _, w := range limit.Schedule {
+		start, err := throttle.ParseTimeOfDay(w.Start)
+		if err != nil {
+			return nil, err
+		}
+		end, err := throttle.ParseTimeOfDay(w.End)
+		if err != nil {
+			return nil, err
+		}
+		if w.BytesPerSecond < 0 {
+			return nil, fmt.Errorf("bandwidth_limit.schedule bytes_per_second must not be negative, got %d for %s-%s", w.BytesPerSecond, w.Start, w.End)
+		}
+		schedule.Windows = append(schedule.Windows, throttle.Window{
+			Start:          start,
+			End:            end,
+			BytesPerSecond: w.BytesPerSecond,
+		})
+	}
+
+	return throttle.New(schedule, parent), nil
+}
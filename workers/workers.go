@@ -0,0 +1,112 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+// Package workers uploads the files under a directory from a bounded pool of
+// goroutines.
+package workers
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"code.cloudfoundry.org/lager/v3"
+)
+
+var errStopped = errors.New("stopped walking after a failed upload")
+
+// UploadFunc uploads a single file.
+type UploadFunc func(ctx context.Context, path string, info os.FileInfo) error
+
+// ForEachFile walks dir in lexical order and calls upload for every file
+// under it, from up to n goroutines at once, or one at a time if n is less
+// than two.
+//
+// The first failure stops the walk, so no more files are started. Uploads
+// already in progress are left to finish, so that any error they return is
+// their own rather than a cancellation. Of the files that failed, the error
+// of the first in walk order is returned, whichever failed first in time.
+func ForEachFile(ctx context.Context, dir string, n int, upload UploadFunc) error {
+	type file struct {
+		index int
+		path  string
+		info  os.FileInfo
+	}
+
+	var (
+		files    = make(chan file)
+		stopped  = make(chan struct{})
+		stopOnce sync.Once
+		running  sync.WaitGroup
+
+		lock        sync.Mutex
+		failedIndex int
+		failure     error
+	)
+	fail := func(index int, err error) {
+		lock.Lock()
+		defer lock.Unlock()
+		if failure == nil || index < failedIndex {
+			failedIndex, failure = index, err
+		}
+		stopOnce.Do(func() { close(stopped) })
+	}
+
+	for range max(n, 1) {
+		running.Add(1)
+		go func() {
+			defer running.Done()
+			for f := range files {
+				if err := upload(ctx, f.path, f.info); err != nil {
+					fail(f.index, err)
+				}
+			}
+		}()
+	}
+
+	index := 0
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		select {
+		case <-stopped:
+			return errStopped
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		select {
+		case files <- file{index: index, path: path, info: info}:
+			index++
+			return nil
+		case <-stopped:
+			return errStopped
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	close(files)
+	running.Wait()
+
+	if err != nil && err != errStopped {
+		// The walk failed after every file it had found.
+		fail(index, err)
+	}
+	return failure
+}
+
+// Log logs the number of files uploaded at once, if more than one.
+func Log(logger lager.Logger, n int) {
+	if n > 1 {
+		logger.Info("Uploading files in parallel", lager.Data{"workers": n})
+	}
+}
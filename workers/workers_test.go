@@ -0,0 +1,159 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package workers_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-cf/service-backup/workers"
+)
+
+var _ = Describe("ForEachFile", func() {
+	var dir string
+
+	BeforeEach(func() {
+		dir = GinkgoT().TempDir()
+		Expect(os.MkdirAll(filepath.Join(dir, "nested"), 0755)).To(Succeed())
+		for _, name := range []string{"a", "b", "c", "d", "nested/e", "nested/f"} {
+			Expect(os.WriteFile(filepath.Join(dir, name), []byte(name), 0644)).To(Succeed())
+		}
+	})
+
+	It("uploads every file, but not the directories", func() {
+		var (
+			lock     sync.Mutex
+			uploaded []string
+		)
+		err := workers.ForEachFile(context.Background(), dir, 3, func(_ context.Context, path string, info os.FileInfo) error {
+			lock.Lock()
+			defer lock.Unlock()
+			Expect(info.IsDir()).To(BeFalse())
+			uploaded = append(uploaded, path)
+			return nil
+		})
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(uploaded).To(ConsistOf(
+			filepath.Join(dir, "a"),
+			filepath.Join(dir, "b"),
+			filepath.Join(dir, "c"),
+			filepath.Join(dir, "d"),
+			filepath.Join(dir, "nested", "e"),
+			filepath.Join(dir, "nested", "f"),
+		))
+	})
+
+	It("uploads one file at a time when given fewer than two workers", func() {
+		var uploaded []string
+		err := workers.ForEachFile(context.Background(), dir, 0, func(_ context.Context, path string, _ os.FileInfo) error {
+			uploaded = append(uploaded, filepath.Base(path))
+			return nil
+		})
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(uploaded).To(Equal([]string{"a", "b", "c", "d", "e", "f"}))
+	})
+
+	It("uploads no more files at once than it has workers", func() {
+		var running, most int32
+		err := workers.ForEachFile(context.Background(), dir, 2, func(context.Context, string, os.FileInfo) error {
+			n := atomic.AddInt32(&running, 1)
+			defer atomic.AddInt32(&running, -1)
+			for {
+				m := atomic.LoadInt32(&most)
+				if n <= m || atomic.CompareAndSwapInt32(&most, m, n) {
+					break
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+			return nil
+		})
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(atomic.LoadInt32(&most)).To(Equal(int32(2)))
+	})
+
+	It("stops starting uploads after the first failure", func() {
+		var started int32
+		err := workers.ForEachFile(context.Background(), dir, 1, func(_ context.Context, path string, _ os.FileInfo) error {
+			atomic.AddInt32(&started, 1)
+			if filepath.Base(path) == "b" {
+				return errors.New("upload of b failed")
+			}
+			return nil
+		})
+
+		Expect(err).To(MatchError("upload of b failed"))
+		Expect(atomic.LoadInt32(&started)).To(BeNumerically("<=", 3))
+	})
+
+	It("returns the error of the first failed file in walk order", func() {
+		bFailing := make(chan struct{})
+		err := workers.ForEachFile(context.Background(), dir, 2, func(_ context.Context, path string, _ os.FileInfo) error {
+			switch filepath.Base(path) {
+			case "a":
+				<-bFailing
+				time.Sleep(20 * time.Millisecond)
+				return errors.New("upload of a failed")
+			case "b":
+				close(bFailing)
+				return errors.New("upload of b failed")
+			}
+			return nil
+		})
+
+		Expect(err).To(MatchError("upload of a failed"))
+	})
+
+	It("lets uploads in progress finish after a failure", func() {
+		aFinished := false
+		bFailing := make(chan struct{})
+		err := workers.ForEachFile(context.Background(), dir, 2, func(ctx context.Context, path string, _ os.FileInfo) error {
+			switch filepath.Base(path) {
+			case "a":
+				<-bFailing
+				time.Sleep(20 * time.Millisecond)
+				aFinished = ctx.Err() == nil
+			case "b":
+				close(bFailing)
+				return errors.New("upload of b failed")
+			}
+			return nil
+		})
+
+		Expect(err).To(MatchError("upload of b failed"))
+		Expect(aFinished).To(BeTrue())
+	})
+
+	It("stops walking when the context is cancelled", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		err := workers.ForEachFile(ctx, dir, 1, func(_ context.Context, path string, _ os.FileInfo) error {
+			if filepath.Base(path) == "a" {
+				cancel()
+			}
+			return nil
+		})
+
+		Expect(err).To(MatchError(context.Canceled))
+	})
+
+	It("returns an error when the directory does not exist", func() {
+		err := workers.ForEachFile(context.Background(), filepath.Join(dir, "missing"), 2, func(context.Context, string, os.FileInfo) error {
+			return nil
+		})
+
+		Expect(err).To(MatchError(os.ErrNotExist))
+	})
+})